@@ -0,0 +1,468 @@
+// # Mini 6502 assembler
+//
+// This package turns small snippets of 6502 source code into machine code,
+// so routines injected into the emulator can be written as readable source
+// instead of hand-encoded byte arrays.
+//
+// Syntax overview:
+//   - one instruction per line, comments start with ';'
+//   - labels end with ':', local labels start with '@' and are scoped to the preceding global label
+//   - constants: 'name = expression'
+//   - origin: '* = expression' or '.org expression', it can only move forward (an origin
+//     lower than the current address is an error), each origin starts a new segment
+//   - data: '.byte 1, $02, "text"' and '.word label, $1000'
+//   - expressions: see expression.go
+package asm6502
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Assembled program
+type Program struct {
+	Origin   uint16            // address of the first byte of Code
+	Code     []byte            // assembled machine code, gaps between segments are filled with zeros
+	Segments []Segment         // code of each origin, without the gaps
+	Labels   map[string]uint16 // labels and constants, local labels are stored as 'global@local'
+}
+
+// Continuous block of assembled code
+type Segment struct {
+	Origin uint16
+	Code   []byte
+}
+
+// Single source line split into parts
+type sourceLine struct {
+	number    int
+	scope     string // global label the line belongs to
+	label     string
+	operation string // lowercase mnemonic, directive, '=' or '*='
+	operand   string
+	mode      AddressingMode // addressing mode selected in the first pass
+}
+
+type assembler struct {
+	origin  int
+	pc      int
+	pass    int
+	symbols map[string]int
+	defined map[string]bool // symbols defined in the current pass
+	lines   []*sourceLine
+	output  []byte
+	starts  []int // output offsets of segments, a segment ends at the next gap
+	ends    []int
+}
+
+var (
+	indexedIndirectRegexp = regexp.MustCompile(`^\((.*),\s*[xX]\s*\)$`)
+	indirectIndexedRegexp = regexp.MustCompile(`^\((.*)\)\s*,\s*[yY]$`)
+	indirectRegexp        = regexp.MustCompile(`^\((.*)\)$`)
+	indexedRegexp         = regexp.MustCompile(`^(.*),\s*([xXyY])$`)
+	assignmentRegexp      = regexp.MustCompile(`^([A-Za-z_@][A-Za-z0-9_.@]*|\*)\s*=\s*(.+)$`)
+	labelRegexp           = regexp.MustCompile(`^([A-Za-z_@][A-Za-z0-9_.@]*):\s*(.*)$`)
+)
+
+// Assemble 6502 source code starting at the origin address
+func Assemble(origin uint16, src string) (*Program, error) {
+	asm := &assembler{
+		origin:  int(origin),
+		symbols: map[string]int{},
+	}
+
+	if err := asm.parse(src); err != nil {
+		return nil, err
+	}
+
+	for asm.pass = 1; asm.pass <= 2; asm.pass++ {
+		asm.pc = asm.origin
+		asm.output = asm.output[:0]
+		asm.starts, asm.ends = []int{0}, nil
+		asm.defined = map[string]bool{}
+		for _, line := range asm.lines {
+			if err := asm.assembleLine(line); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line.number, err)
+			}
+		}
+	}
+
+	program := &Program{
+		Origin: origin,
+		Code:   asm.output,
+		Labels: map[string]uint16{},
+	}
+	asm.ends = append(asm.ends, len(asm.output))
+	for idx, start := range asm.starts {
+		if asm.ends[idx] > start {
+			program.Segments = append(program.Segments, Segment{
+				Origin: origin + uint16(start),
+				Code:   asm.output[start:asm.ends[idx]],
+			})
+		}
+	}
+	for name, value := range asm.symbols {
+		program.Labels[name] = uint16(value)
+	}
+
+	return program, nil
+}
+
+// Split source into lines, labels, operations and operands
+func (asm *assembler) parse(src string) error {
+	scope := ""
+	for idx, text := range strings.Split(src, "\n") {
+		text = strings.TrimSpace(stripComment(text))
+		line := &sourceLine{number: idx + 1}
+
+		if match := labelRegexp.FindStringSubmatch(text); match != nil {
+			line.label = match[1]
+			if !strings.HasPrefix(line.label, "@") {
+				scope = line.label
+			}
+			text = match[2]
+		}
+
+		if match := assignmentRegexp.FindStringSubmatch(text); match != nil {
+			if match[1] == "*" {
+				line.operation = "*="
+			} else {
+				line.label = match[1]
+				line.operation = "="
+			}
+			line.operand = strings.TrimSpace(match[2])
+		} else if text != "" {
+			operation, operand, _ := strings.Cut(strings.Replace(text, "\t", " ", 1), " ")
+			line.operation = strings.ToLower(operation)
+			line.operand = strings.TrimSpace(operand)
+		}
+
+		line.scope = scope
+		if line.label == "" && line.operation == "" {
+			continue
+		}
+		asm.lines = append(asm.lines, line)
+	}
+
+	return nil
+}
+
+// Assemble a single line in the current pass
+func (asm *assembler) assembleLine(line *sourceLine) error {
+	if line.operation != "=" && line.label != "" {
+		if err := asm.define(line, line.label, asm.pc); err != nil {
+			return err
+		}
+	}
+
+	switch line.operation {
+	case "":
+		return nil
+	case "=":
+		value, resolved, err := asm.evaluate(line, line.operand)
+		if err != nil {
+			return err
+		}
+		if !resolved {
+			if asm.pass == 2 {
+				return fmt.Errorf("undefined symbol in '%s'", line.operand)
+			}
+			return nil
+		}
+		return asm.define(line, line.label, value)
+	case "*=", ".org":
+		value, resolved, err := asm.evaluate(line, line.operand)
+		if err != nil {
+			return err
+		}
+		if !resolved {
+			return fmt.Errorf("origin must not use forward references: '%s'", line.operand)
+		}
+		return asm.setPC(value)
+	case ".byte":
+		return asm.emitData(line, 1)
+	case ".word":
+		return asm.emitData(line, 2)
+	}
+
+	if !IsMnemonic(line.operation) {
+		return fmt.Errorf("unknown instruction '%s'", line.operation)
+	}
+
+	return asm.emitInstruction(line)
+}
+
+// Define symbol, each symbol can be defined only once
+func (asm *assembler) define(line *sourceLine, name string, value int) error {
+	key := asm.symbolKey(line, name)
+	if asm.defined[key] {
+		return fmt.Errorf("symbol '%s' already defined", name)
+	}
+	asm.defined[key] = true
+	asm.symbols[key] = value
+	return nil
+}
+
+// Local labels are stored with the name of their global scope
+func (asm *assembler) symbolKey(line *sourceLine, name string) string {
+	if strings.HasPrefix(name, "@") {
+		return line.scope + name
+	}
+	return name
+}
+
+func (asm *assembler) evaluate(line *sourceLine, expression string) (int, bool, error) {
+	return evaluate(expression, asm.pc, func(name string) (int, bool) {
+		value, exist := asm.symbols[asm.symbolKey(line, name)]
+		return value, exist
+	})
+}
+
+// Evaluate expression which has to be resolved in the second pass
+func (asm *assembler) evaluateFinal(line *sourceLine, expression string) (int, bool, error) {
+	value, resolved, err := asm.evaluate(line, expression)
+	if err != nil {
+		return 0, false, err
+	}
+	if !resolved && asm.pass == 2 {
+		return 0, false, fmt.Errorf("undefined symbol in '%s'", expression)
+	}
+	return value, resolved, nil
+}
+
+func (asm *assembler) setPC(value int) error {
+	if value < asm.pc {
+		return fmt.Errorf("origin $%04x is lower than current address $%04x", value, asm.pc)
+	}
+	if value == asm.pc {
+		return nil
+	}
+	asm.ends = append(asm.ends, len(asm.output))
+	for asm.pc < value {
+		asm.emit(0)
+	}
+	asm.starts = append(asm.starts, len(asm.output))
+	return nil
+}
+
+func (asm *assembler) emit(values ...byte) {
+	asm.output = append(asm.output, values...)
+	asm.pc += len(values)
+}
+
+// Emit .byte and .word directives
+func (asm *assembler) emitData(line *sourceLine, size int) error {
+	items, err := splitList(line.operand)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("missing data for '%s'", line.operation)
+	}
+
+	for _, item := range items {
+		if size == 1 && strings.HasPrefix(item, "\"") {
+			if len(item) < 2 || !strings.HasSuffix(item, "\"") {
+				return fmt.Errorf("invalid string %s", item)
+			}
+			asm.emit([]byte(item[1 : len(item)-1])...)
+			continue
+		}
+
+		value, _, err := asm.evaluateFinal(line, item)
+		if err != nil {
+			return err
+		}
+		if size == 1 {
+			if value < -128 || value > 0xff {
+				return fmt.Errorf("value %d of '%s' doesn't fit in a byte", value, item)
+			}
+			asm.emit(byte(value))
+		} else {
+			if value < -0x8000 || value > 0xffff {
+				return fmt.Errorf("value %d of '%s' doesn't fit in a word", value, item)
+			}
+			asm.emit(byte(value), byte(value>>8))
+		}
+	}
+
+	return nil
+}
+
+// Emit a single instruction
+func (asm *assembler) emitInstruction(line *sourceLine) error {
+	mode, expression, err := asm.selectMode(line)
+	if err != nil {
+		return err
+	}
+
+	code, exist := LookupOpcode(line.operation, mode)
+	if !exist {
+		return fmt.Errorf("invalid addressing mode for '%s %s'", line.operation, line.operand)
+	}
+
+	if mode.OperandSize() == 0 {
+		asm.emit(code)
+		return nil
+	}
+
+	value, _, err := asm.evaluateFinal(line, expression)
+	if err != nil {
+		return err
+	}
+
+	switch mode {
+	case Relative:
+		offset := value - (asm.pc + 2)
+		if asm.pass == 2 && (offset < -128 || offset > 127) {
+			return fmt.Errorf("branch target $%04x out of range", value)
+		}
+		asm.emit(code, byte(offset))
+	case Immediate:
+		if value < -128 || value > 0xff {
+			return fmt.Errorf("immediate value %d doesn't fit in a byte", value)
+		}
+		asm.emit(code, byte(value))
+	case ZeroPage, ZeroPageX, ZeroPageY, IndexedIndirect, IndirectIndexed:
+		if value < 0 || value > 0xff {
+			return fmt.Errorf("zero page address $%04x out of range", value)
+		}
+		asm.emit(code, byte(value))
+	default:
+		if value < 0 || value > 0xffff {
+			return fmt.Errorf("address %d out of range", value)
+		}
+		asm.emit(code, byte(value), byte(value>>8))
+	}
+
+	return nil
+}
+
+// Select addressing mode from the operand syntax, zero page modes are chosen
+// in the first pass when the address is already known, the second pass reuses that choice
+func (asm *assembler) selectMode(line *sourceLine) (AddressingMode, string, error) {
+	operand := line.operand
+	mnemonic := line.operation
+
+	if operand == "" {
+		if _, exist := LookupOpcode(mnemonic, Implied); exist {
+			return Implied, "", nil
+		}
+		return Accumulator, "", nil
+	}
+	if strings.EqualFold(operand, "a") {
+		return Accumulator, "", nil
+	}
+	if strings.HasPrefix(operand, "#") {
+		return Immediate, operand[1:], nil
+	}
+	if match := indexedIndirectRegexp.FindStringSubmatch(operand); match != nil {
+		return IndexedIndirect, match[1], nil
+	}
+	if match := indirectIndexedRegexp.FindStringSubmatch(operand); match != nil {
+		return IndirectIndexed, match[1], nil
+	}
+	if match := indirectRegexp.FindStringSubmatch(operand); match != nil {
+		if _, exist := LookupOpcode(mnemonic, Indirect); !exist {
+			return 0, "", fmt.Errorf("invalid addressing mode for '%s %s'", mnemonic, operand)
+		}
+		return Indirect, match[1], nil
+	}
+	if _, exist := LookupOpcode(mnemonic, Relative); exist {
+		return Relative, operand, nil
+	}
+
+	zeroPageMode, absoluteMode, expression := ZeroPage, Absolute, operand
+	if match := indexedRegexp.FindStringSubmatch(operand); match != nil {
+		expression = match[1]
+		if indirectRegexp.MatchString(strings.TrimSpace(expression)) {
+			return 0, "", fmt.Errorf("invalid addressing mode for '%s %s'", mnemonic, operand)
+		}
+		if strings.EqualFold(match[2], "x") {
+			zeroPageMode, absoluteMode = ZeroPageX, AbsoluteX
+		} else {
+			zeroPageMode, absoluteMode = ZeroPageY, AbsoluteY
+		}
+	}
+
+	if asm.pass == 2 {
+		return line.mode, expression, nil
+	}
+
+	line.mode = absoluteMode
+	if _, exist := LookupOpcode(mnemonic, zeroPageMode); exist {
+		value, resolved, err := asm.evaluate(line, expression)
+		if err != nil {
+			return 0, "", err
+		}
+		if resolved && value >= 0 && value <= 0xff {
+			line.mode = zeroPageMode
+		}
+	}
+
+	return line.mode, expression, nil
+}
+
+// Remove comment from the line, ignores ';' inside strings and character literals
+func stripComment(text string) string {
+	inString := false
+	for idx := 0; idx < len(text); idx++ {
+		switch text[idx] {
+		case '"':
+			inString = !inString
+		case '\'':
+			if !inString && idx+2 < len(text) && text[idx+2] == '\'' {
+				idx += 2
+			}
+		case ';':
+			if !inString {
+				return text[:idx]
+			}
+		}
+	}
+	return text
+}
+
+// Split comma separated list, keeps strings intact
+func splitList(text string) ([]string, error) {
+	var items []string
+	inString := false
+	depth := 0
+	start := 0
+	for idx := 0; idx < len(text); idx++ {
+		switch text[idx] {
+		case '"':
+			inString = !inString
+		case '\'':
+			if !inString && idx+2 < len(text) && text[idx+2] == '\'' {
+				idx += 2
+			}
+		case '(':
+			if !inString {
+				depth++
+			}
+		case ')':
+			if !inString {
+				depth--
+			}
+		case ',':
+			if !inString && depth == 0 {
+				items = append(items, strings.TrimSpace(text[start:idx]))
+				start = idx + 1
+			}
+		}
+	}
+	if inString {
+		return nil, fmt.Errorf("unterminated string in '%s'", text)
+	}
+	if last := strings.TrimSpace(text[start:]); last != "" || len(items) > 0 {
+		items = append(items, last)
+	}
+	for _, item := range items {
+		if item == "" {
+			return nil, fmt.Errorf("empty item in '%s'", text)
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,136 @@
+package asm6502
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Returns label for the address or empty string, used to annotate disassembly
+type Labeler func(address uint16) string
+
+// Single disassembled instruction
+type Instruction struct {
+	Address uint16
+	Bytes   []byte // opcode and operand bytes
+	Opcode  Opcode
+	Operand uint16 // operand value, for branches it's the target address
+	Valid   bool   // false for undocumented opcodes and truncated instructions
+}
+
+// Disassemble single instruction from data placed at the address
+func Disassemble(address uint16, data []byte) Instruction {
+	if len(data) == 0 {
+		return Instruction{Address: address}
+	}
+
+	opcode := Opcodes[data[0]]
+	size := opcode.Size()
+	if opcode.Mnemonic == "" || len(data) < size {
+		return Instruction{
+			Address: address,
+			Bytes:   data[:1],
+		}
+	}
+
+	instruction := Instruction{
+		Address: address,
+		Bytes:   data[:size],
+		Opcode:  opcode,
+		Valid:   true,
+	}
+
+	switch size {
+	case 2:
+		instruction.Operand = uint16(data[1])
+	case 3:
+		instruction.Operand = uint16(data[1]) | uint16(data[2])<<8
+	}
+	if opcode.Mode == Relative {
+		instruction.Operand = address + 2 + uint16(int8(data[1]))
+	}
+
+	return instruction
+}
+
+// Disassemble all instructions from the data block
+func DisassembleBlock(address uint16, data []byte) []Instruction {
+	var instructions []Instruction
+	for offset := 0; offset < len(data); {
+		instruction := Disassemble(address+uint16(offset), data[offset:])
+		instructions = append(instructions, instruction)
+		offset += len(instruction.Bytes)
+	}
+	return instructions
+}
+
+// Instruction size in bytes
+func (ins Instruction) Size() int {
+	return len(ins.Bytes)
+}
+
+// Format instruction as assembler source, e.g. 'lda #$10'
+func (ins Instruction) String() string {
+	return ins.Format(nil)
+}
+
+// Format instruction, addresses are replaced with labels when labeler returns one
+func (ins Instruction) Format(labeler Labeler) string {
+	if !ins.Valid {
+		if len(ins.Bytes) == 0 {
+			return ""
+		}
+		return fmt.Sprintf(".byte $%02x", ins.Bytes[0])
+	}
+
+	address := func(width int) string {
+		if labeler != nil {
+			if label := labeler(ins.Operand); label != "" {
+				return label
+			}
+		}
+		if width == 2 {
+			return fmt.Sprintf("$%02x", ins.Operand)
+		}
+		return fmt.Sprintf("$%04x", ins.Operand)
+	}
+
+	operand := ""
+	switch ins.Opcode.Mode {
+	case Accumulator:
+		operand = "a"
+	case Immediate:
+		operand = fmt.Sprintf("#$%02x", ins.Operand)
+	case ZeroPage:
+		operand = address(2)
+	case ZeroPageX:
+		operand = address(2) + ",x"
+	case ZeroPageY:
+		operand = address(2) + ",y"
+	case Absolute, Relative:
+		operand = address(4)
+	case AbsoluteX:
+		operand = address(4) + ",x"
+	case AbsoluteY:
+		operand = address(4) + ",y"
+	case Indirect:
+		operand = "(" + address(4) + ")"
+	case IndexedIndirect:
+		operand = "(" + address(2) + ",x)"
+	case IndirectIndexed:
+		operand = "(" + address(2) + "),y"
+	}
+
+	if operand == "" {
+		return ins.Opcode.Mnemonic
+	}
+	return ins.Opcode.Mnemonic + " " + operand
+}
+
+// Format instruction bytes as hex, e.g. 'a9 10'
+func (ins Instruction) HexBytes() string {
+	parts := make([]string, len(ins.Bytes))
+	for idx, value := range ins.Bytes {
+		parts[idx] = fmt.Sprintf("%02x", value)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,308 @@
+package asm6502
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// --------------------------------------------------------------
+// Expression evaluator
+//
+// Supported syntax:
+//   - numbers: 123, $7f, 0x7f, %0101, 'a'
+//   - symbols: label, @local, * (current program counter)
+//   - unary operators: - ~ < (low byte) > (high byte)
+//   - binary operators: * / % + - << >> & ^ |
+//   - grouping with parentheses
+//
+// --------------------------------------------------------------
+
+// Resolves symbol names, returns false if the symbol is not (yet) defined
+type symbolResolver func(name string) (int, bool)
+
+type expressionParser struct {
+	src      string
+	pos      int
+	pc       int
+	resolve  symbolResolver
+	resolved bool // false when at least one symbol was undefined
+}
+
+// Evaluate the expression, the second return value is false when the
+// expression references undefined symbols
+func evaluate(src string, pc int, resolve symbolResolver) (int, bool, error) {
+	parser := &expressionParser{
+		src:      src,
+		pc:       pc,
+		resolve:  resolve,
+		resolved: true,
+	}
+
+	value, err := parser.parseOr()
+	if err != nil {
+		return 0, false, err
+	}
+
+	parser.skipSpaces()
+	if parser.pos < len(parser.src) {
+		return 0, false, fmt.Errorf("unexpected '%s' in expression '%s'", parser.src[parser.pos:], src)
+	}
+
+	return value, parser.resolved, nil
+}
+
+func (p *expressionParser) skipSpaces() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// Consume the operator if it's next in the input
+func (p *expressionParser) accept(operator string) bool {
+	p.skipSpaces()
+	if strings.HasPrefix(p.src[p.pos:], operator) {
+		p.pos += len(operator)
+		return true
+	}
+	return false
+}
+
+func (p *expressionParser) parseOr() (int, error) {
+	left, err := p.parseXor()
+	if err != nil {
+		return 0, err
+	}
+	for p.accept("|") {
+		right, err := p.parseXor()
+		if err != nil {
+			return 0, err
+		}
+		left |= right
+	}
+	return left, nil
+}
+
+func (p *expressionParser) parseXor() (int, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return 0, err
+	}
+	for p.accept("^") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return 0, err
+		}
+		left ^= right
+	}
+	return left, nil
+}
+
+func (p *expressionParser) parseAnd() (int, error) {
+	left, err := p.parseShift()
+	if err != nil {
+		return 0, err
+	}
+	for p.accept("&") {
+		right, err := p.parseShift()
+		if err != nil {
+			return 0, err
+		}
+		left &= right
+	}
+	return left, nil
+}
+
+func (p *expressionParser) parseShift() (int, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch {
+		case p.accept("<<"):
+			right, err := p.parseSum()
+			if err != nil {
+				return 0, err
+			}
+			left <<= right
+		case p.accept(">>"):
+			right, err := p.parseSum()
+			if err != nil {
+				return 0, err
+			}
+			left >>= right
+		default:
+			return left, nil
+		}
+	}
+}
+
+func (p *expressionParser) parseSum() (int, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch {
+		case p.accept("+"):
+			right, err := p.parseProduct()
+			if err != nil {
+				return 0, err
+			}
+			left += right
+		case p.accept("-"):
+			right, err := p.parseProduct()
+			if err != nil {
+				return 0, err
+			}
+			left -= right
+		default:
+			return left, nil
+		}
+	}
+}
+
+func (p *expressionParser) parseProduct() (int, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch {
+		case p.accept("*"):
+			right, err := p.parseUnary()
+			if err != nil {
+				return 0, err
+			}
+			left *= right
+		case p.accept("/"), p.accept("%"):
+			operator := p.src[p.pos-1]
+			right, err := p.parseUnary()
+			if err != nil {
+				return 0, err
+			}
+			if right == 0 {
+				if !p.resolved {
+					// undefined symbols evaluate to 0 in the first pass
+					left = 0
+					continue
+				}
+				return 0, fmt.Errorf("division by zero in expression '%s'", p.src)
+			}
+			if operator == '/' {
+				left /= right
+			} else {
+				left %= right
+			}
+		default:
+			return left, nil
+		}
+	}
+}
+
+func (p *expressionParser) parseUnary() (int, error) {
+	switch {
+	case p.accept("-"):
+		value, err := p.parseUnary()
+		return -value, err
+	case p.accept("~"):
+		value, err := p.parseUnary()
+		return ^value, err
+	case p.accept("<"):
+		value, err := p.parseUnary()
+		return value & 0xff, err
+	case p.accept(">"):
+		value, err := p.parseUnary()
+		return (value >> 8) & 0xff, err
+	}
+	return p.parsePrimary()
+}
+
+func (p *expressionParser) parsePrimary() (int, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.src) {
+		return 0, fmt.Errorf("unexpected end of expression '%s'", p.src)
+	}
+
+	ch := p.src[p.pos]
+	switch {
+	case ch == '(':
+		p.pos++
+		value, err := p.parseOr()
+		if err != nil {
+			return 0, err
+		}
+		if !p.accept(")") {
+			return 0, fmt.Errorf("missing ')' in expression '%s'", p.src)
+		}
+		return value, nil
+	case ch == '*':
+		p.pos++
+		return p.pc, nil
+	case ch == '\'':
+		if p.pos+2 >= len(p.src) || p.src[p.pos+2] != '\'' {
+			return 0, fmt.Errorf("invalid character literal in expression '%s'", p.src)
+		}
+		value := int(p.src[p.pos+1])
+		p.pos += 3
+		return value, nil
+	case ch == '$':
+		p.pos++
+		return p.parseNumber(16)
+	case ch == '%':
+		p.pos++
+		return p.parseNumber(2)
+	case ch == '0' && p.pos+1 < len(p.src) && (p.src[p.pos+1] == 'x' || p.src[p.pos+1] == 'X'):
+		p.pos += 2
+		return p.parseNumber(16)
+	case isDigit(ch):
+		return p.parseNumber(10)
+	case isIdentifierStart(ch):
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.src) && isIdentifierChar(p.src[p.pos]) {
+			p.pos++
+		}
+		name := p.src[start:p.pos]
+		value, exist := p.resolve(name)
+		if !exist {
+			p.resolved = false
+			return 0, nil
+		}
+		return value, nil
+	}
+
+	return 0, fmt.Errorf("unexpected '%c' in expression '%s'", ch, p.src)
+}
+
+func (p *expressionParser) parseNumber(base int) (int, error) {
+	start := p.pos
+	for p.pos < len(p.src) && isHexDigit(p.src[p.pos]) {
+		p.pos++
+	}
+	if start == p.pos {
+		return 0, fmt.Errorf("missing number in expression '%s'", p.src)
+	}
+	value, err := strconv.ParseInt(p.src[start:p.pos], base, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number '%s' in expression '%s'", p.src[start:p.pos], p.src)
+	}
+	return int(value), nil
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isHexDigit(ch byte) bool {
+	return isDigit(ch) || (ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F')
+}
+
+func isIdentifierStart(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '_' || ch == '@'
+}
+
+func isIdentifierChar(ch byte) bool {
+	return isIdentifierStart(ch) || isDigit(ch) || ch == '.'
+}
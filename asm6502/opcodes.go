@@ -0,0 +1,153 @@
+package asm6502
+
+// Addressing modes of the 6502 CPU
+type AddressingMode int
+
+const (
+	Implied         AddressingMode = iota // rts
+	Accumulator                           // asl a
+	Immediate                             // lda #$00
+	ZeroPage                              // lda $fb
+	ZeroPageX                             // lda $fb,x
+	ZeroPageY                             // ldx $fb,y
+	Absolute                              // lda $1000
+	AbsoluteX                             // lda $1000,x
+	AbsoluteY                             // lda $1000,y
+	Indirect                              // jmp ($fffe)
+	IndexedIndirect                       // lda ($fb,x)
+	IndirectIndexed                       // lda ($fb),y
+	Relative                              // bne label
+)
+
+// Number of operand bytes used by the addressing mode
+func (mode AddressingMode) OperandSize() int {
+	switch mode {
+	case Implied, Accumulator:
+		return 0
+	case Absolute, AbsoluteX, AbsoluteY, Indirect:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// Single opcode description
+type Opcode struct {
+	Mnemonic string
+	Mode     AddressingMode
+	Cycles   int // base cycles, without page crossing and branch penalties
+}
+
+// Instruction length in bytes (opcode + operand)
+func (op Opcode) Size() int {
+	return 1 + op.Mode.OperandSize()
+}
+
+// Documented 6502 opcodes indexed by opcode byte, undocumented ones are left empty
+var Opcodes [256]Opcode
+
+// Mnemonic -> addressing mode -> opcode byte
+var opcodesByMnemonic = map[string]map[AddressingMode]byte{}
+
+type opcodeDef struct {
+	code   byte
+	mode   AddressingMode
+	cycles int
+}
+
+// --------------------------------------------------------------
+// Documented opcodes grouped by mnemonic
+// --------------------------------------------------------------
+var opcodeDefs = map[string][]opcodeDef{
+	"adc": {{0x69, Immediate, 2}, {0x65, ZeroPage, 3}, {0x75, ZeroPageX, 4}, {0x6d, Absolute, 4}, {0x7d, AbsoluteX, 4}, {0x79, AbsoluteY, 4}, {0x61, IndexedIndirect, 6}, {0x71, IndirectIndexed, 5}},
+	"and": {{0x29, Immediate, 2}, {0x25, ZeroPage, 3}, {0x35, ZeroPageX, 4}, {0x2d, Absolute, 4}, {0x3d, AbsoluteX, 4}, {0x39, AbsoluteY, 4}, {0x21, IndexedIndirect, 6}, {0x31, IndirectIndexed, 5}},
+	"asl": {{0x0a, Accumulator, 2}, {0x06, ZeroPage, 5}, {0x16, ZeroPageX, 6}, {0x0e, Absolute, 6}, {0x1e, AbsoluteX, 7}},
+	"bcc": {{0x90, Relative, 2}},
+	"bcs": {{0xb0, Relative, 2}},
+	"beq": {{0xf0, Relative, 2}},
+	"bit": {{0x24, ZeroPage, 3}, {0x2c, Absolute, 4}},
+	"bmi": {{0x30, Relative, 2}},
+	"bne": {{0xd0, Relative, 2}},
+	"bpl": {{0x10, Relative, 2}},
+	"brk": {{0x00, Implied, 7}},
+	"bvc": {{0x50, Relative, 2}},
+	"bvs": {{0x70, Relative, 2}},
+	"clc": {{0x18, Implied, 2}},
+	"cld": {{0xd8, Implied, 2}},
+	"cli": {{0x58, Implied, 2}},
+	"clv": {{0xb8, Implied, 2}},
+	"cmp": {{0xc9, Immediate, 2}, {0xc5, ZeroPage, 3}, {0xd5, ZeroPageX, 4}, {0xcd, Absolute, 4}, {0xdd, AbsoluteX, 4}, {0xd9, AbsoluteY, 4}, {0xc1, IndexedIndirect, 6}, {0xd1, IndirectIndexed, 5}},
+	"cpx": {{0xe0, Immediate, 2}, {0xe4, ZeroPage, 3}, {0xec, Absolute, 4}},
+	"cpy": {{0xc0, Immediate, 2}, {0xc4, ZeroPage, 3}, {0xcc, Absolute, 4}},
+	"dec": {{0xc6, ZeroPage, 5}, {0xd6, ZeroPageX, 6}, {0xce, Absolute, 6}, {0xde, AbsoluteX, 7}},
+	"dex": {{0xca, Implied, 2}},
+	"dey": {{0x88, Implied, 2}},
+	"eor": {{0x49, Immediate, 2}, {0x45, ZeroPage, 3}, {0x55, ZeroPageX, 4}, {0x4d, Absolute, 4}, {0x5d, AbsoluteX, 4}, {0x59, AbsoluteY, 4}, {0x41, IndexedIndirect, 6}, {0x51, IndirectIndexed, 5}},
+	"inc": {{0xe6, ZeroPage, 5}, {0xf6, ZeroPageX, 6}, {0xee, Absolute, 6}, {0xfe, AbsoluteX, 7}},
+	"inx": {{0xe8, Implied, 2}},
+	"iny": {{0xc8, Implied, 2}},
+	"jmp": {{0x4c, Absolute, 3}, {0x6c, Indirect, 5}},
+	"jsr": {{0x20, Absolute, 6}},
+	"lda": {{0xa9, Immediate, 2}, {0xa5, ZeroPage, 3}, {0xb5, ZeroPageX, 4}, {0xad, Absolute, 4}, {0xbd, AbsoluteX, 4}, {0xb9, AbsoluteY, 4}, {0xa1, IndexedIndirect, 6}, {0xb1, IndirectIndexed, 5}},
+	"ldx": {{0xa2, Immediate, 2}, {0xa6, ZeroPage, 3}, {0xb6, ZeroPageY, 4}, {0xae, Absolute, 4}, {0xbe, AbsoluteY, 4}},
+	"ldy": {{0xa0, Immediate, 2}, {0xa4, ZeroPage, 3}, {0xb4, ZeroPageX, 4}, {0xac, Absolute, 4}, {0xbc, AbsoluteX, 4}},
+	"lsr": {{0x4a, Accumulator, 2}, {0x46, ZeroPage, 5}, {0x56, ZeroPageX, 6}, {0x4e, Absolute, 6}, {0x5e, AbsoluteX, 7}},
+	"nop": {{0xea, Implied, 2}},
+	"ora": {{0x09, Immediate, 2}, {0x05, ZeroPage, 3}, {0x15, ZeroPageX, 4}, {0x0d, Absolute, 4}, {0x1d, AbsoluteX, 4}, {0x19, AbsoluteY, 4}, {0x01, IndexedIndirect, 6}, {0x11, IndirectIndexed, 5}},
+	"pha": {{0x48, Implied, 3}},
+	"php": {{0x08, Implied, 3}},
+	"pla": {{0x68, Implied, 4}},
+	"plp": {{0x28, Implied, 4}},
+	"rol": {{0x2a, Accumulator, 2}, {0x26, ZeroPage, 5}, {0x36, ZeroPageX, 6}, {0x2e, Absolute, 6}, {0x3e, AbsoluteX, 7}},
+	"ror": {{0x6a, Accumulator, 2}, {0x66, ZeroPage, 5}, {0x76, ZeroPageX, 6}, {0x6e, Absolute, 6}, {0x7e, AbsoluteX, 7}},
+	"rti": {{0x40, Implied, 6}},
+	"rts": {{0x60, Implied, 6}},
+	"sbc": {{0xe9, Immediate, 2}, {0xe5, ZeroPage, 3}, {0xf5, ZeroPageX, 4}, {0xed, Absolute, 4}, {0xfd, AbsoluteX, 4}, {0xf9, AbsoluteY, 4}, {0xe1, IndexedIndirect, 6}, {0xf1, IndirectIndexed, 5}},
+	"sec": {{0x38, Implied, 2}},
+	"sed": {{0xf8, Implied, 2}},
+	"sei": {{0x78, Implied, 2}},
+	"sta": {{0x85, ZeroPage, 3}, {0x95, ZeroPageX, 4}, {0x8d, Absolute, 4}, {0x9d, AbsoluteX, 5}, {0x99, AbsoluteY, 5}, {0x81, IndexedIndirect, 6}, {0x91, IndirectIndexed, 6}},
+	"stx": {{0x86, ZeroPage, 3}, {0x96, ZeroPageY, 4}, {0x8e, Absolute, 4}},
+	"sty": {{0x84, ZeroPage, 3}, {0x94, ZeroPageX, 4}, {0x8c, Absolute, 4}},
+	"tax": {{0xaa, Implied, 2}},
+	"tay": {{0xa8, Implied, 2}},
+	"tsx": {{0xba, Implied, 2}},
+	"txa": {{0x8a, Implied, 2}},
+	"txs": {{0x9a, Implied, 2}},
+	"tya": {{0x98, Implied, 2}},
+}
+
+func init() {
+	for mnemonic, defs := range opcodeDefs {
+		opcodesByMnemonic[mnemonic] = map[AddressingMode]byte{}
+		for _, def := range defs {
+			Opcodes[def.code] = Opcode{
+				Mnemonic: mnemonic,
+				Mode:     def.mode,
+				Cycles:   def.cycles,
+			}
+			opcodesByMnemonic[mnemonic][def.mode] = def.code
+		}
+	}
+}
+
+// Check if the opcode byte is a documented instruction
+func IsDocumented(code byte) bool {
+	return Opcodes[code].Mnemonic != ""
+}
+
+// Find opcode byte for the mnemonic and addressing mode
+func LookupOpcode(mnemonic string, mode AddressingMode) (byte, bool) {
+	modes, exist := opcodesByMnemonic[mnemonic]
+	if !exist {
+		return 0, false
+	}
+	code, exist := modes[mode]
+	return code, exist
+}
+
+// Check if the mnemonic is a known instruction
+func IsMnemonic(mnemonic string) bool {
+	_, exist := opcodesByMnemonic[mnemonic]
+	return exist
+}
@@ -0,0 +1,573 @@
+package binmon
+
+import (
+	"encoding/binary"
+	"sort"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+)
+
+// Memory banks of the main memory space
+var banks = []struct {
+	id    uint16
+	name  string
+	space c64dws.MemorySpace
+}{
+	{0, "default", c64dws.MemorySpaceCPU},
+	{1, "cpu", c64dws.MemorySpaceCPU},
+	{2, "ram", c64dws.MemorySpaceRAM},
+}
+
+// Registers of the main CPU: ID, bits, name
+var registers = []struct {
+	id   byte
+	bits byte
+	name string
+}{
+	{RegisterA, 8, "A"},
+	{RegisterX, 8, "X"},
+	{RegisterY, 8, "Y"},
+	{RegisterPC, 16, "PC"},
+	{RegisterSP, 8, "SP"},
+	{RegisterFlags, 8, "FL"},
+	{RegisterRasterLine, 16, "LIN"},
+	{RegisterCycle, 16, "CYC"},
+	{Register00, 8, "00"},
+	{Register01, 8, "01"},
+}
+
+// Map memspace and bank to memory space
+func memorySpace(memspace byte, bank uint16) (c64dws.MemorySpace, error) {
+	switch memspace {
+	case MemspaceMain:
+		for _, b := range banks {
+			if b.id == bank {
+				return b.space, nil
+			}
+		}
+		return 0, newCommandError(ErrorInvalidParameter, "Unknown bank %d", bank)
+	case MemspaceDrive8:
+		return c64dws.MemorySpaceDrive1541CPU, nil
+	default:
+		return 0, newCommandError(ErrorInvalidMemspace, "Unsupported memspace %d", memspace)
+	}
+}
+
+// ----------------------------------------------------------------------
+// Memory
+// ----------------------------------------------------------------------
+
+// Decode memory command header: side effects (1), start (2), end (2), memspace (1), bank (2)
+func decodeMemoryRange(command Command) (c64dws.MemorySpace, uint16, int, error) {
+	if err := checkLength(command, 8); err != nil {
+		return 0, 0, 0, err
+	}
+	body := command.Body
+	start := binary.LittleEndian.Uint16(body[1:])
+	end := binary.LittleEndian.Uint16(body[3:])
+	if end < start {
+		return 0, 0, 0, newCommandError(ErrorInvalidParameter, "Invalid memory range $%04x-$%04x", start, end)
+	}
+	space, err := memorySpace(body[5], binary.LittleEndian.Uint16(body[6:]))
+	return space, start, int(end-start) + 1, err
+}
+
+func (s *Server) memoryGet(command Command) ([]byte, error) {
+	space, start, size, err := decodeMemoryRange(command)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.client.ReadMemory(space, start, size)
+	if err != nil {
+		return nil, err
+	}
+	// length 0 means 64KB
+	return append(binary.LittleEndian.AppendUint16(nil, uint16(len(data))), data...), nil
+}
+
+func (s *Server) memorySet(command Command) ([]byte, error) {
+	space, start, size, err := decodeMemoryRange(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(command.Body)-8 != size {
+		return nil, newCommandError(ErrorInvalidLength, "Memory set data length %d doesn't match the range", len(command.Body)-8)
+	}
+	return nil, s.client.WriteMemory(space, start, command.Body[8:])
+}
+
+// ----------------------------------------------------------------------
+// Checkpoints
+// ----------------------------------------------------------------------
+
+// Checkpoint info body
+func (c *checkpoint) info(currentlyHit bool) []byte {
+	body := binary.LittleEndian.AppendUint32(nil, c.number)
+	body = append(body, boolByte(currentlyHit))
+	body = binary.LittleEndian.AppendUint16(body, c.start)
+	body = binary.LittleEndian.AppendUint16(body, c.end)
+	body = append(body, boolByte(c.stop), boolByte(c.enabled), c.operation, boolByte(c.temporary))
+	body = binary.LittleEndian.AppendUint32(body, c.hitCount)
+	body = binary.LittleEndian.AppendUint32(body, 0) // ignore count
+	return append(body, 0, c.memspace)               // has condition, memspace
+}
+
+func boolByte(value bool) byte {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// Find checkpoint by the number in the command body
+func (s *Server) commandCheckpoint(command Command) (*checkpoint, error) {
+	if err := checkLength(command, 4); err != nil {
+		return nil, err
+	}
+	number := binary.LittleEndian.Uint32(command.Body)
+	checkpoint, exist := s.checkpoints[number]
+	if !exist {
+		return nil, newCommandError(ErrorObjectMissing, "Checkpoint %d doesn't exist", number)
+	}
+	return checkpoint, nil
+}
+
+func (s *Server) checkpointGet(command Command) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	checkpoint, err := s.commandCheckpoint(command)
+	if err != nil {
+		return nil, err
+	}
+	return checkpoint.info(false), nil
+}
+
+// Body: start (2), end (2), stop when hit (1), enabled (1), operation (1), temporary (1), [memspace (1)]
+func (s *Server) checkpointSet(command Command) ([]byte, error) {
+	if err := checkLength(command, 8); err != nil {
+		return nil, err
+	}
+	body := command.Body
+	checkpoint := &checkpoint{
+		start:     binary.LittleEndian.Uint16(body[0:]),
+		end:       binary.LittleEndian.Uint16(body[2:]),
+		stop:      body[4] != 0,
+		enabled:   body[5] != 0,
+		operation: body[6],
+		temporary: body[7] != 0,
+	}
+	if len(body) > 8 {
+		checkpoint.memspace = body[8]
+	}
+	if checkpoint.memspace != MemspaceMain {
+		return nil, newCommandError(ErrorInvalidMemspace, "Checkpoints are supported only in the main memspace")
+	}
+	if checkpoint.end < checkpoint.start || int(checkpoint.end-checkpoint.start) >= MaxCheckpointRange {
+		return nil, newCommandError(ErrorInvalidParameter, "Checkpoint range $%04x-$%04x too large", checkpoint.start, checkpoint.end)
+	}
+	if checkpoint.operation&(OperationLoad|OperationStore|OperationExec) == 0 {
+		return nil, newCommandError(ErrorInvalidParameter, "Invalid checkpoint operation %d", checkpoint.operation)
+	}
+
+	s.mutex.Lock()
+	checkpoint.number = s.nextCheckpoint
+	s.nextCheckpoint++
+	s.checkpoints[checkpoint.number] = checkpoint
+	s.mutex.Unlock()
+
+	if err := s.syncBreakpoints(); err != nil {
+		return nil, err
+	}
+	return checkpoint.info(false), nil
+}
+
+func (s *Server) checkpointDelete(command Command) ([]byte, error) {
+	s.mutex.Lock()
+	checkpoint, err := s.commandCheckpoint(command)
+	if err == nil {
+		delete(s.checkpoints, checkpoint.number)
+	}
+	s.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.syncBreakpoints()
+}
+
+// Checkpoints are sent as separate checkpoint info responses followed by the count
+func (s *Server) checkpointList(command Command) ([]byte, error) {
+	s.mutex.Lock()
+	checkpoints := s.sortedCheckpoints()
+	infos := make([][]byte, 0, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		infos = append(infos, checkpoint.info(false))
+	}
+	s.mutex.Unlock()
+
+	for _, info := range infos {
+		s.send(Response{Type: CommandCheckpointGet, RequestID: command.RequestID, Body: info})
+	}
+	return binary.LittleEndian.AppendUint32(nil, uint32(len(infos))), nil
+}
+
+// Body: number (4), enabled (1)
+func (s *Server) checkpointToggle(command Command) ([]byte, error) {
+	if err := checkLength(command, 5); err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	checkpoint, err := s.commandCheckpoint(command)
+	if err == nil {
+		checkpoint.enabled = command.Body[4] != 0
+	}
+	s.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.syncBreakpoints()
+}
+
+// Add and remove emulator breakpoints so they match enabled checkpoints
+func (s *Server) syncBreakpoints() error {
+	s.mutex.Lock()
+	wantedExec := map[uint16]bool{}
+	wantedMemory := map[uint16]c64dws.MemoryBreakpointAccess{}
+	for _, checkpoint := range s.checkpoints {
+		if !checkpoint.enabled {
+			continue
+		}
+		for address := int(checkpoint.start); address <= int(checkpoint.end); address++ {
+			if checkpoint.operation&OperationExec != 0 {
+				wantedExec[uint16(address)] = true
+			}
+			// single memory breakpoint per address, store takes precedence
+			if checkpoint.operation&OperationStore != 0 {
+				wantedMemory[uint16(address)] = c64dws.MemoryBreakpointAccessWrite
+			} else if checkpoint.operation&OperationLoad != 0 && wantedMemory[uint16(address)] == "" {
+				wantedMemory[uint16(address)] = c64dws.MemoryBreakpointAccessRead
+			}
+		}
+	}
+
+	var addExec, removeExec, addMemory, removeMemory []uint16
+	for address := range wantedExec {
+		if !s.activeExec[address] {
+			addExec = append(addExec, address)
+		}
+	}
+	for address := range s.activeExec {
+		if !wantedExec[address] {
+			removeExec = append(removeExec, address)
+		}
+	}
+	for address, access := range wantedMemory {
+		if active, exist := s.activeMemory[address]; !exist || active != access {
+			addMemory = append(addMemory, address)
+		}
+	}
+	for address, access := range s.activeMemory {
+		if wantedMemory[address] != access {
+			removeMemory = append(removeMemory, address)
+		}
+	}
+	s.mutex.Unlock()
+
+	for _, addresses := range [][]uint16{addExec, removeExec, addMemory, removeMemory} {
+		sort.Slice(addresses, func(i, j int) bool { return addresses[i] < addresses[j] })
+	}
+
+	for _, address := range removeExec {
+		if err := s.request(func(token string) error {
+			return s.client.RemoveCPUBreakpoint(address, token)
+		}); err != nil {
+			return err
+		}
+		s.mutex.Lock()
+		delete(s.activeExec, address)
+		s.mutex.Unlock()
+	}
+	for _, address := range removeMemory {
+		if err := s.request(func(token string) error {
+			return s.client.RemoveCPUMemoryBreakpoint(address, 0, token)
+		}); err != nil {
+			return err
+		}
+		s.mutex.Lock()
+		delete(s.activeMemory, address)
+		s.mutex.Unlock()
+	}
+	for _, address := range addExec {
+		if err := s.request(func(token string) error {
+			return s.client.AddCPUBreakpoint(address, token)
+		}); err != nil {
+			return err
+		}
+		s.mutex.Lock()
+		s.activeExec[address] = true
+		s.mutex.Unlock()
+	}
+	for _, address := range addMemory {
+		access := wantedMemory[address]
+		// value 0 with '>=' matches any value
+		if err := s.request(func(token string) error {
+			return s.client.AddCPUMemoryBreakpoint(address, 0, access, ">=", token)
+		}); err != nil {
+			return err
+		}
+		s.mutex.Lock()
+		s.activeMemory[address] = access
+		s.mutex.Unlock()
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------
+// Registers
+// ----------------------------------------------------------------------
+
+// Registers body: count (2), items: size (1), register ID (1), value (2)
+func (s *Server) registersBody(state c64dws.CPUState) ([]byte, error) {
+	zeroPage, err := s.client.ReadMemory(c64dws.MemorySpaceRAM, 0x0000, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[byte]uint16{
+		RegisterA:          uint16(state.A),
+		RegisterX:          uint16(state.X),
+		RegisterY:          uint16(state.Y),
+		RegisterPC:         state.PC,
+		RegisterSP:         uint16(state.SP),
+		RegisterFlags:      uint16(state.P),
+		RegisterRasterLine: state.RasterY,
+		RegisterCycle:      uint16(state.RasterCycle),
+		Register00:         uint16(zeroPage[0]),
+		Register01:         uint16(state.Memory0001),
+	}
+	body := binary.LittleEndian.AppendUint16(nil, uint16(len(registers)))
+	for _, register := range registers {
+		body = append(body, 3, register.id)
+		body = binary.LittleEndian.AppendUint16(body, values[register.id])
+	}
+	return body, nil
+}
+
+func (s *Server) registersGet(command Command) ([]byte, error) {
+	if err := checkLength(command, 1); err != nil {
+		return nil, err
+	}
+	if command.Body[0] != MemspaceMain {
+		return nil, newCommandError(ErrorInvalidMemspace, "Registers are supported only in the main memspace")
+	}
+	state, err := s.client.ReadCPUStatus()
+	if err != nil {
+		return nil, err
+	}
+	return s.registersBody(state)
+}
+
+// Body: memspace (1), count (2), items: size (1), register ID (1), value (2)
+func (s *Server) registersSet(command Command) ([]byte, error) {
+	if err := checkLength(command, 3); err != nil {
+		return nil, err
+	}
+	body := command.Body
+	if body[0] != MemspaceMain {
+		return nil, newCommandError(ErrorInvalidMemspace, "Registers are supported only in the main memspace")
+	}
+
+	state, err := s.client.ReadCPUStatus()
+	if err != nil {
+		return nil, err
+	}
+	changed := false
+	count := int(binary.LittleEndian.Uint16(body[1:]))
+	offset := 3
+	for item := 0; item < count; item++ {
+		if offset >= len(body) || offset+1+int(body[offset]) > len(body) || body[offset] < 3 {
+			return nil, newCommandError(ErrorInvalidLength, "Registers set body too short")
+		}
+		id, value := body[offset+1], binary.LittleEndian.Uint16(body[offset+2:])
+		offset += 1 + int(body[offset])
+
+		switch id {
+		case RegisterA:
+			state.A = uint8(value)
+		case RegisterX:
+			state.X = uint8(value)
+		case RegisterY:
+			state.Y = uint8(value)
+		case RegisterPC:
+			state.PC = value
+		case RegisterSP:
+			state.SP = uint8(value)
+		case RegisterFlags:
+			state.P = uint8(value)
+		case Register00, Register01:
+			if err := s.client.WriteMemory(c64dws.MemorySpaceCPU, uint16(id-Register00), []byte{uint8(value)}); err != nil {
+				return nil, err
+			}
+			continue
+		default:
+			return nil, newCommandError(ErrorInvalidParameter, "Register $%02x is read only", id)
+		}
+		changed = true
+	}
+
+	if changed {
+		if err := s.client.SetRegisters(state); err != nil {
+			return nil, err
+		}
+	}
+	if state, err = s.client.ReadCPUStatus(); err != nil {
+		return nil, err
+	}
+	return s.registersBody(state)
+}
+
+// Body: memspace (1), response: count (2), items: size (1), register ID (1), bits (1), name length (1), name
+func (s *Server) registersAvailable(command Command) ([]byte, error) {
+	if err := checkLength(command, 1); err != nil {
+		return nil, err
+	}
+	if command.Body[0] != MemspaceMain {
+		return nil, newCommandError(ErrorInvalidMemspace, "Registers are supported only in the main memspace")
+	}
+
+	body := binary.LittleEndian.AppendUint16(nil, uint16(len(registers)))
+	for _, register := range registers {
+		body = append(body, byte(3+len(register.name)), register.id, register.bits, byte(len(register.name)))
+		body = append(body, register.name...)
+	}
+	return body, nil
+}
+
+// Response: count (2), items: size (1), bank ID (2), name length (1), name
+func (s *Server) banksAvailable(command Command) ([]byte, error) {
+	body := binary.LittleEndian.AppendUint16(nil, uint16(len(banks)))
+	for _, bank := range banks {
+		body = append(body, byte(3+len(bank.name)))
+		body = binary.LittleEndian.AppendUint16(body, bank.id)
+		body = append(body, byte(len(bank.name)))
+		body = append(body, bank.name...)
+	}
+	return body, nil
+}
+
+// ----------------------------------------------------------------------
+// Execution
+// ----------------------------------------------------------------------
+
+// Body: step over subroutines (1), count (2)
+func (s *Server) advanceInstructions(command Command) ([]byte, error) {
+	if err := checkLength(command, 3); err != nil {
+		return nil, err
+	}
+	stepOver := command.Body[0] != 0
+	count := int(binary.LittleEndian.Uint16(command.Body[1:]))
+
+	for step := 0; step < max(count, 1); step++ {
+		if err := s.request(func(token string) error {
+			if stepOver {
+				return s.client.StepSubroutine(token)
+			}
+			return s.client.StepInstruction(token)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.queueStoppedEvents(); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// Queue registers and stopped events
+func (s *Server) queueStoppedEvents() error {
+	state, err := s.client.ReadCPUStatus()
+	if err != nil {
+		return err
+	}
+	registersBody, err := s.registersBody(state)
+	if err != nil {
+		return err
+	}
+	s.queueEvent(CommandRegistersGet, registersBody)
+	s.queueEvent(ResponseStopped, binary.LittleEndian.AppendUint16(nil, state.PC))
+	return nil
+}
+
+func (s *Server) exit(command Command) ([]byte, error) {
+	if err := s.resume(); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// Continue emulation and queue resumed event
+func (s *Server) resume() error {
+	state, err := s.client.ReadCPUStatus()
+	if err != nil {
+		return err
+	}
+	if err := s.request(func(token string) error {
+		return s.client.ContinueEmulation(token)
+	}); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.running = true
+	s.mutex.Unlock()
+	s.queueEvent(ResponseResumed, binary.LittleEndian.AppendUint16(nil, state.PC))
+	return nil
+}
+
+// Body: reset type (1): 0 soft, 1 hard
+func (s *Server) reset(command Command) ([]byte, error) {
+	if err := checkLength(command, 1); err != nil {
+		return nil, err
+	}
+	switch command.Body[0] {
+	case 0:
+		return nil, s.request(func(token string) error {
+			return s.client.SoftReset(token)
+		})
+	case 1:
+		return nil, s.request(func(token string) error {
+			return s.client.HardReset(token)
+		})
+	default:
+		return nil, newCommandError(ErrorInvalidParameter, "Unsupported reset type %d", command.Body[0])
+	}
+}
+
+// Body: run after loading (1), file index (2), file name length (1), file name.
+// Retro Debugger always starts the loaded file, so the run flag and file index are ignored.
+func (s *Server) autostart(command Command) ([]byte, error) {
+	if err := checkLength(command, 4); err != nil {
+		return nil, err
+	}
+	nameLength := int(command.Body[3])
+	if err := checkLength(command, 4+nameLength); err != nil {
+		return nil, err
+	}
+	path := string(command.Body[4 : 4+nameLength])
+
+	if err := s.request(func(token string) error {
+		return s.client.LoadFile(path, token)
+	}); err != nil {
+		return nil, err
+	}
+	return nil, s.resume()
+}
+
+func (s *Server) ping(command Command) ([]byte, error) {
+	return nil, nil
+}
+
+// Response: version length (1), version (4), SVN revision length (1), SVN revision (4)
+func (s *Server) info(command Command) ([]byte, error) {
+	return []byte{4, 3, 7, 0, 0, 4, 0, 0, 0, 0}, nil
+}
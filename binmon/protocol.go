@@ -0,0 +1,178 @@
+package binmon
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Start of every command and response
+const stx = 0x02
+
+// Supported protocol version
+const APIVersion = 0x02
+
+// Returned by ReadCommand when the command uses other API version, the command is read completely
+var ErrUnsupportedVersion = errors.New("Unsupported API version")
+
+// Maximum command body length, the largest command is memory set of the whole 64KB
+const MaxCommandBodyLength = 8 + 0x10000
+
+// Returned by ReadCommand when the body length exceeds MaxCommandBodyLength, the body
+// is not read, so the command stream can't be continued
+var ErrCommandTooLarge = errors.New("Command too large")
+
+// Request ID of responses sent without a request (events)
+const EventRequestID uint32 = 0xffffffff
+
+// Command types
+const (
+	CommandMemoryGet           byte = 0x01
+	CommandMemorySet           byte = 0x02
+	CommandCheckpointGet       byte = 0x11
+	CommandCheckpointSet       byte = 0x12
+	CommandCheckpointDelete    byte = 0x13
+	CommandCheckpointList      byte = 0x14
+	CommandCheckpointToggle    byte = 0x15
+	CommandRegistersGet        byte = 0x31
+	CommandRegistersSet        byte = 0x32
+	CommandAdvanceInstructions byte = 0x71
+	CommandPing                byte = 0x81
+	CommandBanksAvailable      byte = 0x82
+	CommandRegistersAvailable  byte = 0x83
+	CommandInfo                byte = 0x85
+	CommandExit                byte = 0xaa
+	CommandReset               byte = 0xcc
+	CommandAutostart           byte = 0xdd
+)
+
+// Response types of events, other responses use the command type
+const (
+	ResponseStopped byte = 0x62
+	ResponseResumed byte = 0x63
+)
+
+// Error codes
+const (
+	ErrorOK                byte = 0x00
+	ErrorObjectMissing     byte = 0x01
+	ErrorInvalidMemspace   byte = 0x02
+	ErrorInvalidLength     byte = 0x80
+	ErrorInvalidParameter  byte = 0x81
+	ErrorInvalidAPIVersion byte = 0x82
+	ErrorInvalidCommand    byte = 0x83
+	ErrorGeneralFailure    byte = 0x8f
+)
+
+// Memory spaces
+const (
+	MemspaceMain   byte = 0x00
+	MemspaceDrive8 byte = 0x01
+)
+
+// Checkpoint CPU operations
+const (
+	OperationLoad  byte = 0x01
+	OperationStore byte = 0x02
+	OperationExec  byte = 0x04
+)
+
+// Register IDs of the main CPU
+const (
+	RegisterA          byte = 0x00
+	RegisterX          byte = 0x01
+	RegisterY          byte = 0x02
+	RegisterPC         byte = 0x03
+	RegisterSP         byte = 0x04
+	RegisterFlags      byte = 0x05
+	RegisterRasterLine byte = 0x35
+	RegisterCycle      byte = 0x36
+	Register00         byte = 0x37
+	Register01         byte = 0x38
+)
+
+// Command sent by the client
+type Command struct {
+	RequestID uint32
+	Type      byte
+	Body      []byte
+}
+
+// Response or event sent by the server
+type Response struct {
+	Type      byte
+	Error     byte
+	RequestID uint32
+	Body      []byte
+}
+
+// Read command: STX, API version, body length (4), request ID (4), command type, body
+func ReadCommand(reader io.Reader) (Command, error) {
+	header := make([]byte, 11)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return Command{}, err
+	}
+	if header[0] != stx {
+		return Command{}, fmt.Errorf("Invalid command start byte $%02x", header[0])
+	}
+
+	command := Command{
+		RequestID: binary.LittleEndian.Uint32(header[6:]),
+		Type:      header[10],
+	}
+	length := binary.LittleEndian.Uint32(header[2:])
+	if length > MaxCommandBodyLength {
+		return command, fmt.Errorf("%w: %d bytes", ErrCommandTooLarge, length)
+	}
+	command.Body = make([]byte, length)
+	if _, err := io.ReadFull(reader, command.Body); err != nil {
+		return Command{}, err
+	}
+	if header[1] != APIVersion {
+		return command, fmt.Errorf("%w $%02x", ErrUnsupportedVersion, header[1])
+	}
+	return command, nil
+}
+
+// Write command, used by clients
+func WriteCommand(writer io.Writer, command Command) error {
+	message := []byte{stx, APIVersion}
+	message = binary.LittleEndian.AppendUint32(message, uint32(len(command.Body)))
+	message = binary.LittleEndian.AppendUint32(message, command.RequestID)
+	message = append(message, command.Type)
+	message = append(message, command.Body...)
+	_, err := writer.Write(message)
+	return err
+}
+
+// Read response: STX, API version, body length (4), response type, error code, request ID (4), body
+func ReadResponse(reader io.Reader) (Response, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return Response{}, err
+	}
+	if header[0] != stx {
+		return Response{}, fmt.Errorf("Invalid response start byte $%02x", header[0])
+	}
+
+	response := Response{
+		Type:      header[6],
+		Error:     header[7],
+		RequestID: binary.LittleEndian.Uint32(header[8:]),
+		Body:      make([]byte, binary.LittleEndian.Uint32(header[2:])),
+	}
+	_, err := io.ReadFull(reader, response.Body)
+	return response, err
+}
+
+// Write response or event
+func WriteResponse(writer io.Writer, response Response) error {
+	message := []byte{stx, APIVersion}
+	message = binary.LittleEndian.AppendUint32(message, uint32(len(response.Body)))
+	message = append(message, response.Type, response.Error)
+	message = binary.LittleEndian.AppendUint32(message, response.RequestID)
+	message = append(message, response.Body...)
+	_, err := writer.Write(message)
+	return err
+}
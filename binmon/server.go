@@ -0,0 +1,353 @@
+// # VICE binary monitor bridge
+//
+// This package implements the server side of the VICE binary monitor protocol
+// (API version 2) on top of the Retro Debugger WebSocket API client, so tools
+// speaking the protocol (IDE plugins, C64 Studio, scripts) can control the
+// Retro Debugger. Like VICE, any command stops the emulation and the Exit
+// command resumes it.
+package binmon
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+)
+
+// Default VICE binary monitor port
+const DefaultPort = 6502
+
+// Maximum number of addresses covered by a single checkpoint,
+// every address needs its own Retro Debugger breakpoint
+const MaxCheckpointRange = 0x100
+
+// Error returned to the client with the error code
+type commandError struct {
+	code    byte
+	message string
+}
+
+func (e commandError) Error() string {
+	return e.message
+}
+
+func newCommandError(code byte, format string, args ...any) error {
+	return commandError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+// Checkpoint (breakpoint or watchpoint) set by the client
+type checkpoint struct {
+	number    uint32
+	start     uint16
+	end       uint16 // inclusive
+	stop      bool   // stop emulation when hit
+	enabled   bool
+	operation byte // OperationLoad, OperationStore and OperationExec bits
+	temporary bool // deleted after the first hit
+	memspace  byte
+	hitCount  uint32
+}
+
+// Command handler, returns response body
+type handler func(s *Server, command Command) ([]byte, error)
+
+// Bridge serving one client connection at a time
+type Server struct {
+	client *c64dws.Client
+
+	mutex          sync.Mutex // guards fields below, they are used by the events goroutine
+	conn           io.Writer
+	pendingEvents  []Response // events sent after the response
+	running        bool
+	checkpoints    map[uint32]*checkpoint
+	nextCheckpoint uint32
+	activeExec     map[uint16]bool                          // CPU breakpoints set in the emulator
+	activeMemory   map[uint16]c64dws.MemoryBreakpointAccess // memory breakpoints set in the emulator
+	writeMutex     sync.Mutex
+}
+
+var handlers = map[byte]handler{}
+
+func init() {
+	handlers = map[byte]handler{
+		CommandMemoryGet:           (*Server).memoryGet,
+		CommandMemorySet:           (*Server).memorySet,
+		CommandCheckpointGet:       (*Server).checkpointGet,
+		CommandCheckpointSet:       (*Server).checkpointSet,
+		CommandCheckpointDelete:    (*Server).checkpointDelete,
+		CommandCheckpointList:      (*Server).checkpointList,
+		CommandCheckpointToggle:    (*Server).checkpointToggle,
+		CommandRegistersGet:        (*Server).registersGet,
+		CommandRegistersSet:        (*Server).registersSet,
+		CommandAdvanceInstructions: (*Server).advanceInstructions,
+		CommandPing:                (*Server).ping,
+		CommandBanksAvailable:      (*Server).banksAvailable,
+		CommandRegistersAvailable:  (*Server).registersAvailable,
+		CommandInfo:                (*Server).info,
+		CommandExit:                (*Server).exit,
+		CommandReset:               (*Server).reset,
+		CommandAutostart:           (*Server).autostart,
+	}
+}
+
+// Create bridge using the connected client
+func NewServer(client *c64dws.Client) *Server {
+	return &Server{
+		client:         client,
+		running:        true,
+		checkpoints:    map[uint32]*checkpoint{},
+		nextCheckpoint: 1,
+		activeExec:     map[uint16]bool{},
+		activeMemory:   map[uint16]c64dws.MemoryBreakpointAccess{},
+	}
+}
+
+// Listen on TCP address (e.g. ':6502') and serve connections one by one
+func (s *Server) ListenAndServe(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		s.ServeConn(conn)
+		conn.Close()
+	}
+}
+
+// Serve commands until the connection is closed, checkpoints are kept for the next connection
+func (s *Server) ServeConn(conn io.ReadWriter) error {
+	events, unsubscribe := s.client.SubscribeEvents()
+	defer unsubscribe()
+
+	s.mutex.Lock()
+	s.conn = conn
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		s.conn = nil
+		s.mutex.Unlock()
+	}()
+
+	done := make(chan struct{})
+	defer close(done)
+	go s.listenForEvents(events, done)
+
+	reader := bufio.NewReader(conn)
+	for {
+		command, err := ReadCommand(reader)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if errors.Is(err, ErrUnsupportedVersion) {
+			s.send(Response{Type: command.Type, Error: ErrorInvalidAPIVersion, RequestID: command.RequestID})
+			continue
+		}
+		if errors.Is(err, ErrCommandTooLarge) {
+			s.send(Response{Type: command.Type, Error: ErrorInvalidLength, RequestID: command.RequestID})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		s.handle(command)
+	}
+}
+
+func (s *Server) handle(command Command) {
+	handle, exist := handlers[command.Type]
+	if !exist {
+		s.send(Response{Type: command.Type, Error: ErrorInvalidCommand, RequestID: command.RequestID})
+		return
+	}
+
+	// like VICE, enter the monitor on any command
+	if err := s.stop(); err != nil {
+		s.send(Response{Type: command.Type, Error: ErrorGeneralFailure, RequestID: command.RequestID})
+		return
+	}
+
+	body, err := handle(s, command)
+	response := Response{Type: command.Type, RequestID: command.RequestID, Body: body}
+	if err != nil {
+		response.Body = nil
+		response.Error = ErrorGeneralFailure
+		var commandErr commandError
+		if errors.As(err, &commandErr) {
+			response.Error = commandErr.code
+		}
+	}
+	// Registers set responds with the registers
+	if command.Type == CommandRegistersSet {
+		response.Type = CommandRegistersGet
+	}
+	s.send(response)
+
+	s.mutex.Lock()
+	pendingEvents := s.pendingEvents
+	s.pendingEvents = nil
+	s.mutex.Unlock()
+	for _, event := range pendingEvents {
+		s.send(event)
+	}
+}
+
+func (s *Server) send(response Response) {
+	s.mutex.Lock()
+	conn := s.conn
+	s.mutex.Unlock()
+	if conn == nil {
+		return
+	}
+
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+	WriteResponse(conn, response)
+}
+
+// Queue event sent after the response
+func (s *Server) queueEvent(responseType byte, body []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.pendingEvents = append(s.pendingEvents, Response{Type: responseType, RequestID: EventRequestID, Body: body})
+}
+
+// Pause emulation if it's running and send stopped event
+func (s *Server) stop() error {
+	s.mutex.Lock()
+	running := s.running
+	s.mutex.Unlock()
+	if !running {
+		return nil
+	}
+
+	if err := s.request(func(token string) error {
+		return s.client.PauseEmulation(token)
+	}); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	s.running = false
+	s.mutex.Unlock()
+
+	state, err := s.client.ReadCPUStatus()
+	if err != nil {
+		return err
+	}
+	s.send(Response{Type: ResponseStopped, RequestID: EventRequestID, Body: binary.LittleEndian.AppendUint16(nil, state.PC)})
+	return nil
+}
+
+// Send request and wait for its response
+func (s *Server) request(send func(token string) error) error {
+	_, err := s.client.Request(send)
+	return err
+}
+
+// Check command body length
+func checkLength(command Command, length int) error {
+	if len(command.Body) < length {
+		return newCommandError(ErrorInvalidLength, "Command $%02x body too short", command.Type)
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------
+// Events
+// ----------------------------------------------------------------------
+
+// Report checkpoints hit while running
+func (s *Server) listenForEvents(events <-chan any, done <-chan struct{}) {
+	for {
+		select {
+		case event := <-events:
+			if c64dws.IsCPUBreakpointEvent(event) || c64dws.IsMemoryBreakpointEvent(event) {
+				s.checkpointHit(c64dws.IsCPUBreakpointEvent(event))
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+func (s *Server) checkpointHit(exec bool) {
+	state, err := s.client.ReadCPUStatus()
+	if err != nil {
+		return
+	}
+
+	s.mutex.Lock()
+	s.running = false
+	hit := s.findHitCheckpoint(exec, state.PC)
+	var info []byte
+	if hit != nil {
+		hit.hitCount++
+		info = hit.info(true)
+		if hit.temporary {
+			delete(s.checkpoints, hit.number)
+		}
+	}
+	s.mutex.Unlock()
+
+	if hit != nil && hit.temporary {
+		s.syncBreakpoints()
+	}
+	if hit != nil && !hit.stop {
+		if s.request(func(token string) error {
+			return s.client.ContinueEmulation(token)
+		}) == nil {
+			s.mutex.Lock()
+			s.running = true
+			s.mutex.Unlock()
+		}
+		return
+	}
+
+	if info != nil {
+		s.send(Response{Type: CommandCheckpointGet, RequestID: EventRequestID, Body: info})
+	}
+	if registers, err := s.registersBody(state); err == nil {
+		s.send(Response{Type: CommandRegistersGet, RequestID: EventRequestID, Body: registers})
+	}
+	s.send(Response{Type: ResponseStopped, RequestID: EventRequestID, Body: binary.LittleEndian.AppendUint16(nil, state.PC)})
+}
+
+// Find enabled checkpoint matching the breakpoint. Memory breakpoint events
+// don't contain the address, so the first load/store checkpoint is used.
+func (s *Server) findHitCheckpoint(exec bool, pc uint16) *checkpoint {
+	for _, checkpoint := range s.sortedCheckpoints() {
+		if !checkpoint.enabled {
+			continue
+		}
+		if exec && checkpoint.operation&OperationExec != 0 && pc >= checkpoint.start && pc <= checkpoint.end {
+			return checkpoint
+		}
+		if !exec && checkpoint.operation&(OperationLoad|OperationStore) != 0 {
+			return checkpoint
+		}
+	}
+	return nil
+}
+
+func (s *Server) sortedCheckpoints() []*checkpoint {
+	checkpoints := make([]*checkpoint, 0, len(s.checkpoints))
+	for _, checkpoint := range s.checkpoints {
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	sort.Slice(checkpoints, func(i, j int) bool {
+		return checkpoints[i].number < checkpoints[j].number
+	})
+	return checkpoints
+}
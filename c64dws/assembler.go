@@ -0,0 +1,21 @@
+package c64dws
+
+import (
+	"github.com/mojzesh/c64d-ws-client/asm6502"
+)
+
+// Assemble 6502 source code at origin address and write it to RAM,
+// each segment is written separately, so memory between them is left unchanged
+func (c *Client) AssembleAndWrite(origin uint16, src string, token ...string) error {
+	program, err := asm6502.Assemble(origin, src)
+	if err != nil {
+		return err
+	}
+
+	for _, segment := range program.Segments {
+		if err := c.RAMWriteBlock(segment.Origin, segment.Code, token...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
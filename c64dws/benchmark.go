@@ -0,0 +1,153 @@
+package c64dws
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// Cycle statistics of repeated routine runs
+type RoutineStats struct {
+	Runs   int      // number of runs
+	Cycles []uint64 // cycles per run, in run order
+	Min    uint64   // fastest run
+	Max    uint64   // slowest run
+	Mean   float64  // average cycles
+	Median float64  // median cycles
+	StdDev float64  // standard deviation (population)
+}
+
+// Statistics formatted as 'runs: 10, min: 123, max: 130, mean: 125.40, median: 125.00, stddev: 2.10'
+func (s RoutineStats) String() string {
+	return fmt.Sprintf(
+		"runs: %d, min: %d, max: %d, mean: %.2f, median: %.2f, stddev: %.2f",
+		s.Runs, s.Min, s.Max, s.Mean, s.Median, s.StdDev,
+	)
+}
+
+// Calculate statistics of the cycle counts
+func NewRoutineStats(cycles []uint64) RoutineStats {
+	stats := RoutineStats{
+		Runs:   len(cycles),
+		Cycles: cycles,
+	}
+	if len(cycles) == 0 {
+		return stats
+	}
+
+	sorted := slices.Clone(cycles)
+	slices.Sort(sorted)
+	stats.Min = sorted[0]
+	stats.Max = sorted[len(sorted)-1]
+
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		stats.Median = float64(sorted[middle-1]+sorted[middle]) / 2
+	} else {
+		stats.Median = float64(sorted[middle])
+	}
+
+	var sum float64
+	for _, value := range cycles {
+		sum += float64(value)
+	}
+	stats.Mean = sum / float64(len(cycles))
+
+	var variance float64
+	for _, value := range cycles {
+		variance += math.Pow(float64(value)-stats.Mean, 2)
+	}
+	stats.StdDev = math.Sqrt(variance / float64(len(cycles)))
+
+	return stats
+}
+
+// Measure how many cycles the routine takes (sync mode).
+// For every run CPU jumps to the address and emulation continues until
+// CPU breakpoint at the return address is hit. Emulation is paused afterwards.
+// The routine must reach the return address, e.g. it's the instruction after
+// a JSR in the caller or the final loop of the routine (CallSubroutine handles RTS).
+func (c *Client) MeasureRoutine(address uint16, returnAddress uint16, runs int) (RoutineStats, error) {
+	if runs < 1 {
+		runs = 1
+	}
+
+	cycles := make([]uint64, 0, runs)
+	err := c.withReturnBreakpoint(returnAddress, func(events <-chan any) error {
+		for run := 0; run < runs; run++ {
+			elapsed, err := c.runUntil(events, address, returnAddress)
+			if err != nil {
+				return err
+			}
+			cycles = append(cycles, elapsed)
+		}
+		return nil
+	})
+
+	return NewRoutineStats(cycles), err
+}
+
+// Pause emulation and set CPU breakpoint at the return address while run is called (sync mode)
+func (c *Client) withReturnBreakpoint(returnAddress uint16, run func(events <-chan any) error) error {
+	events, unsubscribe := c.SubscribeEvents()
+	defer unsubscribe()
+
+	if err := c.syncRequest(func(token string) error {
+		return c.PauseEmulation(token)
+	}); err != nil {
+		return err
+	}
+	if err := c.syncRequest(func(token string) error {
+		return c.AddCPUBreakpoint(returnAddress, token)
+	}); err != nil {
+		return err
+	}
+	defer c.syncRequest(func(token string) error {
+		return c.RemoveCPUBreakpoint(returnAddress, token)
+	})
+
+	return run(events)
+}
+
+// Jump to the address, continue emulation and wait until CPU stops at the return address.
+// Returns number of elapsed cycles.
+func (c *Client) runUntil(events <-chan any, address uint16, returnAddress uint16) (uint64, error) {
+	if err := c.syncRequest(func(token string) error {
+		return c.CPUMakeJMP(address, token)
+	}); err != nil {
+		return 0, err
+	}
+
+	before, err := c.ReadCPUCounters()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := c.syncRequest(func(token string) error {
+		return c.ContinueEmulation(token)
+	}); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.requestTimeout())
+	defer cancel()
+	if _, err := c.WaitForEvent(ctx, events, IsCPUBreakpointEvent); err != nil {
+		return 0, fmt.Errorf("Breakpoint at $%04x not reached: %w", returnAddress, err)
+	}
+
+	state, err := c.ReadCPUStatus()
+	if err != nil {
+		return 0, err
+	}
+	if state.PC != returnAddress {
+		return 0, fmt.Errorf("CPU stopped at $%04x instead of $%04x", state.PC, returnAddress)
+	}
+
+	after, err := c.ReadCPUCounters()
+	if err != nil {
+		return 0, err
+	}
+
+	return after.Cycle - before.Cycle, nil
+}
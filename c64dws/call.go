@@ -0,0 +1,198 @@
+package c64dws
+
+import (
+	"fmt"
+
+	"github.com/mojzesh/c64d-ws-client/asm6502"
+)
+
+// Default address of the CallSubroutine trampoline (cassette buffer)
+const DefaultTrampolineAddress uint16 = 0x033c
+
+// Cycles spent by the trampoline setting registers before JSR
+const trampolineSetupCycles = 15
+
+// Trampoline: set registers, call subroutine and spin at 'done'
+const trampolineSource = `
+	lda #$%02x
+	pha
+	lda #$%02x
+	ldx #$%02x
+	ldy #$%02x
+	plp
+	jsr $%04x
+done:	jmp done
+`
+
+// Trampoline: set all registers and jump to PC, it's executed by stepping.
+// Interrupts are disabled until PLP sets the final P, so a pending IRQ isn't taken
+// in the middle of the trampoline.
+const setRegistersSource = `
+	sei
+	ldx #$%02x
+	txs
+	lda #$%02x
+	pha
+	lda #$%02x
+	ldx #$%02x
+	ldy #$%02x
+	plp
+resume:	jmp $%04x
+`
+
+// Maximum number of steps executing the setRegistersSource trampoline, an IRQ taken
+// right after PLP (or before SEI) is stepped through until it returns
+const setRegistersMaxSteps = 10000
+
+// CPU registers passed to and returned from a subroutine
+type Regs struct {
+	A uint8
+	X uint8
+	Y uint8
+	P uint8
+}
+
+// Registers formatted as 'A:01 X:02 Y:03 P:24'
+func (r Regs) String() string {
+	return fmt.Sprintf("A:%02x X:%02x Y:%02x P:%02x", r.A, r.X, r.Y, r.P)
+}
+
+// Result of a subroutine call
+type CallResult struct {
+	Regs   Regs   // registers after RTS
+	SP     uint8  // stack pointer after RTS
+	Cycles uint64 // cycles of JSR, the subroutine and its RTS
+}
+
+// Set the address of the CallSubroutine and SetRegisters trampoline, the trampoline takes 17 bytes
+func (c *Client) SetTrampolineAddress(address uint16) {
+	c.trampoline = address
+}
+
+func (c *Client) trampolineAddress() uint16 {
+	if c.trampoline == 0 {
+		return DefaultTrampolineAddress
+	}
+	return c.trampoline
+}
+
+// Call subroutine with the registers set and wait until it returns (sync mode).
+// A trampoline (registers setup, JSR and a spin loop with CPU breakpoint) is injected
+// at the trampoline address, memory overwritten by it is restored afterwards.
+// Emulation is paused when the call returns, PC and registers are restored with
+// SetRegisters, so the interrupted program can be continued or stepped.
+func (c *Client) CallSubroutine(address uint16, regs Regs) (CallResult, error) {
+	trampolineAddress := c.trampolineAddress()
+	program, err := asm6502.Assemble(
+		trampolineAddress,
+		fmt.Sprintf(trampolineSource, regs.P, regs.A, regs.X, regs.Y, address),
+	)
+	if err != nil {
+		return CallResult{}, err
+	}
+	returnAddress := program.Labels["done"]
+
+	var result CallResult
+	var saved CPUState
+	err = c.withReturnBreakpoint(returnAddress, func(events <-chan any) (err error) {
+		saved, err = c.ReadCPUStatus()
+		if err != nil {
+			return err
+		}
+
+		original, err := c.ReadMemory(MemorySpaceRAM, trampolineAddress, len(program.Code))
+		if err != nil {
+			return err
+		}
+		if err := c.WriteMemory(MemorySpaceRAM, trampolineAddress, program.Code); err != nil {
+			return err
+		}
+		defer func() {
+			if restoreErr := c.WriteMemory(MemorySpaceRAM, trampolineAddress, original); err == nil {
+				err = restoreErr
+			}
+		}()
+
+		elapsed, err := c.runUntil(events, trampolineAddress, returnAddress)
+		if err != nil {
+			return err
+		}
+
+		state, err := c.ReadCPUStatus()
+		if err != nil {
+			return err
+		}
+		result = CallResult{
+			Regs:   Regs{A: state.A, X: state.X, Y: state.Y, P: state.P},
+			SP:     state.SP,
+			Cycles: elapsed - min(elapsed, trampolineSetupCycles),
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	// the return breakpoint is removed and the trampoline memory restored at this point
+	return result, c.SetRegisters(saved)
+}
+
+// Set PC, A, X, Y, SP and P registers (sync mode).
+// The API can set only PC, so a trampoline setting the registers is injected at
+// the trampoline address and executed instruction by instruction, memory overwritten
+// by it is restored afterwards. Emulation is paused.
+func (c *Client) SetRegisters(state CPUState) (err error) {
+	trampolineAddress := c.trampolineAddress()
+	program, err := asm6502.Assemble(
+		trampolineAddress,
+		fmt.Sprintf(setRegistersSource, state.SP, state.P, state.A, state.X, state.Y, state.PC),
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := c.syncRequest(func(token string) error {
+		return c.PauseEmulation(token)
+	}); err != nil {
+		return err
+	}
+
+	original, err := c.ReadMemory(MemorySpaceRAM, trampolineAddress, len(program.Code))
+	if err != nil {
+		return err
+	}
+	if err := c.WriteMemory(MemorySpaceRAM, trampolineAddress, program.Code); err != nil {
+		return err
+	}
+	defer func() {
+		if restoreErr := c.WriteMemory(MemorySpaceRAM, trampolineAddress, original); err == nil {
+			err = restoreErr
+		}
+	}()
+
+	if err := c.syncRequest(func(token string) error {
+		return c.CPUMakeJMP(trampolineAddress, token)
+	}); err != nil {
+		return err
+	}
+
+	// step until the final JMP reaches PC
+	resume := program.Labels["resume"]
+	resumed := false
+	for step := 0; step < setRegistersMaxSteps; step++ {
+		if err := c.syncRequest(func(token string) error {
+			return c.StepInstruction(token)
+		}); err != nil {
+			return err
+		}
+		cpu, err := c.ReadCPUStatus()
+		if err != nil {
+			return err
+		}
+		if resumed && cpu.PC == state.PC {
+			return nil
+		}
+		resumed = resumed || cpu.PC == resume
+	}
+	return fmt.Errorf("Registers not set, PC $%04x not reached in %d steps", state.PC, setRegistersMaxSteps)
+}
@@ -0,0 +1,204 @@
+package c64dws
+
+import (
+	"fmt"
+
+	"github.com/mojzesh/c64d-ws-client/asm6502"
+	"github.com/mojzesh/c64d-ws-client/symbols"
+)
+
+// 6502 opcodes recognized on the stack
+const (
+	opcodeBRK = 0x00
+	opcodeJSR = 0x20
+)
+
+// Kind of the call stack frame
+type FrameKind string
+
+const (
+	FrameCurrent   FrameKind = "pc"        // current PC, always the first frame
+	FrameJSR       FrameKind = "jsr"       // return address pushed by JSR
+	FrameInterrupt FrameKind = "interrupt" // PC and flags pushed by IRQ or NMI
+	FrameBRK       FrameKind = "brk"       // PC and flags pushed by BRK
+)
+
+// Single call stack frame
+type StackFrame struct {
+	Kind          FrameKind // frame kind
+	StackAddress  uint16    // address of the lowest frame byte on the stack page, 0 for FrameCurrent
+	Address       uint16    // JSR/BRK instruction address, interrupted PC or current PC
+	ReturnAddress uint16    // where execution continues after RTS/RTI
+	Target        uint16    // JSR destination, 0 for other frames
+	Flags         uint8     // pushed P register (interrupt and BRK frames)
+	Label         string    // symbol of the address, e.g. 'main+3'
+	TargetLabel   string    // symbol of the JSR destination
+}
+
+// Frame formatted as e.g. '$0813 main+3  jsr $c000 (routine)'
+func (f StackFrame) String() string {
+	location := fmt.Sprintf("$%04x", f.Address)
+	if f.Label != "" {
+		location += " " + f.Label
+	}
+	switch f.Kind {
+	case FrameJSR:
+		target := fmt.Sprintf("$%04x", f.Target)
+		if f.TargetLabel != "" {
+			target += " (" + f.TargetLabel + ")"
+		}
+		return fmt.Sprintf("%-24s jsr %s", location, target)
+	case FrameInterrupt, FrameBRK:
+		return fmt.Sprintf("%-24s %s, flags %s", location, f.Kind, FormatFlags(f.Flags))
+	default:
+		return location
+	}
+}
+
+// Reconstruct call stack from the stack page (sync mode).
+// The first frame is the current PC, following frames are ordered from the innermost call.
+func (c *Client) CallStack() ([]StackFrame, error) {
+	state, err := c.ReadCPUStatus()
+	if err != nil {
+		return nil, err
+	}
+	stackPage, err := c.ReadMemory(MemorySpaceRAM, 0x0100, 0x100)
+	if err != nil {
+		return nil, err
+	}
+
+	memory := newPagedMemory(c, MemorySpaceCPU)
+	return ReconstructCallStack(state.PC, state.SP, stackPage, memory.byteAt, c.symbolTable)
+}
+
+// Reconstruct call stack from the stack page ($0100-$01ff) and stack pointer.
+// The stack is scanned heuristically from SP upwards:
+//   - two bytes form a JSR frame if the byte 3 bytes before the return address is a JSR opcode,
+//   - three bytes form an interrupt frame if the flags have the unused bit set and
+//     the pushed PC points to a documented opcode outside of the zero and stack pages,
+//   - all other bytes are skipped (registers and data pushed with PHA/PHP).
+//
+// readByte is used to check instructions in memory, symbolTable may be nil.
+func ReconstructCallStack(pc uint16, sp uint8, stackPage []byte, readByte func(address uint16) (byte, error), symbolTable *symbols.SymbolTable) ([]StackFrame, error) {
+	if len(stackPage) != 0x100 {
+		return nil, fmt.Errorf("Stack page must have 256 bytes, got %d", len(stackPage))
+	}
+
+	frames := []StackFrame{{
+		Kind:          FrameCurrent,
+		Address:       pc,
+		ReturnAddress: pc,
+		Label:         symbolTable.Annotate(pc),
+	}}
+
+	stackWord := func(offset int) uint16 {
+		return uint16(stackPage[offset]) | uint16(stackPage[offset+1])<<8
+	}
+
+	for offset := int(sp) + 1; offset < 0x100; {
+		// JSR frame: return address - 1
+		if offset+1 < 0x100 {
+			pushed := stackWord(offset)
+			jsrAddress := pushed - 2
+			opcode, err := readByte(jsrAddress)
+			if err != nil {
+				return nil, err
+			}
+			if opcode == opcodeJSR {
+				target, err := readWord(readByte, jsrAddress+1)
+				if err != nil {
+					return nil, err
+				}
+				frames = append(frames, StackFrame{
+					Kind:          FrameJSR,
+					StackAddress:  0x0100 + uint16(offset),
+					Address:       jsrAddress,
+					ReturnAddress: pushed + 1,
+					Target:        target,
+					Label:         symbolTable.Annotate(jsrAddress),
+					TargetLabel:   symbolTable.Annotate(target),
+				})
+				offset += 2
+				continue
+			}
+		}
+
+		// interrupt frame: flags, PC
+		if offset+2 < 0x100 {
+			flags := stackPage[offset]
+			returnAddress := stackWord(offset + 1)
+			opcode, err := readByte(returnAddress)
+			if err != nil {
+				return nil, err
+			}
+			if flags&FlagUnused != 0 && returnAddress >= 0x0200 && asm6502.IsDocumented(opcode) {
+				frame := StackFrame{
+					Kind:          FrameInterrupt,
+					StackAddress:  0x0100 + uint16(offset),
+					Address:       returnAddress,
+					ReturnAddress: returnAddress,
+					Flags:         flags,
+				}
+				if flags&FlagBreak != 0 {
+					opcode, err := readByte(returnAddress - 2)
+					if err != nil {
+						return nil, err
+					}
+					if opcode == opcodeBRK {
+						frame.Kind = FrameBRK
+						frame.Address = returnAddress - 2
+					}
+				}
+				frame.Label = symbolTable.Annotate(frame.Address)
+				frames = append(frames, frame)
+				offset += 3
+				continue
+			}
+		}
+
+		offset++
+	}
+
+	return frames, nil
+}
+
+func readWord(readByte func(address uint16) (byte, error), address uint16) (uint16, error) {
+	lo, err := readByte(address)
+	if err != nil {
+		return 0, err
+	}
+	hi, err := readByte(address + 1)
+	if err != nil {
+		return 0, err
+	}
+	return uint16(lo) | uint16(hi)<<8, nil
+}
+
+// Memory read in 256 byte pages on demand (sync mode)
+type pagedMemory struct {
+	client *Client
+	space  MemorySpace
+	pages  map[uint8][]byte
+}
+
+func newPagedMemory(client *Client, space MemorySpace) *pagedMemory {
+	return &pagedMemory{
+		client: client,
+		space:  space,
+		pages:  map[uint8][]byte{},
+	}
+}
+
+func (m *pagedMemory) byteAt(address uint16) (byte, error) {
+	pageNum := uint8(address >> 8)
+	page, exist := m.pages[pageNum]
+	if !exist {
+		var err error
+		page, err = m.client.ReadMemory(m.space, uint16(pageNum)<<8, 0x100)
+		if err != nil {
+			return 0, err
+		}
+		m.pages[pageNum] = page
+	}
+	return page[address&0xff], nil
+}
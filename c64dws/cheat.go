@@ -0,0 +1,177 @@
+package c64dws
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Condition narrowing cheat search candidates, comparing with the previous read
+type CheatCondition int
+
+const (
+	CheatDecreased CheatCondition = iota // value is lower than before
+	CheatIncreased                       // value is higher than before
+	CheatUnchanged                       // value is the same
+	CheatChanged                         // value is different
+	CheatEquals                          // value equals the given value
+)
+
+// Condition name
+func (condition CheatCondition) String() string {
+	switch condition {
+	case CheatDecreased:
+		return "decreased"
+	case CheatIncreased:
+		return "increased"
+	case CheatUnchanged:
+		return "unchanged"
+	case CheatChanged:
+		return "changed"
+	case CheatEquals:
+		return "equals"
+	default:
+		return "unknown"
+	}
+}
+
+// Parse condition name: 'dec', 'inc', 'same', 'diff', 'eq' or the full name
+func ParseCheatCondition(name string) (CheatCondition, error) {
+	switch strings.ToLower(name) {
+	case "dec", "decreased", "<":
+		return CheatDecreased, nil
+	case "inc", "increased", ">":
+		return CheatIncreased, nil
+	case "same", "unchanged", "==":
+		return CheatUnchanged, nil
+	case "diff", "changed", "!=":
+		return CheatChanged, nil
+	case "eq", "equals", "=":
+		return CheatEquals, nil
+	}
+	return 0, fmt.Errorf("Unknown cheat condition '%s', use dec, inc, same, diff or eq", name)
+}
+
+// Cheat search candidate
+type CheatCandidate struct {
+	Address uint16
+	Value   uint8 // value at the last read
+}
+
+// Guided search for counters (lives, energy, ...) in RAM, like the Action Replay cheat finder.
+// All addresses in the ranges are candidates at start, every Filter reads RAM again and keeps
+// candidates matching the condition.
+type CheatFinder struct {
+	client   *Client
+	values   map[uint16]uint8 // candidates with values at the last read
+	searches int
+}
+
+// Start cheat search in the RAM ranges (sync mode), empty ranges mean the whole RAM
+func (c *Client) NewCheatFinder(ranges []AddressRange) (*CheatFinder, error) {
+	if len(ranges) == 0 {
+		ranges = []AddressRange{{Start: 0x0000, End: 0xffff}}
+	}
+
+	finder := &CheatFinder{client: c, values: map[uint16]uint8{}}
+	for _, r := range ranges {
+		if r.End < r.Start {
+			return nil, fmt.Errorf("Invalid range %s", r)
+		}
+		data, err := c.ReadMemory(MemorySpaceRAM, r.Start, r.Size())
+		if err != nil {
+			return nil, err
+		}
+		for offset, value := range data {
+			finder.values[r.Start+uint16(offset)] = value
+		}
+	}
+	return finder, nil
+}
+
+// Read RAM and keep candidates matching the condition (sync mode), value is used by CheatEquals.
+// Returns the number of remaining candidates.
+func (f *CheatFinder) Filter(condition CheatCondition, value uint8) (int, error) {
+	if len(f.values) == 0 {
+		return 0, nil
+	}
+
+	candidates := f.Candidates()
+	first, last := candidates[0].Address, candidates[len(candidates)-1].Address
+	data, err := f.client.ReadMemory(MemorySpaceRAM, first, int(last)-int(first)+1)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, candidate := range candidates {
+		current := data[candidate.Address-first]
+		var keep bool
+		switch condition {
+		case CheatDecreased:
+			keep = current < candidate.Value
+		case CheatIncreased:
+			keep = current > candidate.Value
+		case CheatUnchanged:
+			keep = current == candidate.Value
+		case CheatChanged:
+			keep = current != candidate.Value
+		case CheatEquals:
+			keep = current == value
+		default:
+			return 0, fmt.Errorf("Unknown cheat condition %d", condition)
+		}
+
+		if keep {
+			f.values[candidate.Address] = current
+		} else {
+			delete(f.values, candidate.Address)
+		}
+	}
+	f.searches++
+
+	return len(f.values), nil
+}
+
+// Number of remaining candidates
+func (f *CheatFinder) Len() int {
+	return len(f.values)
+}
+
+// Number of Filter calls
+func (f *CheatFinder) Searches() int {
+	return f.searches
+}
+
+// Remaining candidates sorted by address
+func (f *CheatFinder) Candidates() []CheatCandidate {
+	candidates := make([]CheatCandidate, 0, len(f.values))
+	for address, value := range f.values {
+		candidates = append(candidates, CheatCandidate{Address: address, Value: value})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Address < candidates[j].Address
+	})
+	return candidates
+}
+
+// Write the value to all remaining candidates (sync mode)
+func (f *CheatFinder) PokeAll(value uint8) error {
+	for _, candidate := range f.Candidates() {
+		if err := f.Poke(candidate.Address, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write the value to the candidate address (sync mode)
+func (f *CheatFinder) Poke(address uint16, value uint8) error {
+	if _, exist := f.values[address]; !exist {
+		return fmt.Errorf("$%04x is not a cheat candidate", address)
+	}
+	if err := f.client.WriteMemory(MemorySpaceRAM, address, []byte{value}); err != nil {
+		return err
+	}
+	f.values[address] = value
+	return nil
+}
@@ -0,0 +1,161 @@
+package c64dws
+
+import (
+	"fmt"
+	"strings"
+)
+
+// C64 color names, index is the color number
+var ColorNames = []string{
+	"black", "white", "red", "cyan", "purple", "green", "blue", "yellow",
+	"orange", "brown", "light red", "dark grey", "grey", "light green", "light blue", "light grey",
+}
+
+var vicRegisterNames = []string{
+	"sprite 0 X", "sprite 0 Y", "sprite 1 X", "sprite 1 Y", "sprite 2 X", "sprite 2 Y", "sprite 3 X", "sprite 3 Y",
+	"sprite 4 X", "sprite 4 Y", "sprite 5 X", "sprite 5 Y", "sprite 6 X", "sprite 6 Y", "sprite 7 X", "sprite 7 Y",
+	"sprites X MSB", "control 1", "raster line", "light pen X", "light pen Y", "sprite enable", "control 2", "sprite Y expand",
+	"memory pointers", "interrupt status", "interrupt enable", "sprite priority", "sprite multicolor", "sprite X expand", "sprite-sprite collision", "sprite-data collision",
+	"border color", "background color 0", "background color 1", "background color 2", "background color 3", "sprite multicolor 0", "sprite multicolor 1", "sprite 0 color",
+	"sprite 1 color", "sprite 2 color", "sprite 3 color", "sprite 4 color", "sprite 5 color", "sprite 6 color", "sprite 7 color",
+}
+
+var sidVoiceRegisterNames = []string{
+	"frequency lo", "frequency hi", "pulse width lo", "pulse width hi", "control", "attack/decay", "sustain/release",
+}
+
+var sidRegisterNames = []string{
+	"filter cutoff lo", "filter cutoff hi", "filter resonance/routing", "volume/filter mode",
+	"paddle X", "paddle Y", "oscillator 3", "envelope 3",
+}
+
+var ciaRegisterNames = []string{
+	"port A", "port B", "port A direction", "port B direction", "timer A lo", "timer A hi", "timer B lo", "timer B hi",
+	"TOD tenths", "TOD seconds", "TOD minutes", "TOD hours", "serial data", "interrupt control", "control A", "control B",
+}
+
+var viaRegisterNames = []string{
+	"port B", "port A", "port B direction", "port A direction", "timer 1 lo", "timer 1 hi", "timer 1 latch lo", "timer 1 latch hi",
+	"timer 2 lo", "timer 2 hi", "shift register", "auxiliary control", "peripheral control", "interrupt flags", "interrupt enable", "port A (no handshake)",
+}
+
+// Register name, offset is relative to the chip base address
+func (chip Chip) RegisterName(offset int) string {
+	if offset < 0 || offset >= chip.RegistersCount() {
+		return ""
+	}
+	switch chip {
+	case ChipVIC:
+		return vicRegisterNames[offset]
+	case ChipSID:
+		if offset < 3*len(sidVoiceRegisterNames) {
+			return fmt.Sprintf("voice %d %s", offset/len(sidVoiceRegisterNames)+1, sidVoiceRegisterNames[offset%len(sidVoiceRegisterNames)])
+		}
+		return sidRegisterNames[offset-3*len(sidVoiceRegisterNames)]
+	case ChipCIA1, ChipCIA2:
+		return ciaRegisterNames[offset]
+	case ChipDrive1541VIA1, ChipDrive1541VIA2:
+		return viaRegisterNames[offset]
+	}
+	return ""
+}
+
+// Decoded meaning of the register value, empty if there is nothing to decode
+func (chip Chip) DescribeRegister(offset int, value byte) string {
+	switch chip {
+	case ChipVIC:
+		return describeVICRegister(offset, value)
+	case ChipSID:
+		return describeSIDRegister(offset, value)
+	case ChipCIA2:
+		if offset == 0x00 {
+			return fmt.Sprintf("VIC bank $%04x", uint16(3-value&0x03)*0x4000)
+		}
+	case ChipDrive1541VIA2:
+		if offset == 0x00 {
+			return describeFlags(value, []string{"", "", "motor", "LED", "", "", "", ""})
+		}
+	}
+	return ""
+}
+
+func describeVICRegister(offset int, value byte) string {
+	switch {
+	case offset == 0x10 || offset == 0x15 || offset == 0x17 || offset >= 0x1b && offset <= 0x1f:
+		return describeSprites(value)
+	case offset == 0x11:
+		rows := 24
+		if value&0x08 != 0 {
+			rows = 25
+		}
+		return joinDescriptions(
+			fmt.Sprintf("Y scroll %d, %d rows", value&0x07, rows),
+			describeFlags(value, []string{"", "", "", "", "screen on", "bitmap", "extended color", "raster bit 8"}),
+		)
+	case offset == 0x16:
+		columns := 38
+		if value&0x08 != 0 {
+			columns = 40
+		}
+		return joinDescriptions(
+			fmt.Sprintf("X scroll %d, %d columns", value&0x07, columns),
+			describeFlags(value, []string{"", "", "", "", "multicolor", "", "", ""}),
+		)
+	case offset == 0x18:
+		return fmt.Sprintf("screen +$%04x, charset +$%04x, bitmap +$%04x",
+			uint16(value>>4)*0x0400, uint16(value>>1&0x07)*0x0800, uint16(value&0x08)*0x0400)
+	case offset == 0x19 || offset == 0x1a:
+		return describeFlags(value, []string{"raster", "sprite-data", "sprite-sprite", "light pen", "", "", "", ""})
+	case offset >= 0x20:
+		return ColorNames[value&0x0f]
+	}
+	return ""
+}
+
+func describeSIDRegister(offset int, value byte) string {
+	switch {
+	case offset < 0x15 && offset%7 == 4:
+		return describeFlags(value, []string{"gate", "sync", "ring", "test", "triangle", "saw", "pulse", "noise"})
+	case offset == 0x18:
+		return joinDescriptions(
+			fmt.Sprintf("volume %d", value&0x0f),
+			describeFlags(value, []string{"", "", "", "", "low pass", "band pass", "high pass", "voice 3 off"}),
+		)
+	}
+	return ""
+}
+
+// Sprite numbers of set bits: 'sprites 0,3,7'
+func describeSprites(value byte) string {
+	if value == 0 {
+		return "no sprites"
+	}
+	sprites := []string{}
+	for sprite := 0; sprite < 8; sprite++ {
+		if value&(1<<sprite) != 0 {
+			sprites = append(sprites, fmt.Sprint(sprite))
+		}
+	}
+	return "sprites " + strings.Join(sprites, ",")
+}
+
+// Names of set bits, names index is the bit number, empty names are skipped
+func describeFlags(value byte, names []string) string {
+	flags := []string{}
+	for bit, name := range names {
+		if name != "" && value&(1<<bit) != 0 {
+			flags = append(flags, name)
+		}
+	}
+	return strings.Join(flags, ", ")
+}
+
+func joinDescriptions(descriptions ...string) string {
+	nonEmpty := []string{}
+	for _, description := range descriptions {
+		if description != "" {
+			nonEmpty = append(nonEmpty, description)
+		}
+	}
+	return strings.Join(nonEmpty, ", ")
+}
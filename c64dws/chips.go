@@ -0,0 +1,188 @@
+package c64dws
+
+import (
+	"fmt"
+)
+
+// C64 chips with registers readable via API
+type Chip int
+
+const (
+	ChipVIC Chip = iota
+	ChipCIA1
+	ChipCIA2
+	ChipSID
+	ChipDrive1541VIA1
+	ChipDrive1541VIA2
+)
+
+// All C64 chips in the order of their base addresses
+var Chips = []Chip{ChipVIC, ChipSID, ChipCIA1, ChipCIA2}
+
+// 1541 drive chips (default drive)
+var DriveChips = []Chip{ChipDrive1541VIA1, ChipDrive1541VIA2}
+
+// Chip name
+func (chip Chip) String() string {
+	switch chip {
+	case ChipVIC:
+		return "VIC"
+	case ChipCIA1:
+		return "CIA1"
+	case ChipCIA2:
+		return "CIA2"
+	case ChipSID:
+		return "SID"
+	case ChipDrive1541VIA1:
+		return "1541 VIA1"
+	case ChipDrive1541VIA2:
+		return "1541 VIA2"
+	default:
+		return "Unknown"
+	}
+}
+
+// Address of the first chip register
+func (chip Chip) BaseAddress() uint16 {
+	switch chip {
+	case ChipVIC:
+		return 0xd000
+	case ChipCIA1:
+		return 0xdc00
+	case ChipCIA2:
+		return 0xdd00
+	case ChipSID:
+		return 0xd400
+	case ChipDrive1541VIA1:
+		return 0x1800
+	case ChipDrive1541VIA2:
+		return 0x1c00
+	default:
+		return 0
+	}
+}
+
+// Number of chip registers
+func (chip Chip) RegistersCount() int {
+	switch chip {
+	case ChipVIC:
+		return 0x2f
+	case ChipCIA1, ChipCIA2, ChipDrive1541VIA1, ChipDrive1541VIA2:
+		return 0x10
+	case ChipSID:
+		return 0x1d
+	default:
+		return 0
+	}
+}
+
+// Send read request for all chip registers
+func (c *Client) readChip(chip Chip, token string) error {
+	registers := make(Registers, chip.RegistersCount())
+	for idx := range registers {
+		registers[idx] = chip.BaseAddress() + uint16(idx)
+	}
+
+	switch chip {
+	case ChipVIC:
+		return c.VICRead(registers, token)
+	case ChipCIA1:
+		return c.CIARead(CIA1, registers, token)
+	case ChipCIA2:
+		return c.CIARead(CIA2, registers, token)
+	case ChipSID:
+		return c.SIDRead(SID0, registers, token)
+	case ChipDrive1541VIA1:
+		return c.Drive1541VIARead(DriveDefault, VIA1, registers, token)
+	case ChipDrive1541VIA2:
+		return c.Drive1541VIARead(DriveDefault, VIA2, registers, token)
+	}
+	return fmt.Errorf("Unknown chip %d", chip)
+}
+
+// Send write request for chip registers, key is the register offset
+func (c *Client) writeChip(chip Chip, values map[int]byte, token string) error {
+	registersMap := RegistersMap{}
+	for offset, value := range values {
+		registersMap[fmt.Sprintf("$%04x", chip.BaseAddress()+uint16(offset))] = value
+	}
+
+	switch chip {
+	case ChipVIC:
+		return c.VICWrite(registersMap, token)
+	case ChipCIA1:
+		return c.CIAWrite(CIA1, registersMap, token)
+	case ChipCIA2:
+		return c.CIAWrite(CIA2, registersMap, token)
+	case ChipSID:
+		return c.SIDWrite(SIDRegistersMap{"SID0": {Num: SID0, Registers: registersMap}}, token)
+	case ChipDrive1541VIA1:
+		return c.Drive1541VIAWrite(DriveDefault, VIA1, registersMap, token)
+	case ChipDrive1541VIA2:
+		return c.Drive1541VIAWrite(DriveDefault, VIA2, registersMap, token)
+	}
+	return fmt.Errorf("Unknown chip %d", chip)
+}
+
+// Read all chip registers (sync mode), index is the register offset
+func (c *Client) ReadChipRegisters(chip Chip) ([]byte, error) {
+	requestResult, err := c.Request(func(token string) error {
+		return c.readChip(chip, token)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeChipRegisters(chip, requestResult)
+}
+
+// Write chip registers (sync mode), index is the register offset.
+// Registers are written as they are, including those with write side effects
+// (e.g. interrupt acknowledge).
+func (c *Client) WriteChipRegisters(chip Chip, values []byte) error {
+	if len(values) > chip.RegistersCount() {
+		return fmt.Errorf("%s has %d registers, %d values given", chip, chip.RegistersCount(), len(values))
+	}
+	registers := map[int]byte{}
+	for offset, value := range values {
+		registers[offset] = value
+	}
+	return c.syncRequest(func(token string) error {
+		return c.writeChip(chip, registers, token)
+	})
+}
+
+// Decode 'registers' result: [[register, value], ...], register is an offset or a full address
+func decodeChipRegisters(chip Chip, requestResult *RequestResult) ([]byte, error) {
+	if requestResult.Result == nil {
+		return nil, fmt.Errorf("Empty %s registers result", chip)
+	}
+	pairs, ok := (*requestResult.Result)["registers"].([]any)
+	if !ok {
+		return nil, fmt.Errorf("Invalid %s registers result", chip)
+	}
+
+	values := make([]byte, chip.RegistersCount())
+	for _, pair := range pairs {
+		pair, ok := pair.([]any)
+		if !ok || len(pair) != 2 {
+			return nil, fmt.Errorf("Invalid %s register entry", chip)
+		}
+		register, ok1 := pair[0].(float64)
+		value, ok2 := pair[1].(float64)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("Invalid %s register entry", chip)
+		}
+
+		offset := int(register)
+		if offset >= int(chip.BaseAddress()) {
+			offset -= int(chip.BaseAddress())
+		}
+		if offset < 0 || offset >= len(values) {
+			return nil, fmt.Errorf("%s register $%x out of range", chip, int(register))
+		}
+		values[offset] = byte(value)
+	}
+
+	return values, nil
+}
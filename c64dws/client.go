@@ -19,9 +19,12 @@ import (
 	"io"
 	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"github.com/mojzesh/c64d-ws-client/symbols"
 )
 
 // Default host, port and scheme of Retro Debugger WebSocket API
@@ -182,7 +185,11 @@ type Client struct {
 	conn          *websocket.Conn
 	tokenType     TokenType
 	tokenFormat   string
-	autoincrement int64 // autoincrement token used when TokenTypeAutoIncrement
+	autoincrement int64                // autoincrement token used when TokenTypeAutoIncrement
+	symbolTable   *symbols.SymbolTable // symbols used by name based addressing
+	writeMutex    sync.Mutex           // serializes writes to the WebSocket connection
+	dispatcher    dispatcher           // routes incoming messages in sync mode
+	trampoline    uint16               // address of the CallSubroutine trampoline, 0 means default
 }
 
 // Create a new client with custom host, port and scheme
@@ -423,6 +430,13 @@ func (c *Client) prepareMessage(apiFn APIFn, params *Params, binaryData []byte,
 
 // Send message over the WebSocket connection
 func (c *Client) sendMessage(message []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	if c.conn == nil {
+		return errors.New("Not connected")
+	}
+
 	err := c.conn.WriteMessage(websocket.BinaryMessage, message)
 	if err != nil {
 		return err
@@ -1024,8 +1038,7 @@ func (c *Client) GetToken(tokenFormat ...string) string {
 	case TokenTypeUUID:
 		return fmt.Sprintf(tokenFormatStr, uuid.New().String())
 	case TokenTypeAutoIncrement:
-		c.autoincrement++
-		return fmt.Sprintf(tokenFormatStr, c.autoincrement)
+		return fmt.Sprintf(tokenFormatStr, atomic.AddInt64(&c.autoincrement, 1))
 	default:
 		panic("Unknown token type")
 	}
@@ -0,0 +1,131 @@
+package c64dws
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CPU status flags (P register)
+const (
+	FlagCarry     uint8 = 0b00000001
+	FlagZero      uint8 = 0b00000010
+	FlagInterrupt uint8 = 0b00000100
+	FlagDecimal   uint8 = 0b00001000
+	FlagBreak     uint8 = 0b00010000
+	FlagUnused    uint8 = 0b00100000
+	FlagOverflow  uint8 = 0b01000000
+	FlagNegative  uint8 = 0b10000000
+)
+
+// CPU status decoded from the cpu/status result
+type CPUState struct {
+	PC               uint16 `json:"pc"`
+	A                uint8  `json:"a"`
+	X                uint8  `json:"x"`
+	Y                uint8  `json:"y"`
+	SP               uint8  `json:"sp"`
+	P                uint8  `json:"p"`
+	Memory0001       uint8  `json:"memory0001"`
+	InstructionCycle uint64 `json:"instructionCycle"`
+	RasterCycle      uint64 `json:"rasterCycle"`
+	RasterX          uint16 `json:"rasterX"`
+	RasterY          uint16 `json:"rasterY"`
+	Game             uint8  `json:"game"`
+	Exrom            uint8  `json:"exrom"`
+}
+
+// CPU counters decoded from the cpu/counters/read result
+type CycleCounters struct {
+	Cycle       uint64 `json:"cycle"`
+	Frame       uint64 `json:"frame"`
+	Instruction uint64 `json:"instruction"`
+}
+
+// Flags formatted as 'NV-BDIZC', cleared flags are shown as '.'
+func (s CPUState) FlagsString() string {
+	return FormatFlags(s.P)
+}
+
+// Format P register as 'NV-BDIZC', cleared flags are shown as '.'
+func FormatFlags(p uint8) string {
+	const names = "NV-BDIZC"
+	flags := []byte(names)
+	for idx := 0; idx < 8; idx++ {
+		if idx != 2 && p&(0x80>>idx) == 0 {
+			flags[idx] = '.'
+		}
+	}
+	return string(flags)
+}
+
+// Read CPU status (sync mode)
+func (c *Client) ReadCPUStatus() (CPUState, error) {
+	requestResult, err := c.Request(func(token string) error {
+		return c.CPUStatus(token)
+	})
+	if err != nil {
+		return CPUState{}, err
+	}
+
+	var state CPUState
+	err = decodeResult(requestResult, &state)
+	return state, err
+}
+
+// Read CPU counters (sync mode)
+func (c *Client) ReadCPUCounters() (CycleCounters, error) {
+	requestResult, err := c.Request(func(token string) error {
+		return c.CPUCounters(token)
+	})
+	if err != nil {
+		return CycleCounters{}, err
+	}
+
+	var counters CycleCounters
+	err = decodeResult(requestResult, &counters)
+	return counters, err
+}
+
+// Decode result map into struct, numbers may be sent as JSON numbers,
+// booleans or strings ('$0810', '0x0810', '2064')
+func decodeResult(requestResult *RequestResult, target any) error {
+	if requestResult.Result == nil {
+		return fmt.Errorf("Empty result")
+	}
+
+	normalized := map[string]any{}
+	for key, value := range *requestResult.Result {
+		switch value := value.(type) {
+		case bool:
+			if value {
+				normalized[key] = 1
+			} else {
+				normalized[key] = 0
+			}
+		case string:
+			number, err := parseResultNumber(value)
+			if err != nil {
+				return fmt.Errorf("Invalid value of '%s': %s", key, value)
+			}
+			normalized[key] = number
+		default:
+			normalized[key] = value
+		}
+	}
+
+	data, err := json.Marshal(normalized)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+func parseResultNumber(value string) (uint64, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "$") {
+		return strconv.ParseUint(value[1:], 16, 64)
+	}
+	return strconv.ParseUint(value, 0, 64)
+}
@@ -0,0 +1,198 @@
+package c64dws
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Frame buffer in C64 RAM and VIC registers displaying it
+type FrameBuffer struct {
+	Address uint16 // address the frame data is uploaded to, e.g. bitmap address
+	D018    uint8  // $d018 value: screen and bitmap / charset offset within the bank
+	DD00    uint8  // $dd00 value: VIC bank
+}
+
+// Frame streamer options
+type FrameStreamerOptions struct {
+	RasterLine  uint8          // raster line of the vsync breakpoint
+	Buffers     [2]FrameBuffer // front and back buffer, swapped every frame
+	FrameSize   int            // size of the frame data returned by the render callback
+	RequestCost int            // cost of a write request, DefaultMirrorRequestCost when 0
+}
+
+// Render callback, returns frame data of FrameSize bytes for the frame number
+type RenderFunc func(frame int) ([]byte, error)
+
+// Frame streamer statistics
+type FrameStats struct {
+	Frames         int           // displayed frames
+	Dropped        int           // frames not ready at vsync, the previous frame was displayed one more frame
+	Latency        time.Duration // time from render start to display of the last frame
+	AverageLatency time.Duration
+	FPS            float64
+	Flush          FlushStats // upload of the last frame
+}
+
+// Streams frames rendered on the client side to C64 RAM, synchronized with vsync
+// (raster breakpoint). Frames are uploaded double-buffered: the back buffer is
+// written while the front buffer is displayed, then the buffers are swapped at
+// vsync by writing $d018 / $dd00. Only bytes changed since the last frame in the
+// same buffer are uploaded (MirroredRAM).
+type FrameStreamer struct {
+	client       *Client
+	opts         FrameStreamerOptions
+	render       RenderFunc
+	mirrors      [2]*MirroredRAM
+	mutex        sync.Mutex
+	stats        FrameStats
+	totalLatency time.Duration
+	OnFrame      func(stats FrameStats) // called after every displayed frame, may be nil
+}
+
+// Create frame streamer, both buffers are expected to be cleared (zeros) or are uploaded whole
+// by the first two frames after Invalidate
+func (c *Client) NewFrameStreamer(opts FrameStreamerOptions, render RenderFunc) (*FrameStreamer, error) {
+	if opts.FrameSize <= 0 {
+		return nil, fmt.Errorf("Invalid frame size %d", opts.FrameSize)
+	}
+	if opts.RequestCost <= 0 {
+		opts.RequestCost = DefaultMirrorRequestCost
+	}
+
+	s := &FrameStreamer{client: c, opts: opts, render: render}
+	for idx, buffer := range opts.Buffers {
+		if int(buffer.Address)+opts.FrameSize > MemorySpaceRAM.Size() {
+			return nil, fmt.Errorf("Frame buffer $%04x+%d exceeds RAM size", buffer.Address, opts.FrameSize)
+		}
+		mirror, err := c.NewMirroredRAM(MemorySpaceRAM, AddressRange{Start: buffer.Address, End: buffer.Address + uint16(opts.FrameSize-1)})
+		if err != nil {
+			return nil, err
+		}
+		mirror.RequestCost = opts.RequestCost
+		s.mirrors[idx] = mirror
+	}
+	return s, nil
+}
+
+// Upload both buffers whole with the next frames
+func (s *FrameStreamer) Invalidate() {
+	for _, mirror := range s.mirrors {
+		mirror.Invalidate()
+	}
+}
+
+// Statistics of the streamed frames
+func (s *FrameStreamer) Stats() FrameStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats
+}
+
+// Render and stream frames until ctx is done or the render callback fails (sync mode).
+// The first frame is displayed from the first buffer, emulation keeps running when Run returns.
+func (s *FrameStreamer) Run(ctx context.Context) error {
+	c := s.client
+	events, unsubscribe := c.SubscribeEvents()
+	defer unsubscribe()
+
+	if err := c.syncRequest(func(token string) error {
+		return c.AddRasterBreakpoint(s.opts.RasterLine, token)
+	}); err != nil {
+		return err
+	}
+	defer c.syncRequest(func(token string) error {
+		return c.ContinueEmulation(token)
+	})
+	defer c.syncRequest(func(token string) error {
+		return c.RemoveRasterBreakpoint(s.opts.RasterLine, token)
+	})
+	if err := c.syncRequest(func(token string) error {
+		return c.ContinueEmulation(token)
+	}); err != nil {
+		return err
+	}
+
+	startTime := time.Now()
+	for frame := 0; ; frame++ {
+		renderStart := time.Now()
+		back := frame % 2
+		flushStats, err := s.upload(frame, back)
+		if err != nil {
+			return err
+		}
+
+		// vsync already reached means the emulator waited for this frame
+		dropped := false
+		select {
+		case event := <-events:
+			dropped = IsRasterBreakpointEvent(event)
+		default:
+		}
+		if !dropped {
+			if _, err := c.WaitForEvent(ctx, events, IsRasterBreakpointEvent); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+		}
+
+		if err := s.show(back); err != nil {
+			return err
+		}
+		if err := c.syncRequest(func(token string) error {
+			return c.ContinueEmulation(token)
+		}); err != nil {
+			return err
+		}
+
+		s.mutex.Lock()
+		s.stats.Frames++
+		if dropped && frame > 0 {
+			s.stats.Dropped++
+		}
+		s.stats.Latency = time.Since(renderStart)
+		s.totalLatency += s.stats.Latency
+		s.stats.AverageLatency = s.totalLatency / time.Duration(s.stats.Frames)
+		s.stats.FPS = float64(s.stats.Frames) / time.Since(startTime).Seconds()
+		s.stats.Flush = flushStats
+		stats := s.stats
+		s.mutex.Unlock()
+
+		if s.OnFrame != nil {
+			s.OnFrame(stats)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// Render the frame and upload changed bytes to the buffer
+func (s *FrameStreamer) upload(frame int, buffer int) (FlushStats, error) {
+	data, err := s.render(frame)
+	if err != nil {
+		return FlushStats{}, err
+	}
+	if len(data) != s.opts.FrameSize {
+		return FlushStats{}, fmt.Errorf("Rendered frame has %d bytes, expected %d", len(data), s.opts.FrameSize)
+	}
+	mirror := s.mirrors[buffer]
+	mirror.Write(mirror.Range().Start, data)
+	return mirror.FlushSync()
+}
+
+// Switch VIC to the buffer
+func (s *FrameStreamer) show(buffer int) error {
+	c := s.client
+	if err := c.syncRequest(func(token string) error {
+		return c.CIAWrite(CIA2, RegistersMap{"$dd00": s.opts.Buffers[buffer].DD00}, token)
+	}); err != nil {
+		return err
+	}
+	return c.syncRequest(func(token string) error {
+		return c.VICWrite(RegistersMap{"$d018": s.opts.Buffers[buffer].D018}, token)
+	})
+}
@@ -0,0 +1,290 @@
+package c64dws
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mojzesh/c64d-ws-client/symbols"
+)
+
+// When the freezer re-writes frozen bytes
+type FreezeMode int
+
+const (
+	FreezeEveryFrame FreezeMode = iota // every frame, synchronized with a raster breakpoint
+	FreezeOnWrite                      // when a write memory breakpoint on a frozen address fires
+)
+
+// Freezer options
+type FreezerOptions struct {
+	Mode       FreezeMode // when bytes are re-written
+	RasterLine uint8      // raster line of the breakpoint (FreezeEveryFrame)
+}
+
+// Frozen byte, trainer file entry
+type TrainerEntry struct {
+	Address     uint16
+	Value       uint8
+	Description string // e.g. 'lives', may be empty
+}
+
+// Memory freezer (trainer): keeps configured bytes at their values while the program runs
+type Freezer struct {
+	client  *Client
+	opts    FreezerOptions
+	mutex   sync.Mutex
+	entries map[uint16]TrainerEntry
+	running bool
+}
+
+// Create freezer without frozen bytes
+func (c *Client) NewFreezer(opts FreezerOptions) *Freezer {
+	return &Freezer{
+		client:  c,
+		opts:    opts,
+		entries: map[uint16]TrainerEntry{},
+	}
+}
+
+// Freeze byte (sync mode), may be called while Run is running
+func (f *Freezer) Add(entry TrainerEntry) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	_, exist := f.entries[entry.Address]
+	f.entries[entry.Address] = entry
+	if !f.running {
+		return nil
+	}
+	if f.opts.Mode == FreezeOnWrite && !exist {
+		if err := f.addBreakpoint(entry.Address); err != nil {
+			return err
+		}
+	}
+	return f.client.WriteMemory(MemorySpaceRAM, entry.Address, []byte{entry.Value})
+}
+
+// Unfreeze byte (sync mode), may be called while Run is running
+func (f *Freezer) Remove(address uint16) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if _, exist := f.entries[address]; !exist {
+		return fmt.Errorf("$%04x is not frozen", address)
+	}
+	delete(f.entries, address)
+	if f.running && f.opts.Mode == FreezeOnWrite {
+		return f.removeBreakpoint(address)
+	}
+	return nil
+}
+
+// Frozen bytes sorted by address
+func (f *Freezer) Entries() []TrainerEntry {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.sortedEntries()
+}
+
+func (f *Freezer) sortedEntries() []TrainerEntry {
+	entries := make([]TrainerEntry, 0, len(f.entries))
+	for _, entry := range f.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Address < entries[j].Address
+	})
+	return entries
+}
+
+// Run emulation and re-write frozen bytes until ctx is done (sync mode).
+// Emulation keeps running when Run returns.
+func (f *Freezer) Run(ctx context.Context) error {
+	c := f.client
+	events, unsubscribe := c.SubscribeEvents()
+	defer unsubscribe()
+
+	if err := f.start(); err != nil {
+		f.stop()
+		return err
+	}
+	defer f.stop()
+
+	match := IsRasterBreakpointEvent
+	if f.opts.Mode == FreezeOnWrite {
+		match = IsMemoryBreakpointEvent
+	}
+	for {
+		if err := c.syncRequest(func(token string) error {
+			return c.ContinueEmulation(token)
+		}); err != nil {
+			return err
+		}
+		if _, err := c.WaitForEvent(ctx, events, match); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		f.mutex.Lock()
+		err := f.writeValues()
+		f.mutex.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Add breakpoints and write frozen bytes
+func (f *Freezer) start() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.running = true
+	if f.opts.Mode == FreezeEveryFrame {
+		if err := f.client.syncRequest(func(token string) error {
+			return f.client.AddRasterBreakpoint(f.opts.RasterLine, token)
+		}); err != nil {
+			return err
+		}
+	} else {
+		for address := range f.entries {
+			if err := f.addBreakpoint(address); err != nil {
+				return err
+			}
+		}
+	}
+	return f.writeValues()
+}
+
+// Remove breakpoints and continue emulation
+func (f *Freezer) stop() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.running = false
+	if f.opts.Mode == FreezeEveryFrame {
+		f.client.syncRequest(func(token string) error {
+			return f.client.RemoveRasterBreakpoint(f.opts.RasterLine, token)
+		})
+	} else {
+		for address := range f.entries {
+			f.removeBreakpoint(address)
+		}
+	}
+	f.client.syncRequest(func(token string) error {
+		return f.client.ContinueEmulation(token)
+	})
+}
+
+func (f *Freezer) writeValues() error {
+	for _, entry := range f.sortedEntries() {
+		if err := f.client.WriteMemory(MemorySpaceRAM, entry.Address, []byte{entry.Value}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write breakpoint on any value
+func (f *Freezer) addBreakpoint(address uint16) error {
+	return f.client.syncRequest(func(token string) error {
+		return f.client.AddCPUMemoryBreakpoint(address, 0, MemoryBreakpointAccessWrite, ">=", token)
+	})
+}
+
+func (f *Freezer) removeBreakpoint(address uint16) error {
+	return f.client.syncRequest(func(token string) error {
+		return f.client.RemoveCPUMemoryBreakpoint(address, 0, token)
+	})
+}
+
+// ----------------------------------------------------------------------
+// Trainer files, one entry per line: '$c010 $09 lives',
+// empty lines and lines starting with '#' or ';' are skipped
+// ----------------------------------------------------------------------
+
+// Write trainer entries
+func WriteTrainer(w io.Writer, entries []TrainerEntry) error {
+	for _, entry := range entries {
+		line := fmt.Sprintf("$%04x $%02x", entry.Address, entry.Value)
+		if entry.Description != "" {
+			line += " " + entry.Description
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read trainer entries, numbers are parsed like in symbol files ($c010, 0xc010, 49168)
+func ReadTrainer(r io.Reader) ([]TrainerEntry, error) {
+	var entries []TrainerEntry
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("Invalid trainer entry in line %d: '%s'", lineNumber, line)
+		}
+		address, err := symbols.ParseNumber(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid address in line %d: '%s'", lineNumber, fields[0])
+		}
+		value, err := symbols.ParseNumber(fields[1])
+		if err != nil || value > 0xff {
+			return nil, fmt.Errorf("Invalid value in line %d: '%s'", lineNumber, fields[1])
+		}
+		entries = append(entries, TrainerEntry{
+			Address:     address,
+			Value:       uint8(value),
+			Description: strings.Join(fields[2:], " "),
+		})
+	}
+	return entries, scanner.Err()
+}
+
+// Save frozen bytes to the trainer file
+func (f *Freezer) SaveTrainer(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteTrainer(file, f.Entries()); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Freeze bytes from the trainer file (sync mode)
+func (f *Freezer) LoadTrainer(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	entries, err := ReadTrainer(file)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := f.Add(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package c64dws
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Byte pattern searched by Hunt
+type HuntPattern struct {
+	Bytes []byte
+	Mask  []bool // false for bytes matching any value (wildcards)
+}
+
+// Pattern matching the bytes exactly
+func BytesPattern(data []byte) HuntPattern {
+	pattern := HuntPattern{}
+	pattern.append(data...)
+	return pattern
+}
+
+// Pattern of ASCII characters
+func ASCIIPattern(text string) (HuntPattern, error) {
+	return textPattern(text, func(ch rune) (byte, bool) {
+		return byte(ch), ch < 0x80
+	})
+}
+
+// Pattern of PETSCII characters (uppercase / graphics character set),
+// letters of both cases are converted to uppercase
+func PETSCIIPattern(text string) (HuntPattern, error) {
+	return textPattern(text, petsciiByte)
+}
+
+// Pattern of screen codes (uppercase / graphics character set),
+// letters of both cases are converted to uppercase
+func ScreenCodePattern(text string) (HuntPattern, error) {
+	return textPattern(text, func(ch rune) (byte, bool) {
+		value, ok := petsciiByte(ch)
+		if value >= 0x40 && value < 0x60 {
+			value -= 0x40
+		}
+		return value, ok
+	})
+}
+
+// Parse pattern from arguments, each one is:
+//   - hexadecimal byte ('a9', '$a9')
+//   - wildcard matching any byte ('?' or '??')
+//   - hexadecimal bytes with wildcards without spaces ('a9??8d')
+//   - 'a:text', 'p:text' or 's:text' - ASCII, PETSCII or screen code string
+func ParseHuntPattern(args []string) (HuntPattern, error) {
+	pattern := HuntPattern{}
+	for _, arg := range args {
+		var part HuntPattern
+		var err error
+		switch {
+		case strings.HasPrefix(arg, "a:"):
+			part, err = ASCIIPattern(arg[2:])
+		case strings.HasPrefix(arg, "p:"):
+			part, err = PETSCIIPattern(arg[2:])
+		case strings.HasPrefix(arg, "s:"):
+			part, err = ScreenCodePattern(arg[2:])
+		default:
+			part, err = parseHexPattern(arg)
+		}
+		if err != nil {
+			return HuntPattern{}, err
+		}
+		pattern.Bytes = append(pattern.Bytes, part.Bytes...)
+		pattern.Mask = append(pattern.Mask, part.Mask...)
+	}
+	if len(pattern.Bytes) == 0 {
+		return HuntPattern{}, fmt.Errorf("Empty hunt pattern")
+	}
+	return pattern, nil
+}
+
+// Parse hexadecimal bytes and '??' wildcards: '$a9', 'a9??8d', '?'
+func parseHexPattern(text string) (HuntPattern, error) {
+	pattern := HuntPattern{}
+	hexText := strings.TrimPrefix(strings.ToLower(text), "$")
+	if hexText == "?" {
+		hexText = "??"
+	}
+	if len(hexText) == 1 {
+		hexText = "0" + hexText
+	}
+	if len(hexText) == 0 || len(hexText)%2 != 0 {
+		return HuntPattern{}, fmt.Errorf("Invalid hunt pattern '%s'", text)
+	}
+
+	for idx := 0; idx < len(hexText); idx += 2 {
+		if hexText[idx:idx+2] == "??" {
+			pattern.Bytes = append(pattern.Bytes, 0)
+			pattern.Mask = append(pattern.Mask, false)
+			continue
+		}
+		value, err := strconv.ParseUint(hexText[idx:idx+2], 16, 8)
+		if err != nil {
+			return HuntPattern{}, fmt.Errorf("Invalid hunt pattern '%s'", text)
+		}
+		pattern.append(byte(value))
+	}
+	return pattern, nil
+}
+
+func textPattern(text string, convert func(ch rune) (byte, bool)) (HuntPattern, error) {
+	pattern := HuntPattern{}
+	for _, ch := range text {
+		value, ok := convert(ch)
+		if !ok {
+			return HuntPattern{}, fmt.Errorf("Character '%c' can't be converted", ch)
+		}
+		pattern.append(value)
+	}
+	return pattern, nil
+}
+
+// Convert ASCII character to PETSCII, '\', '^' and '_' are pound, up arrow and left arrow
+func petsciiByte(ch rune) (byte, bool) {
+	switch {
+	case ch >= 'a' && ch <= 'z':
+		return byte(ch - 'a' + 'A'), true
+	case ch == '£':
+		return 0x5c, true
+	case ch >= 0x20 && ch < 0x60:
+		return byte(ch), true
+	}
+	return 0, false
+}
+
+func (p *HuntPattern) append(data ...byte) {
+	for _, value := range data {
+		p.Bytes = append(p.Bytes, value)
+		p.Mask = append(p.Mask, true)
+	}
+}
+
+// Check if the pattern matches data at the offset
+func (p HuntPattern) matchAt(data []byte, offset int) bool {
+	if offset+len(p.Bytes) > len(data) {
+		return false
+	}
+	for idx, value := range p.Bytes {
+		if p.Mask[idx] && data[offset+idx] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Addresses of all pattern occurrences in data located at the address
+func FindPattern(data []byte, address uint16, pattern HuntPattern) []uint16 {
+	found := []uint16{}
+	for offset := 0; offset+len(pattern.Bytes) <= len(data); offset++ {
+		if pattern.matchAt(data, offset) {
+			found = append(found, address+uint16(offset))
+		}
+	}
+	return found
+}
+
+// Search memory ranges for the pattern (sync mode), empty ranges mean the whole memory space.
+// Occurrences must fit within a range, addresses are returned in the ranges order.
+func (c *Client) Hunt(space MemorySpace, ranges []AddressRange, pattern HuntPattern) ([]uint16, error) {
+	if len(pattern.Bytes) == 0 || len(pattern.Bytes) != len(pattern.Mask) {
+		return nil, fmt.Errorf("Invalid hunt pattern")
+	}
+	if len(ranges) == 0 {
+		ranges = []AddressRange{{Start: 0x0000, End: uint16(space.Size() - 1)}}
+	}
+
+	found := []uint16{}
+	for _, r := range ranges {
+		if r.End < r.Start {
+			return nil, fmt.Errorf("Invalid range %s", r)
+		}
+		data, err := c.ReadMemory(space, r.Start, r.Size())
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, FindPattern(data, r.Start, pattern)...)
+	}
+	return found, nil
+}
@@ -0,0 +1,103 @@
+package c64dws
+
+import (
+	"fmt"
+	"io"
+)
+
+// Memory space as io.ReaderAt, io.WriterAt, io.ReadWriteSeeker, backed by block reads and
+// writes (sync mode). Offsets are addresses, reads stop with io.EOF at the end of the space.
+type MemoryIO struct {
+	client *Client
+	space  MemorySpace
+	offset int64
+}
+
+// Open memory space for standard io, e.g. binary.Read or hex.Dumper
+func (c *Client) OpenMemory(space MemorySpace) *MemoryIO {
+	return &MemoryIO{client: c, space: space}
+}
+
+// Memory space
+func (m *MemoryIO) Space() MemorySpace {
+	return m.space
+}
+
+// Size of the memory space in bytes
+func (m *MemoryIO) Size() int64 {
+	return int64(m.space.Size())
+}
+
+// Read len(p) bytes at the address off, fewer bytes are read at the end of the space
+func (m *MemoryIO) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("Negative address %d", off)
+	}
+	if off >= m.Size() {
+		return 0, io.EOF
+	}
+	size := min(int64(len(p)), m.Size()-off)
+	if size == 0 {
+		return 0, nil
+	}
+
+	data, err := m.client.ReadMemory(m.space, uint16(off), int(size))
+	if err != nil {
+		return 0, err
+	}
+	n := copy(p, data)
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Write p at the address off, writes past the end of the space fail without writing
+func (m *MemoryIO) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("Negative address %d", off)
+	}
+	if off+int64(len(p)) > m.Size() {
+		return 0, fmt.Errorf("Block $%04x+%d exceeds %s memory size", off, len(p), m.space)
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if err := m.client.WriteMemory(m.space, uint16(off), p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Read from the current address
+func (m *MemoryIO) Read(p []byte) (int, error) {
+	n, err := m.ReadAt(p, m.offset)
+	m.offset += int64(n)
+	return n, err
+}
+
+// Write at the current address
+func (m *MemoryIO) Write(p []byte) (int, error) {
+	n, err := m.WriteAt(p, m.offset)
+	m.offset += int64(n)
+	return n, err
+}
+
+// Set the current address, relative to the start, the current address or the end of the space
+func (m *MemoryIO) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += m.offset
+	case io.SeekEnd:
+		offset += m.Size()
+	default:
+		return 0, fmt.Errorf("Invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("Seek before the start of the memory")
+	}
+	m.offset = offset
+	return offset, nil
+}
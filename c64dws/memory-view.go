@@ -0,0 +1,139 @@
+package c64dws
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Memory configuration: what the CPU sees in the banked areas instead of RAM.
+// Only combinations selectable with $01 bits LORAM, HIRAM and CHAREN are valid
+// (no cartridge): BASIC requires KERNAL, and $d000-$dfff is either character
+// ROM or I/O when anything else is banked in.
+type MemoryConfig struct {
+	BASIC   bool // BASIC ROM at $a000-$bfff
+	KERNAL  bool // KERNAL ROM at $e000-$ffff
+	CharROM bool // character ROM at $d000-$dfff
+	IO      bool // I/O at $d000-$dfff (VIC, SID, color RAM, CIAs)
+}
+
+// Common memory configurations
+var (
+	MemoryConfigRAM     = MemoryConfig{}                                         // $01 = $x0, RAM everywhere
+	MemoryConfigIO      = MemoryConfig{IO: true}                                 // $01 = $x5, RAM with I/O
+	MemoryConfigROMs    = MemoryConfig{BASIC: true, KERNAL: true, CharROM: true} // $01 = $x3, all ROMs
+	MemoryConfigDefault = MemoryConfig{BASIC: true, KERNAL: true, IO: true}      // $01 = $x7, after reset
+)
+
+// Memory configuration selected by $01 bits 0-2
+func MemoryConfigFromPort(port uint8) MemoryConfig {
+	loram, hiram, charen := port&0x01 != 0, port&0x02 != 0, port&0x04 != 0
+	if !loram && !hiram {
+		return MemoryConfigRAM
+	}
+	return MemoryConfig{
+		BASIC:   loram && hiram,
+		KERNAL:  hiram,
+		CharROM: !charen,
+		IO:      charen,
+	}
+}
+
+// $01 bits 0-2 selecting the configuration
+func (config MemoryConfig) Port() (uint8, error) {
+	for port := uint8(0); port < 8; port++ {
+		if MemoryConfigFromPort(port) == config {
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("Memory configuration %s can't be selected with $01", config)
+}
+
+// Configuration formatted as 'BASIC+I/O+KERNAL', 'RAM' when nothing is banked in
+func (config MemoryConfig) String() string {
+	var parts []string
+	if config.BASIC {
+		parts = append(parts, "BASIC")
+	}
+	if config.CharROM {
+		parts = append(parts, "CHAR")
+	}
+	if config.IO {
+		parts = append(parts, "I/O")
+	}
+	if config.KERNAL {
+		parts = append(parts, "KERNAL")
+	}
+	if len(parts) == 0 {
+		return "RAM"
+	}
+	return strings.Join(parts, "+")
+}
+
+// CPU memory seen with an explicit memory configuration, independently of the current $01.
+// Every access pauses emulation, switches $01, accesses memory and restores $00 / $01,
+// so the running program never sees the switched configuration. Emulation stays paused.
+type MemoryView struct {
+	client *Client
+	config MemoryConfig
+	port   uint8
+}
+
+// Create view of CPU memory with the configuration
+func (c *Client) NewMemoryView(config MemoryConfig) (*MemoryView, error) {
+	port, err := config.Port()
+	if err != nil {
+		return nil, err
+	}
+	return &MemoryView{client: c, config: config, port: port}, nil
+}
+
+// Memory configuration of the view
+func (v *MemoryView) Config() MemoryConfig {
+	return v.config
+}
+
+// Read memory block (sync mode)
+func (v *MemoryView) Read(address uint16, size int) ([]byte, error) {
+	var data []byte
+	err := v.access(func() error {
+		var err error
+		data, err = v.client.ReadMemory(MemorySpaceCPU, address, size)
+		return err
+	})
+	return data, err
+}
+
+// Write memory block (sync mode), writes to ROM areas go to RAM underneath like on C64
+func (v *MemoryView) Write(address uint16, data []byte) error {
+	return v.access(func() error {
+		return v.client.WriteMemory(MemorySpaceCPU, address, data)
+	})
+}
+
+func (v *MemoryView) access(fn func() error) error {
+	c := v.client
+	if err := c.syncRequest(func(token string) error {
+		return c.PauseEmulation(token)
+	}); err != nil {
+		return err
+	}
+	return c.withMemoryConfig(v.port, fn)
+}
+
+// Switch $01 bits 0-2 to the port configuration while calling fn (emulation must be paused),
+// processor port ($00 and $01) is restored afterwards
+func (c *Client) withMemoryConfig(port uint8, fn func() error) error {
+	original, err := c.ReadMemory(MemorySpaceCPU, 0x0000, 2)
+	if err != nil {
+		return err
+	}
+	if err := c.WriteMemory(MemorySpaceCPU, 0x0000, []byte{original[0] | 0x07, original[1]&^0x07 | port}); err != nil {
+		return err
+	}
+
+	fnErr := fn()
+	if err := c.WriteMemory(MemorySpaceCPU, 0x0000, original); err != nil && fnErr == nil {
+		return err
+	}
+	return fnErr
+}
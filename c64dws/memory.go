@@ -0,0 +1,157 @@
+package c64dws
+
+import (
+	"fmt"
+)
+
+// Memory spaces accessible with block reads and writes
+type MemorySpace int
+
+const (
+	MemorySpaceRAM          MemorySpace = iota // C64 RAM, doesn't depend on $01
+	MemorySpaceCPU                             // C64 memory as seen by CPU, depends on $01
+	MemorySpaceDrive1541RAM                    // 1541 drive RAM
+	MemorySpaceDrive1541CPU                    // 1541 drive memory as seen by its CPU
+)
+
+// Size of the memory space in bytes
+func (space MemorySpace) Size() int {
+	if space == MemorySpaceDrive1541RAM {
+		return 0x0800
+	}
+	return 0x10000
+}
+
+// Memory space name
+func (space MemorySpace) String() string {
+	switch space {
+	case MemorySpaceRAM:
+		return "RAM"
+	case MemorySpaceCPU:
+		return "CPU"
+	case MemorySpaceDrive1541RAM:
+		return "Drive1541RAM"
+	case MemorySpaceDrive1541CPU:
+		return "Drive1541CPU"
+	default:
+		return "Unknown"
+	}
+}
+
+// Maximum size of a single block read/write request
+const maxBlockSize = 0x8000
+
+// Address range, both addresses are inclusive
+type AddressRange struct {
+	Start uint16 `json:"start"`
+	End   uint16 `json:"end"`
+}
+
+// Check if the address is within the range
+func (r AddressRange) Contains(address uint16) bool {
+	return address >= r.Start && address <= r.End
+}
+
+// Number of bytes in the range
+func (r AddressRange) Size() int {
+	return int(r.End) - int(r.Start) + 1
+}
+
+// Range formatted as '$0800-$08ff'
+func (r AddressRange) String() string {
+	return fmt.Sprintf("$%04x-$%04x", r.Start, r.End)
+}
+
+// Check if the address is within any of the ranges, empty ranges match all addresses
+func inRanges(ranges []AddressRange, address uint16) bool {
+	if len(ranges) == 0 {
+		return true
+	}
+	for _, r := range ranges {
+		if r.Contains(address) {
+			return true
+		}
+	}
+	return false
+}
+
+// Send block read request for the memory space
+func (c *Client) readBlock(space MemorySpace, address uint16, size uint16, token string) error {
+	switch space {
+	case MemorySpaceRAM:
+		return c.RAMReadBlock(address, size, token)
+	case MemorySpaceCPU:
+		return c.CPUMemoryReadBlock(address, size, token)
+	case MemorySpaceDrive1541RAM:
+		return c.Drive1541RAMReadBlock(address, size, token)
+	case MemorySpaceDrive1541CPU:
+		return c.Drive1541CPUMemoryReadBlock(address, size, token)
+	}
+	return fmt.Errorf("Unknown memory space %d", space)
+}
+
+// Send block write request for the memory space
+func (c *Client) writeBlock(space MemorySpace, address uint16, data []byte, token string) error {
+	switch space {
+	case MemorySpaceRAM:
+		return c.RAMWriteBlock(address, data, token)
+	case MemorySpaceCPU:
+		return c.CPUMemoryWriteBlock(address, data, token)
+	case MemorySpaceDrive1541RAM:
+		return c.Drive1541RAMWriteBlock(address, data, token)
+	case MemorySpaceDrive1541CPU:
+		return c.Drive1541CPUMemoryWriteBlock(address, data, token)
+	}
+	return fmt.Errorf("Unknown memory space %d", space)
+}
+
+func checkMemoryBounds(space MemorySpace, address uint16, size int) error {
+	if size < 0 || int(address)+size > space.Size() {
+		return fmt.Errorf("Block $%04x+%d exceeds %s memory size", address, size, space)
+	}
+	return nil
+}
+
+// Read memory block (sync mode), blocks bigger than 32KB are split into several requests
+func (c *Client) ReadMemory(space MemorySpace, address uint16, size int) ([]byte, error) {
+	if err := checkMemoryBounds(space, address, size); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, 0, size)
+	for offset := 0; offset < size; offset += maxBlockSize {
+		chunkSize := min(size-offset, maxBlockSize)
+		chunkAddress := address + uint16(offset)
+		requestResult, err := c.Request(func(token string) error {
+			return c.readBlock(space, chunkAddress, uint16(chunkSize), token)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(requestResult.BinaryData) != chunkSize {
+			return nil, fmt.Errorf("Expected %d bytes at $%04x, received %d", chunkSize, chunkAddress, len(requestResult.BinaryData))
+		}
+		data = append(data, requestResult.BinaryData...)
+	}
+
+	return data, nil
+}
+
+// Write memory block (sync mode), blocks bigger than 32KB are split into several requests
+func (c *Client) WriteMemory(space MemorySpace, address uint16, data []byte) error {
+	if err := checkMemoryBounds(space, address, len(data)); err != nil {
+		return err
+	}
+
+	for offset := 0; offset < len(data); offset += maxBlockSize {
+		chunk := data[offset:min(len(data), offset+maxBlockSize)]
+		chunkAddress := address + uint16(offset)
+		if _, err := c.Request(func(token string) error {
+			return c.writeBlock(space, chunkAddress, chunk, token)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
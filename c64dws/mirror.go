@@ -0,0 +1,184 @@
+package c64dws
+
+import (
+	"fmt"
+)
+
+// Default cost of a write request in bytes (message header, function name, token, params).
+// Clean gaps up to this size are written together with the surrounding dirty bytes.
+const DefaultMirrorRequestCost = 64
+
+// Result of the mirror flush
+type FlushStats struct {
+	Requests   int // number of block write requests
+	DirtyBytes int // number of changed bytes
+	Bytes      int // number of written bytes, including clean gaps within merged runs
+	Saved      int // bytes saved compared to writing the whole mirrored range
+}
+
+// Local copy of a memory range. Writes go to the local copy and mark changed bytes
+// as dirty, Flush writes only the dirty runs to the emulator.
+type MirroredRAM struct {
+	client      *Client
+	space       MemorySpace
+	r           AddressRange
+	data        []byte
+	dirty       []bool
+	RequestCost int // cost of a write request in bytes, see DefaultMirrorRequestCost
+}
+
+// Create mirror of the memory range, the local copy starts zeroed (as after RAMClear).
+// Use Load to read the current memory or Invalidate to write the whole range on the next flush.
+func (c *Client) NewMirroredRAM(space MemorySpace, r AddressRange) (*MirroredRAM, error) {
+	if r.End < r.Start {
+		return nil, fmt.Errorf("Invalid range %s", r)
+	}
+	if err := checkMemoryBounds(space, r.Start, r.Size()); err != nil {
+		return nil, err
+	}
+	return &MirroredRAM{
+		client:      c,
+		space:       space,
+		r:           r,
+		data:        make([]byte, r.Size()),
+		dirty:       make([]bool, r.Size()),
+		RequestCost: DefaultMirrorRequestCost,
+	}, nil
+}
+
+// Mirrored range
+func (m *MirroredRAM) Range() AddressRange {
+	return m.r
+}
+
+// Read the mirrored range into the local copy (sync mode), dirty bytes are dropped
+func (m *MirroredRAM) Load() error {
+	data, err := m.client.ReadMemory(m.space, m.r.Start, m.r.Size())
+	if err != nil {
+		return err
+	}
+	copy(m.data, data)
+	clear(m.dirty)
+	return nil
+}
+
+// Mark the whole range dirty
+func (m *MirroredRAM) Invalidate() {
+	for idx := range m.dirty {
+		m.dirty[idx] = true
+	}
+}
+
+// Byte of the local copy
+func (m *MirroredRAM) GetUByte8(address uint16) byte {
+	return m.data[m.offset(address)]
+}
+
+// Set byte of the local copy, it's marked dirty when the value changes
+func (m *MirroredRAM) SetUByte8(address uint16, value byte) {
+	offset := m.offset(address)
+	if m.data[offset] != value {
+		m.data[offset] = value
+		m.dirty[offset] = true
+	}
+}
+
+// Write data to the local copy at the address
+func (m *MirroredRAM) Write(address uint16, data []byte) {
+	offset := m.offset(address)
+	if offset+len(data) > len(m.data) {
+		panic(fmt.Sprintf("Block $%04x+%d exceeds mirrored range %s", address, len(data), m.r))
+	}
+	for idx, value := range data {
+		if m.data[offset+idx] != value {
+			m.data[offset+idx] = value
+			m.dirty[offset+idx] = true
+		}
+	}
+}
+
+// Fill count bytes of the local copy at the address
+func (m *MirroredRAM) Fill(address uint16, count int, value byte) {
+	for idx := 0; idx < count; idx++ {
+		m.SetUByte8(address+uint16(idx), value)
+	}
+}
+
+// Local copy of the mirrored range, must not be modified
+func (m *MirroredRAM) Bytes() []byte {
+	return m.data
+}
+
+// Number of dirty bytes
+func (m *MirroredRAM) DirtyBytes() int {
+	count := 0
+	for _, dirty := range m.dirty {
+		if dirty {
+			count++
+		}
+	}
+	return count
+}
+
+// Dirty runs to write. Two runs are merged when the clean gap between them costs
+// less than another request, so a mostly dirty range becomes one big write.
+func (m *MirroredRAM) DirtyRuns() []AddressRange {
+	var runs []AddressRange
+	for offset := 0; offset < len(m.dirty); offset++ {
+		if !m.dirty[offset] {
+			continue
+		}
+		start := offset
+		for offset+1 < len(m.dirty) && m.dirty[offset+1] {
+			offset++
+		}
+		runs = append(runs, AddressRange{Start: m.r.Start + uint16(start), End: m.r.Start + uint16(offset)})
+	}
+	return MergeRanges(runs, max(m.RequestCost, 0))
+}
+
+// Write dirty runs to the emulator. Requests are sent without waiting for responses,
+// so flushing works with both sync mode and messages read by ReceiveMessage, but
+// failed writes are not reported and their bytes are marked clean anyway.
+func (m *MirroredRAM) Flush() (FlushStats, error) {
+	return m.flush(func(address uint16, chunk []byte) error {
+		return m.client.writeBlock(m.space, address, chunk, "")
+	})
+}
+
+// Write dirty runs to the emulator and wait for every response (sync mode).
+// Flushing stops at the first failed write, bytes which were not written stay dirty.
+func (m *MirroredRAM) FlushSync() (FlushStats, error) {
+	return m.flush(func(address uint16, chunk []byte) error {
+		return m.client.syncRequest(func(token string) error {
+			return m.client.writeBlock(m.space, address, chunk, token)
+		})
+	})
+}
+
+func (m *MirroredRAM) flush(write func(address uint16, chunk []byte) error) (FlushStats, error) {
+	stats := FlushStats{DirtyBytes: m.DirtyBytes()}
+	for _, run := range m.DirtyRuns() {
+		data := m.data[run.Start-m.r.Start : int(run.End-m.r.Start)+1]
+		for offset := 0; offset < len(data); offset += maxBlockSize {
+			chunk := data[offset:min(len(data), offset+maxBlockSize)]
+			if err := write(run.Start+uint16(offset), chunk); err != nil {
+				return stats, err
+			}
+			stats.Requests++
+		}
+		for address := int(run.Start); address <= int(run.End); address++ {
+			m.dirty[address-int(m.r.Start)] = false
+		}
+		stats.Bytes += len(data)
+	}
+	stats.Saved = len(m.data) - stats.Bytes
+	return stats, nil
+}
+
+func (m *MirroredRAM) offset(address uint16) int {
+	if !m.r.Contains(address) {
+		panic(fmt.Sprintf("Address $%04x is outside of mirrored range %s", address, m.r))
+	}
+	return int(address - m.r.Start)
+}
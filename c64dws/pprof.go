@@ -0,0 +1,182 @@
+package c64dws
+
+import (
+	"compress/gzip"
+	"io"
+	"slices"
+)
+
+// --------------------------------------------------------------
+// pprof export
+//
+// Profiles are written as gzipped protobuf (perftools.profiles.Profile),
+// so they can be inspected with 'go tool pprof'. Every sampled PC becomes
+// a location, every symbol a function, e.g.:
+//
+//	go tool pprof -top -lines c64.pb.gz
+// --------------------------------------------------------------
+
+// Field numbers of perftools.profiles messages
+const (
+	pprofProfileSampleType    = 1
+	pprofProfileSample        = 2
+	pprofProfileLocation      = 4
+	pprofProfileFunction      = 5
+	pprofProfileStringTable   = 6
+	pprofProfileTimeNanos     = 9
+	pprofProfileDurationNanos = 10
+	pprofProfilePeriodType    = 11
+	pprofProfilePeriod        = 12
+
+	pprofValueTypeType = 1
+	pprofValueTypeUnit = 2
+
+	pprofSampleLocationID = 1
+	pprofSampleValue      = 2
+
+	pprofLocationID      = 1
+	pprofLocationAddress = 3
+	pprofLocationLine    = 4
+
+	pprofLineFunctionID = 1
+	pprofLineLine       = 2
+
+	pprofFunctionID         = 1
+	pprofFunctionName       = 2
+	pprofFunctionSystemName = 3
+	pprofFunctionStartLine  = 5
+)
+
+// Minimal protobuf encoder
+type protoBuffer []byte
+
+func (b *protoBuffer) varint(value uint64) {
+	for value >= 0x80 {
+		*b = append(*b, byte(value)|0x80)
+		value >>= 7
+	}
+	*b = append(*b, byte(value))
+}
+
+func (b *protoBuffer) tag(field int, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+// Varint field, zero values are omitted
+func (b *protoBuffer) uint64Field(field int, value uint64) {
+	if value == 0 {
+		return
+	}
+	b.tag(field, 0)
+	b.varint(value)
+}
+
+// Length delimited field
+func (b *protoBuffer) bytesField(field int, data []byte) {
+	b.tag(field, 2)
+	b.varint(uint64(len(data)))
+	*b = append(*b, data...)
+}
+
+// Packed repeated varint field
+func (b *protoBuffer) packedField(field int, values ...uint64) {
+	var packed protoBuffer
+	for _, value := range values {
+		packed.varint(value)
+	}
+	b.bytesField(field, packed)
+}
+
+// String table, index 0 is always empty string
+type pprofStrings struct {
+	table []string
+	index map[string]uint64
+}
+
+func (s *pprofStrings) id(str string) uint64 {
+	if s.index == nil {
+		s.table = []string{""}
+		s.index = map[string]uint64{"": 0}
+	}
+	if id, exist := s.index[str]; exist {
+		return id
+	}
+	id := uint64(len(s.table))
+	s.table = append(s.table, str)
+	s.index[str] = id
+	return id
+}
+
+// Write profile in pprof format (gzipped protobuf)
+func (p *Profile) WritePprof(w io.Writer) error {
+	var stringTable pprofStrings
+	var profile protoBuffer
+
+	valueType := func(typeName, unit string) []byte {
+		var valueType protoBuffer
+		valueType.uint64Field(pprofValueTypeType, stringTable.id(typeName))
+		valueType.uint64Field(pprofValueTypeUnit, stringTable.id(unit))
+		return valueType
+	}
+	profile.bytesField(pprofProfileSampleType, valueType("samples", "count"))
+
+	pcs := make([]uint16, 0, len(p.Samples))
+	for pc := range p.Samples {
+		pcs = append(pcs, pc)
+	}
+	slices.Sort(pcs)
+
+	functionIDs := map[string]uint64{}
+	var functions protoBuffer
+	for idx, pc := range pcs {
+		locationID := uint64(idx + 1)
+
+		// function per symbol
+		name, address := p.symbolFor(pc)
+		functionID, exist := functionIDs[name]
+		if !exist {
+			functionID = uint64(len(functionIDs) + 1)
+			functionIDs[name] = functionID
+
+			var function protoBuffer
+			function.uint64Field(pprofFunctionID, functionID)
+			function.uint64Field(pprofFunctionName, stringTable.id(name))
+			function.uint64Field(pprofFunctionSystemName, stringTable.id(name))
+			function.uint64Field(pprofFunctionStartLine, uint64(address))
+			functions.bytesField(pprofProfileFunction, function)
+		}
+
+		// sample per PC
+		var sample protoBuffer
+		sample.packedField(pprofSampleLocationID, locationID)
+		sample.packedField(pprofSampleValue, uint64(p.Samples[pc]))
+		profile.bytesField(pprofProfileSample, sample)
+
+		// location per PC, line number is the PC so 'pprof -lines' shows addresses
+		var line protoBuffer
+		line.uint64Field(pprofLineFunctionID, functionID)
+		line.uint64Field(pprofLineLine, uint64(pc))
+
+		var location protoBuffer
+		location.uint64Field(pprofLocationID, locationID)
+		location.uint64Field(pprofLocationAddress, uint64(pc))
+		location.bytesField(pprofLocationLine, line)
+		profile.bytesField(pprofProfileLocation, location)
+	}
+	profile = append(profile, functions...)
+
+	profile.bytesField(pprofProfilePeriodType, valueType("samples", "count"))
+	profile.uint64Field(pprofProfilePeriod, 1)
+	profile.uint64Field(pprofProfileTimeNanos, uint64(p.StartTime.UnixNano()))
+	profile.uint64Field(pprofProfileDurationNanos, uint64(p.Duration.Nanoseconds()))
+
+	for _, str := range stringTable.table {
+		profile.bytesField(pprofProfileStringTable, []byte(str))
+	}
+
+	gzipWriter := gzip.NewWriter(w)
+	if _, err := gzipWriter.Write(profile); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
@@ -0,0 +1,211 @@
+package c64dws
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mojzesh/c64d-ws-client/symbols"
+)
+
+// How the profiler collects samples
+type ProfileMode int
+
+const (
+	ProfileModeRunning  ProfileMode = iota // emulation runs, CPU status is polled every Interval
+	ProfileModeStepping                    // emulation is paused, CPU is stepped StepInstructions between samples
+)
+
+// Default time between samples in ProfileModeRunning
+const DefaultProfileInterval = time.Millisecond
+
+// Profiler options
+type ProfileOptions struct {
+	Mode             ProfileMode   // sampling mode
+	Interval         time.Duration // time between samples (ProfileModeRunning), DefaultProfileInterval when 0
+	StepInstructions int           // instructions executed between samples (ProfileModeStepping), 1 when 0
+	Duration         time.Duration // stop after this time, 0 means no limit
+	MaxSamples       int           // stop after collecting this many samples, 0 means no limit
+	Warp             bool          // enable warp mode while profiling
+}
+
+// Sampled PC histogram
+type Profile struct {
+	Samples   map[uint16]int       // number of samples per PC
+	Total     int                  // total number of samples
+	Mode      ProfileMode          // sampling mode
+	Interval  time.Duration        // time between samples (ProfileModeRunning)
+	StartTime time.Time            // when profiling has started
+	Duration  time.Duration        // how long profiling took
+	Symbols   *symbols.SymbolTable // symbols used for aggregation, may be nil
+}
+
+// Single profile entry, either PC or symbol
+type Hotspot struct {
+	Address uint16  // PC or symbol address
+	Name    string  // symbol name ('main+3' for PC hotspots), empty if unknown
+	Samples int     // number of samples
+	Percent float64 // percent of all samples
+}
+
+// Hotspot formatted as '$0810 main+3  123  12.30%'
+func (h Hotspot) String() string {
+	return fmt.Sprintf("$%04x %-20s %8d %6.2f%%", h.Address, h.Name, h.Samples, h.Percent)
+}
+
+// Create an empty profile
+func NewProfile(symbolTable *symbols.SymbolTable) *Profile {
+	return &Profile{
+		Samples:   map[uint16]int{},
+		StartTime: time.Now(),
+		Symbols:   symbolTable,
+	}
+}
+
+// Add PC sample
+func (p *Profile) AddSample(pc uint16) {
+	p.Samples[pc]++
+	p.Total++
+}
+
+// Sample PC until ctx is done or one of the limits is reached (sync mode).
+// In ProfileModeRunning emulation should be running, in ProfileModeStepping it should be paused.
+// At least one limit (ctx, Duration or MaxSamples) should be set, otherwise profiling never stops.
+func (c *Client) Profile(ctx context.Context, opts ProfileOptions) (*Profile, error) {
+	profile := NewProfile(c.symbolTable)
+	profile.Mode = opts.Mode
+	profile.Interval = opts.Interval
+	if profile.Interval == 0 {
+		profile.Interval = DefaultProfileInterval
+	}
+	stepInstructions := max(opts.StepInstructions, 1)
+
+	if opts.Warp {
+		if err := c.setWarpModeSync(true); err != nil {
+			return nil, err
+		}
+		defer c.setWarpModeSync(false)
+	}
+
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+	var ticker *time.Ticker
+	if opts.Mode == ProfileModeRunning {
+		ticker = time.NewTicker(profile.Interval)
+		defer ticker.Stop()
+	}
+
+	defer func() {
+		profile.Duration = time.Since(profile.StartTime)
+	}()
+
+	for opts.MaxSamples == 0 || profile.Total < opts.MaxSamples {
+		select {
+		case <-ctx.Done():
+			return profile, nil
+		default:
+		}
+
+		if opts.Mode == ProfileModeStepping {
+			for step := 0; step < stepInstructions; step++ {
+				if _, err := c.Request(func(token string) error {
+					return c.StepInstruction(token)
+				}); err != nil {
+					return profile, err
+				}
+			}
+		}
+
+		state, err := c.ReadCPUStatus()
+		if err != nil {
+			return profile, err
+		}
+		profile.AddSample(state.PC)
+
+		if ticker != nil {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+			}
+		}
+	}
+
+	return profile, nil
+}
+
+func (c *Client) setWarpModeSync(warpMode bool) error {
+	return c.syncRequest(func(token string) error {
+		return c.SetWarpMode(warpMode, token)
+	})
+}
+
+// PC hotspots sorted by number of samples, limit 0 means all
+func (p *Profile) Hotspots(limit int) []Hotspot {
+	hotspots := make([]Hotspot, 0, len(p.Samples))
+	for pc, samples := range p.Samples {
+		hotspots = append(hotspots, Hotspot{
+			Address: pc,
+			Name:    p.Symbols.Annotate(pc),
+			Samples: samples,
+			Percent: p.percent(samples),
+		})
+	}
+	return sortHotspots(hotspots, limit)
+}
+
+// Hotspots aggregated by the nearest symbol placed at or below PC, limit 0 means all.
+// Samples without any symbol within symbols.MaxAnnotationOffset below them are aggregated by PC.
+func (p *Profile) HotspotsBySymbol(limit int) []Hotspot {
+	bySymbol := map[string]*Hotspot{}
+	for pc, samples := range p.Samples {
+		name, address := p.symbolFor(pc)
+		hotspot, exist := bySymbol[name]
+		if !exist {
+			hotspot = &Hotspot{Address: address, Name: name}
+			bySymbol[name] = hotspot
+		}
+		hotspot.Samples += samples
+	}
+
+	hotspots := make([]Hotspot, 0, len(bySymbol))
+	for _, hotspot := range bySymbol {
+		hotspot.Percent = p.percent(hotspot.Samples)
+		hotspots = append(hotspots, *hotspot)
+	}
+	return sortHotspots(hotspots, limit)
+}
+
+// Name and address of the symbol which the PC belongs to, '$xxxx' if there is none nearby
+func (p *Profile) symbolFor(pc uint16) (string, uint16) {
+	if p.Symbols != nil {
+		if symbol, offset, exist := p.Symbols.Nearest(pc); exist && offset <= symbols.MaxAnnotationOffset {
+			return symbol.Name, symbol.Address
+		}
+	}
+	return fmt.Sprintf("$%04x", pc), pc
+}
+
+func (p *Profile) percent(samples int) float64 {
+	if p.Total == 0 {
+		return 0
+	}
+	return float64(samples) * 100 / float64(p.Total)
+}
+
+// Sort by samples (descending) then by address
+func sortHotspots(hotspots []Hotspot, limit int) []Hotspot {
+	sort.Slice(hotspots, func(i, j int) bool {
+		if hotspots[i].Samples != hotspots[j].Samples {
+			return hotspots[i].Samples > hotspots[j].Samples
+		}
+		return hotspots[i].Address < hotspots[j].Address
+	})
+	if limit > 0 && len(hotspots) > limit {
+		hotspots = hotspots[:limit]
+	}
+	return hotspots
+}
@@ -18,6 +18,7 @@ type RequestResult struct {
 // Request error
 type RequestError struct {
 	RequestResultBase
+	Token string `json:"token"`
 	Error string `json:"error"`
 }
 
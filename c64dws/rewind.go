@@ -0,0 +1,149 @@
+package c64dws
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Default number of snapshots kept by the rewind buffer
+const DefaultRewindCapacity = 50
+
+// Rewind buffer options
+type RewindOptions struct {
+	Capacity    int   // number of kept snapshots, DefaultRewindCapacity when 0
+	EveryFrames int   // frames between snapshots, 1 when 0
+	RasterLine  uint8 // raster line of the breakpoint counting frames
+}
+
+// Ring buffer of periodic snapshots
+type RewindBuffer struct {
+	client    *Client
+	opts      RewindOptions
+	mutex     sync.Mutex
+	snapshots []*Snapshot // ring, next is the index of the oldest snapshot when full
+	next      int
+	count     int
+}
+
+// Create an empty rewind buffer
+func (c *Client) NewRewindBuffer(opts RewindOptions) *RewindBuffer {
+	if opts.Capacity <= 0 {
+		opts.Capacity = DefaultRewindCapacity
+	}
+	opts.EveryFrames = max(opts.EveryFrames, 1)
+
+	return &RewindBuffer{
+		client:    c,
+		opts:      opts,
+		snapshots: make([]*Snapshot, opts.Capacity),
+	}
+}
+
+// Run emulation and capture a snapshot every EveryFrames frames until ctx is done (sync mode).
+// Frames are counted with a raster breakpoint, emulation is paused when Run returns.
+func (b *RewindBuffer) Run(ctx context.Context) error {
+	c := b.client
+	events, unsubscribe := c.SubscribeEvents()
+	defer unsubscribe()
+
+	if err := c.syncRequest(func(token string) error {
+		return c.AddRasterBreakpoint(b.opts.RasterLine, token)
+	}); err != nil {
+		return err
+	}
+	defer c.syncRequest(func(token string) error {
+		return c.RemoveRasterBreakpoint(b.opts.RasterLine, token)
+	})
+	defer c.syncRequest(func(token string) error {
+		return c.PauseEmulation(token)
+	})
+
+	for frame := 1; ; frame++ {
+		if err := c.syncRequest(func(token string) error {
+			return c.ContinueEmulation(token)
+		}); err != nil {
+			return err
+		}
+		if _, err := c.WaitForEvent(ctx, events, IsRasterBreakpointEvent); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if frame%b.opts.EveryFrames == 0 {
+			if _, err := b.Capture(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Capture a snapshot now and add it to the buffer (sync mode), emulation is paused
+func (b *RewindBuffer) Capture() (*Snapshot, error) {
+	snapshot, err := b.client.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	b.Add(snapshot)
+	return snapshot, nil
+}
+
+// Add snapshot to the buffer, the oldest snapshot is dropped when the buffer is full
+func (b *RewindBuffer) Add(snapshot *Snapshot) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.snapshots[b.next] = snapshot
+	b.next = (b.next + 1) % len(b.snapshots)
+	b.count = min(b.count+1, len(b.snapshots))
+}
+
+// Number of snapshots in the buffer
+func (b *RewindBuffer) Len() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.count
+}
+
+// Snapshot k steps back, 0 is the latest one
+func (b *RewindBuffer) Snapshot(k int) (*Snapshot, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if k < 0 || k >= b.count {
+		return nil, fmt.Errorf("Rewind buffer contains %d snapshots, can't go %d back", b.count, k)
+	}
+	return b.snapshots[(b.next-1-k+len(b.snapshots))%len(b.snapshots)], nil
+}
+
+// Restore the snapshot k steps back (sync mode), newer snapshots are dropped,
+// so the restored state becomes the latest one
+func (b *RewindBuffer) Rewind(k int) error {
+	snapshot, err := b.Snapshot(k)
+	if err != nil {
+		return err
+	}
+	if err := b.client.Restore(snapshot); err != nil {
+		return err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for drop := 0; drop < k; drop++ {
+		b.next = (b.next - 1 + len(b.snapshots)) % len(b.snapshots)
+		b.snapshots[b.next] = nil
+		b.count--
+	}
+	return nil
+}
+
+// Save the snapshot k steps back to the file
+func (b *RewindBuffer) Export(k int, path string) error {
+	snapshot, err := b.Snapshot(k)
+	if err != nil {
+		return err
+	}
+	return snapshot.SaveFile(path)
+}
@@ -0,0 +1,70 @@
+package c64dws
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Memory segment name. Segments come from the debug symbols loaded in Retro Debugger
+// (e.g. KickAssembler segments), so the accepted names depend on the debugged program.
+type Segment string
+
+// Segment used by KickAssembler for code outside of named segments
+const SegmentDefault Segment = "Default"
+
+// Segment read result. The server returns no result fields for a segment read,
+// only the binary contents, so Segment is the name passed in the request.
+type SegmentData struct {
+	Segment Segment
+	Data    []byte // segment contents
+}
+
+// Read segment (sync mode)
+func (c *Client) ReadSegmentData(segment Segment) (*SegmentData, error) {
+	requestResult, err := c.Request(func(token string) error {
+		return c.ReadSegment(string(segment), token)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Segment '%s': %w", segment, err)
+	}
+	return &SegmentData{Segment: segment, Data: requestResult.BinaryData}, nil
+}
+
+// Write segment contents (sync mode)
+func (c *Client) WriteSegmentData(segment Segment, data []byte) error {
+	if err := c.syncRequest(func(token string) error {
+		return c.WriteSegment(string(segment), data, token)
+	}); err != nil {
+		return fmt.Errorf("Segment '%s': %w", segment, err)
+	}
+	return nil
+}
+
+// Names accepted by the server out of the candidates (sync mode). The API has no call
+// listing segments, so every candidate is read; names rejected as unknown segments
+// are skipped, other errors (e.g. emulation not paused, closed connection) stop
+// the discovery.
+func (c *Client) DiscoverSegments(candidates []Segment) ([]Segment, error) {
+	found := []Segment{}
+	for _, segment := range candidates {
+		_, err := c.ReadSegmentData(segment)
+		switch {
+		case err == nil:
+			found = append(found, segment)
+		case isUnknownSegmentError(err):
+			continue
+		default:
+			return nil, err
+		}
+	}
+	return found, nil
+}
+
+// Bad request naming the segment, e.g. 'unknown segment Music'
+func isUnknownSegmentError(err error) bool {
+	var requestFailed *RequestFailedError
+	return errors.As(err, &requestFailed) &&
+		requestFailed.Status == 400 &&
+		strings.Contains(strings.ToLower(requestFailed.Message), "segment")
+}
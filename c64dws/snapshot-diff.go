@@ -0,0 +1,240 @@
+package c64dws
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Memories compared by DiffSnapshots
+const (
+	DiffMemoryRAM          = "RAM"
+	DiffMemoryColorRAM     = "ColorRAM"
+	DiffMemoryDrive1541RAM = "Drive1541RAM"
+)
+
+// Maximum number of bytes shown per memory range in the text report
+const diffTextMaxBytes = 16
+
+// Bytes marshaled to JSON as a hex string
+type HexBytes []byte
+
+func (b HexBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(b))
+}
+
+func (b *HexBytes) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	decoded, err := hex.DecodeString(str)
+	*b = decoded
+	return err
+}
+
+// Changed CPU register
+type ValueDiff struct {
+	Name string `json:"name"`
+	Old  uint64 `json:"old"`
+	New  uint64 `json:"new"`
+}
+
+// Changed memory range, addresses of color RAM start at $d800
+type MemoryDiff struct {
+	Memory string       `json:"memory"`
+	Range  AddressRange `json:"range"`
+	Old    HexBytes     `json:"old"`
+	New    HexBytes     `json:"new"`
+}
+
+// Changed chip register with its decoded meaning
+type RegisterDiff struct {
+	Chip           string `json:"chip"`
+	Address        uint16 `json:"address"`
+	Name           string `json:"name"`
+	Old            uint8  `json:"old"`
+	New            uint8  `json:"new"`
+	OldDescription string `json:"oldDescription,omitempty"`
+	NewDescription string `json:"newDescription,omitempty"`
+}
+
+// Differences between two snapshots
+type SnapshotDiff struct {
+	CPU       []ValueDiff    `json:"cpu"`
+	Elapsed   CycleCounters  `json:"elapsed"` // counters difference, zero if counters went back
+	Memory    []MemoryDiff   `json:"memory"`
+	Registers []RegisterDiff `json:"registers"`
+}
+
+// Compare snapshots, memory changes are reported as ranges of consecutive changed bytes
+func DiffSnapshots(old *Snapshot, new *Snapshot) *SnapshotDiff {
+	diff := &SnapshotDiff{
+		CPU:       diffCPU(old.CPU, new.CPU),
+		Memory:    []MemoryDiff{},
+		Registers: []RegisterDiff{},
+	}
+
+	if new.Counters.Cycle >= old.Counters.Cycle && new.Counters.Frame >= old.Counters.Frame && new.Counters.Instruction >= old.Counters.Instruction {
+		diff.Elapsed = CycleCounters{
+			Cycle:       new.Counters.Cycle - old.Counters.Cycle,
+			Frame:       new.Counters.Frame - old.Counters.Frame,
+			Instruction: new.Counters.Instruction - old.Counters.Instruction,
+		}
+	}
+
+	diff.Memory = append(diff.Memory, diffMemory(DiffMemoryRAM, 0x0000, old.RAM, new.RAM)...)
+	diff.Memory = append(diff.Memory, diffMemory(DiffMemoryColorRAM, ColorRAMAddress, old.ColorRAM, new.ColorRAM)...)
+	diff.Memory = append(diff.Memory, diffMemory(DiffMemoryDrive1541RAM, 0x0000, old.DriveRAM, new.DriveRAM)...)
+
+	for _, chip := range snapshotChips {
+		oldRegisters, newRegisters := old.Chips[chip], new.Chips[chip]
+		for offset := 0; offset < min(len(oldRegisters), len(newRegisters)); offset++ {
+			if oldRegisters[offset] == newRegisters[offset] {
+				continue
+			}
+			diff.Registers = append(diff.Registers, RegisterDiff{
+				Chip:           chip.String(),
+				Address:        chip.BaseAddress() + uint16(offset),
+				Name:           chip.RegisterName(offset),
+				Old:            oldRegisters[offset],
+				New:            newRegisters[offset],
+				OldDescription: chip.DescribeRegister(offset, oldRegisters[offset]),
+				NewDescription: chip.DescribeRegister(offset, newRegisters[offset]),
+			})
+		}
+	}
+
+	return diff
+}
+
+// Compare the snapshot with the live machine state (sync mode), emulation is paused
+func (c *Client) DiffWithSnapshot(snapshot *Snapshot) (*SnapshotDiff, error) {
+	live, err := c.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return DiffSnapshots(snapshot, live), nil
+}
+
+// Changed CPU registers
+func diffCPU(old CPUState, new CPUState) []ValueDiff {
+	diffs := []ValueDiff{}
+	compare := func(name string, oldValue uint64, newValue uint64) {
+		if oldValue != newValue {
+			diffs = append(diffs, ValueDiff{Name: name, Old: oldValue, New: newValue})
+		}
+	}
+	compare("PC", uint64(old.PC), uint64(new.PC))
+	compare("A", uint64(old.A), uint64(new.A))
+	compare("X", uint64(old.X), uint64(new.X))
+	compare("Y", uint64(old.Y), uint64(new.Y))
+	compare("SP", uint64(old.SP), uint64(new.SP))
+	compare("P", uint64(old.P), uint64(new.P))
+	compare("$01", uint64(old.Memory0001), uint64(new.Memory0001))
+	return diffs
+}
+
+// Ranges of consecutive changed bytes
+func diffMemory(memory string, baseAddress uint16, old []byte, new []byte) []MemoryDiff {
+	diffs := []MemoryDiff{}
+	size := min(len(old), len(new))
+	for offset := 0; offset < size; offset++ {
+		if old[offset] == new[offset] {
+			continue
+		}
+		end := offset
+		for end+1 < size && old[end+1] != new[end+1] {
+			end++
+		}
+		diffs = append(diffs, MemoryDiff{
+			Memory: memory,
+			Range:  AddressRange{Start: baseAddress + uint16(offset), End: baseAddress + uint16(end)},
+			Old:    append(HexBytes{}, old[offset:end+1]...),
+			New:    append(HexBytes{}, new[offset:end+1]...),
+		})
+		offset = end
+	}
+	return diffs
+}
+
+// Check if snapshots are the same (elapsed counters are not compared)
+func (d *SnapshotDiff) Empty() bool {
+	return len(d.CPU) == 0 && len(d.Memory) == 0 && len(d.Registers) == 0
+}
+
+// Write diff as indented JSON
+func (d *SnapshotDiff) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(d)
+}
+
+// Write diff as text report
+func (d *SnapshotDiff) WriteText(w io.Writer) error {
+	_, err := io.WriteString(w, d.String())
+	return err
+}
+
+// Diff formatted as text report
+func (d *SnapshotDiff) String() string {
+	var report strings.Builder
+	fmt.Fprintf(&report, "Elapsed: %d cycles, %d frames, %d instructions\n", d.Elapsed.Cycle, d.Elapsed.Frame, d.Elapsed.Instruction)
+	if d.Empty() {
+		report.WriteString("No changes\n")
+		return report.String()
+	}
+
+	if len(d.CPU) > 0 {
+		report.WriteString("CPU:\n")
+		for _, value := range d.CPU {
+			width := 2
+			if value.Name == "PC" {
+				width = 4
+			}
+			fmt.Fprintf(&report, "  %-3s $%0*x -> $%0*x\n", value.Name, width, value.Old, width, value.New)
+		}
+	}
+
+	if len(d.Memory) > 0 {
+		report.WriteString("Memory:\n")
+		for _, memory := range d.Memory {
+			fmt.Fprintf(&report, "  %-12s %s (%d bytes): %s -> %s\n",
+				memory.Memory, memory.Range, memory.Range.Size(), formatDiffBytes(memory.Old), formatDiffBytes(memory.New))
+		}
+	}
+
+	if len(d.Registers) > 0 {
+		report.WriteString("Registers:\n")
+		for _, register := range d.Registers {
+			fmt.Fprintf(&report, "  %-9s $%04x %-24s $%02x -> $%02x", register.Chip, register.Address, register.Name, register.Old, register.New)
+			if register.OldDescription != "" || register.NewDescription != "" {
+				fmt.Fprintf(&report, " (%s -> %s)", describeOrDash(register.OldDescription), describeOrDash(register.NewDescription))
+			}
+			report.WriteString("\n")
+		}
+	}
+
+	return report.String()
+}
+
+// Bytes formatted as '01 02 03', long ranges are truncated
+func formatDiffBytes(data []byte) string {
+	formatted := make([]string, 0, min(len(data), diffTextMaxBytes)+1)
+	for _, value := range data[:min(len(data), diffTextMaxBytes)] {
+		formatted = append(formatted, fmt.Sprintf("%02x", value))
+	}
+	if len(data) > diffTextMaxBytes {
+		formatted = append(formatted, "...")
+	}
+	return strings.Join(formatted, " ")
+}
+
+func describeOrDash(description string) string {
+	if description == "" {
+		return "-"
+	}
+	return description
+}
@@ -0,0 +1,331 @@
+package c64dws
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Version of the snapshot file format
+const SnapshotVersion = 1
+
+// Snapshot file header: magic followed by the version (uint16, little endian)
+const snapshotMagic = "C64DSNAP"
+
+// Snapshot file chunk IDs, every chunk is: ID (4 bytes), size (uint32, little endian), data
+const (
+	chunkCPU           = "CPU "
+	chunkCounters      = "CNTR"
+	chunkProcessorPort = "PORT"
+	chunkRAM           = "RAM "
+	chunkColorRAM      = "COLR"
+	chunkChip          = "CHIP" // chip number (1 byte) followed by registers
+	chunkDriveRAM      = "DRAM"
+)
+
+// Color RAM location and size
+const (
+	ColorRAMAddress uint16 = 0xd800
+	ColorRAMSize           = 0x0400
+)
+
+// Chips captured in snapshots
+var snapshotChips = append(append([]Chip{}, Chips...), DriveChips...)
+
+// Full machine state
+type Snapshot struct {
+	CPU           CPUState        // only PC, A, X, Y, SP and P are restored
+	Counters      CycleCounters   // counters can't be restored
+	ProcessorPort [2]byte         // $00 (data direction) and $01 (port) as seen by CPU
+	RAM           []byte          // 64KB of C64 RAM
+	ColorRAM      []byte          // 1KB of color RAM
+	Chips         map[Chip][]byte // VIC, SID, CIA and 1541 VIA registers, partially restored
+	DriveRAM      []byte          // 2KB of 1541 RAM
+}
+
+// Capture the machine state (sync mode), emulation is paused
+func (c *Client) Snapshot() (*Snapshot, error) {
+	if err := c.syncRequest(func(token string) error {
+		return c.PauseEmulation(token)
+	}); err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{Chips: map[Chip][]byte{}}
+	var err error
+	if snapshot.CPU, err = c.ReadCPUStatus(); err != nil {
+		return nil, err
+	}
+	if snapshot.Counters, err = c.ReadCPUCounters(); err != nil {
+		return nil, err
+	}
+	port, err := c.ReadMemory(MemorySpaceCPU, 0x0000, len(snapshot.ProcessorPort))
+	if err != nil {
+		return nil, err
+	}
+	copy(snapshot.ProcessorPort[:], port)
+	if snapshot.RAM, err = c.ReadMemory(MemorySpaceRAM, 0x0000, MemorySpaceRAM.Size()); err != nil {
+		return nil, err
+	}
+	if err := c.withMemoryConfig(memoryConfigIOPort, func() error {
+		snapshot.ColorRAM, err = c.ReadMemory(MemorySpaceCPU, ColorRAMAddress, ColorRAMSize)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	for _, chip := range snapshotChips {
+		if snapshot.Chips[chip], err = c.ReadChipRegisters(chip); err != nil {
+			return nil, err
+		}
+	}
+	if snapshot.DriveRAM, err = c.ReadMemory(MemorySpaceDrive1541RAM, 0x0000, MemorySpaceDrive1541RAM.Size()); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// Restore the machine state (sync mode), emulation is paused.
+// Registers are set with the SetRegisters trampoline before RAM is written,
+// so memory it uses is restored too. Chips missing in the snapshot are skipped,
+// chip registers are restored only partially, see restoreChipWrites.
+func (c *Client) Restore(snapshot *Snapshot) error {
+	if err := snapshot.validate(); err != nil {
+		return err
+	}
+
+	if err := c.SetRegisters(snapshot.CPU); err != nil {
+		return err
+	}
+	if err := c.WriteMemory(MemorySpaceRAM, 0x0000, snapshot.RAM); err != nil {
+		return err
+	}
+	if err := c.withMemoryConfig(memoryConfigIOPort, func() error {
+		return c.WriteMemory(MemorySpaceCPU, ColorRAMAddress, snapshot.ColorRAM)
+	}); err != nil {
+		return err
+	}
+	for _, chip := range snapshotChips {
+		registers, exist := snapshot.Chips[chip]
+		if !exist {
+			continue
+		}
+		for _, values := range restoreChipWrites(chip, registers) {
+			if err := c.syncRequest(func(token string) error {
+				return c.writeChip(chip, values, token)
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	if err := c.WriteMemory(MemorySpaceDrive1541RAM, 0x0000, snapshot.DriveRAM); err != nil {
+		return err
+	}
+
+	return c.WriteMemory(MemorySpaceCPU, 0x0000, snapshot.ProcessorPort[:])
+}
+
+// Chip register writes restoring captured registers, key is the register offset.
+// Registers reading back something else than what was written, or with write side
+// effects, are skipped or restored deliberately:
+//   - VIC: $d012 (reads the current raster line, writes the compare line), $d019
+//     (writing acknowledges interrupts) and $d01e-$d01f (read-only) are not restored
+//   - SID: nothing is restored, $d400-$d418 are write-only and $d419-$d41c read-only
+//   - CIA: TOD $x8-$xb (writing stops the clock or sets the alarm) and ICR $xd
+//     (reads the interrupt flags, writes the mask) are not restored; timer counters
+//     $x4-$x7 are written to the latches and force loaded with CRA / CRB bit 4,
+//     so the latches are left equal to the counters
+//   - VIA: timers $x4-$x5 and $x8-$x9 (writing starts them) and IFR $xd are not
+//     restored; IER $xe is cleared and set to the captured mask
+func restoreChipWrites(chip Chip, registers []byte) []map[int]byte {
+	values := func(offsets ...int) map[int]byte {
+		result := map[int]byte{}
+		for _, offset := range offsets {
+			result[offset] = registers[offset]
+		}
+		return result
+	}
+	span := func(first int, last int) []int {
+		var offsets []int
+		for offset := first; offset <= last; offset++ {
+			offsets = append(offsets, offset)
+		}
+		return offsets
+	}
+
+	switch chip {
+	case ChipVIC:
+		offsets := append(append(span(0x00, 0x11), span(0x13, 0x18)...), span(0x1a, 0x1d)...)
+		return []map[int]byte{values(append(offsets, span(0x20, 0x2e)...)...)}
+	case ChipCIA1, ChipCIA2:
+		forceLoad := map[int]byte{0x0e: registers[0x0e] | 0x10, 0x0f: registers[0x0f] | 0x10}
+		return []map[int]byte{values(0x00, 0x01, 0x02, 0x03, 0x0c), values(span(0x04, 0x07)...), forceLoad}
+	case ChipDrive1541VIA1, ChipDrive1541VIA2:
+		return []map[int]byte{
+			values(0x00, 0x01, 0x02, 0x03, 0x06, 0x07, 0x0a, 0x0b, 0x0c, 0x0f),
+			{0x0e: 0x7f},
+			{0x0e: registers[0x0e] | 0x80},
+		}
+	default:
+		return nil
+	}
+}
+
+// $01 bits 0-2 banking in I/O with RAM elsewhere, used to access color RAM
+const memoryConfigIOPort = 0x05
+
+func (s *Snapshot) validate() error {
+	if len(s.RAM) != MemorySpaceRAM.Size() {
+		return fmt.Errorf("Invalid snapshot RAM size %d", len(s.RAM))
+	}
+	if len(s.ColorRAM) != ColorRAMSize {
+		return fmt.Errorf("Invalid snapshot color RAM size %d", len(s.ColorRAM))
+	}
+	if len(s.DriveRAM) != MemorySpaceDrive1541RAM.Size() {
+		return fmt.Errorf("Invalid snapshot 1541 RAM size %d", len(s.DriveRAM))
+	}
+	for chip, registers := range s.Chips {
+		if len(registers) != chip.RegistersCount() {
+			return fmt.Errorf("Invalid snapshot %s registers count %d", chip, len(registers))
+		}
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------
+// Snapshot file format
+// ----------------------------------------------------------------------
+
+// Write snapshot in the versioned file format
+func (s *Snapshot) Write(w io.Writer) error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+
+	buffer := bufio.NewWriter(w)
+	buffer.WriteString(snapshotMagic)
+	binary.Write(buffer, binary.LittleEndian, uint16(SnapshotVersion))
+
+	writeChunk := func(id string, data []byte) {
+		buffer.WriteString(id)
+		binary.Write(buffer, binary.LittleEndian, uint32(len(data)))
+		buffer.Write(data)
+	}
+	writeStruct := func(id string, value any) {
+		var data bytes.Buffer
+		binary.Write(&data, binary.LittleEndian, value)
+		writeChunk(id, data.Bytes())
+	}
+
+	writeStruct(chunkCPU, s.CPU)
+	writeStruct(chunkCounters, s.Counters)
+	writeChunk(chunkProcessorPort, s.ProcessorPort[:])
+	writeChunk(chunkRAM, s.RAM)
+	writeChunk(chunkColorRAM, s.ColorRAM)
+	for _, chip := range snapshotChips {
+		if registers, exist := s.Chips[chip]; exist {
+			writeChunk(chunkChip, append([]byte{byte(chip)}, registers...))
+		}
+	}
+	writeChunk(chunkDriveRAM, s.DriveRAM)
+
+	return buffer.Flush()
+}
+
+// Read snapshot written with Snapshot.Write, unknown chunks are skipped
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	reader := bufio.NewReader(r)
+	header := make([]byte, len(snapshotMagic)+2)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("Invalid snapshot header: %w", err)
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return nil, fmt.Errorf("Not a snapshot file")
+	}
+	if version := binary.LittleEndian.Uint16(header[len(snapshotMagic):]); version > SnapshotVersion {
+		return nil, fmt.Errorf("Unsupported snapshot version %d", version)
+	}
+
+	snapshot := &Snapshot{Chips: map[Chip][]byte{}}
+	chunkHeader := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(reader, chunkHeader); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("Invalid snapshot chunk: %w", err)
+		}
+		id := string(chunkHeader[:4])
+		data := make([]byte, binary.LittleEndian.Uint32(chunkHeader[4:]))
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, fmt.Errorf("Invalid snapshot chunk '%s': %w", id, err)
+		}
+
+		var err error
+		switch id {
+		case chunkCPU:
+			err = readStruct(data, &snapshot.CPU)
+		case chunkCounters:
+			err = readStruct(data, &snapshot.Counters)
+		case chunkProcessorPort:
+			if copy(snapshot.ProcessorPort[:], data) != len(snapshot.ProcessorPort) {
+				err = fmt.Errorf("Invalid processor port chunk")
+			}
+		case chunkRAM:
+			snapshot.RAM = data
+		case chunkColorRAM:
+			snapshot.ColorRAM = data
+		case chunkChip:
+			if len(data) == 0 {
+				err = fmt.Errorf("Invalid chip chunk")
+			} else {
+				snapshot.Chips[Chip(data[0])] = data[1:]
+			}
+		case chunkDriveRAM:
+			snapshot.DriveRAM = data
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := snapshot.validate(); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+func readStruct(data []byte, target any) error {
+	if len(data) != binary.Size(target) {
+		return fmt.Errorf("Invalid snapshot chunk size %d", len(data))
+	}
+	return binary.Read(bytes.NewReader(data), binary.LittleEndian, target)
+}
+
+// Save snapshot to the file
+func (s *Snapshot) SaveFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := s.Write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Load snapshot from the file
+func LoadSnapshotFile(path string) (*Snapshot, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadSnapshot(file)
+}
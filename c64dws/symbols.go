@@ -0,0 +1,82 @@
+package c64dws
+
+import (
+	"fmt"
+
+	"github.com/mojzesh/c64d-ws-client/symbols"
+)
+
+// Set symbol table used by name based addressing
+func (c *Client) SetSymbols(symbolTable *symbols.SymbolTable) {
+	c.symbolTable = symbolTable
+}
+
+// Get symbol table used by name based addressing, nil if not set
+func (c *Client) Symbols() *symbols.SymbolTable {
+	return c.symbolTable
+}
+
+// Load symbol file (KickAssembler .sym/.vs, VICE labels or ACME label dump)
+// and merge it into the client symbol table
+func (c *Client) LoadSymbols(path string) error {
+	symbolTable, err := symbols.LoadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if c.symbolTable == nil {
+		c.symbolTable = symbols.NewSymbolTable()
+	}
+	c.symbolTable.Merge(symbolTable)
+
+	return nil
+}
+
+// Resolve symbol name (e.g. 'main', 'main+3') or number (e.g. '$0810') into address
+func (c *Client) ResolveAddress(name string) (uint16, error) {
+	return c.symbolTable.Resolve(name)
+}
+
+// Describe address with the nearest symbol, e.g. '$0813 (main+3)'
+func (c *Client) DescribeAddress(address uint16) string {
+	if label := c.symbolTable.Annotate(address); label != "" {
+		return fmt.Sprintf("$%04x (%s)", address, label)
+	}
+	return fmt.Sprintf("$%04x", address)
+}
+
+// Add CPU breakpoint at symbol
+func (c *Client) AddCPUBreakpointByName(name string, token ...string) error {
+	address, err := c.ResolveAddress(name)
+	if err != nil {
+		return err
+	}
+	return c.AddCPUBreakpoint(address, token...)
+}
+
+// Remove CPU breakpoint at symbol
+func (c *Client) RemoveCPUBreakpointByName(name string, token ...string) error {
+	address, err := c.ResolveAddress(name)
+	if err != nil {
+		return err
+	}
+	return c.RemoveCPUBreakpoint(address, token...)
+}
+
+// Make CPU JMP to symbol
+func (c *Client) CPUMakeJMPByName(name string, token ...string) error {
+	address, err := c.ResolveAddress(name)
+	if err != nil {
+		return err
+	}
+	return c.CPUMakeJMP(address, token...)
+}
+
+// Read RAM block starting at symbol
+func (c *Client) RAMReadBlockByName(name string, size uint16, token ...string) error {
+	address, err := c.ResolveAddress(name)
+	if err != nil {
+		return err
+	}
+	return c.RAMReadBlock(address, size, token...)
+}
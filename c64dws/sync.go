@@ -0,0 +1,261 @@
+package c64dws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// --------------------------------------------------------------
+// Sync mode
+//
+// The first call to Request or SubscribeEvents starts a background reader
+// which routes responses to waiting requests (matched by token) and server
+// events to subscribers. From that moment messages must not be read with
+// ReceiveMessage anymore.
+// --------------------------------------------------------------
+
+// Default time to wait for a response in sync mode
+const DefaultRequestTimeout = 5 * time.Second
+
+// Size of the per subscriber event buffer, oldest events are dropped when it's full
+const eventsBufferSize = 256
+
+// Routes incoming messages in sync mode
+type dispatcher struct {
+	mutex       sync.Mutex
+	started     bool
+	waiting     map[string]chan any
+	subscribers map[int]chan any
+	nextID      int
+	err         error
+	done        chan struct{}
+	timeout     time.Duration
+}
+
+// Set the time to wait for a response in sync mode
+func (c *Client) SetRequestTimeout(timeout time.Duration) {
+	c.dispatcher.mutex.Lock()
+	defer c.dispatcher.mutex.Unlock()
+	c.dispatcher.timeout = timeout
+}
+
+// Start background reader if it's not running yet
+func (c *Client) startDispatcher() *dispatcher {
+	d := &c.dispatcher
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if !d.started {
+		d.started = true
+		d.waiting = map[string]chan any{}
+		d.subscribers = map[int]chan any{}
+		d.done = make(chan struct{})
+		if d.timeout == 0 {
+			d.timeout = DefaultRequestTimeout
+		}
+		go c.dispatchMessages()
+	}
+
+	return d
+}
+
+// Read messages and route them to waiting requests and event subscribers
+func (c *Client) dispatchMessages() {
+	d := &c.dispatcher
+	for {
+		msgType, msg, err := c.ReceiveMessage()
+		if err != nil {
+			d.mutex.Lock()
+			d.err = err
+			close(d.done)
+			d.mutex.Unlock()
+			return
+		}
+
+		switch msgType {
+		case C64DRequestResponse:
+			var token string
+			switch msg := msg.(type) {
+			case RequestResult:
+				token = msg.Token
+			case RequestError:
+				token = msg.Token
+			}
+			d.mutex.Lock()
+			if responseChan, exist := d.waiting[token]; exist {
+				delete(d.waiting, token)
+				responseChan <- msg
+			}
+			d.mutex.Unlock()
+		case C64DServerEvent:
+			d.mutex.Lock()
+			for _, eventsChan := range d.subscribers {
+				publishEvent(eventsChan, msg)
+			}
+			d.mutex.Unlock()
+		}
+	}
+}
+
+// Publish event without blocking the reader, drops the oldest event if subscriber is too slow
+func publishEvent(eventsChan chan any, event any) {
+	for {
+		select {
+		case eventsChan <- event:
+			return
+		default:
+			select {
+			case <-eventsChan:
+			default:
+			}
+		}
+	}
+}
+
+// Send request and wait for its response (sync mode).
+// The send function gets a unique token which has to be passed to the API call, e.g.:
+//
+//	result, err := client.Request(func(token string) error {
+//		return client.CPUStatus(token)
+//	})
+func (c *Client) Request(send func(token string) error) (*RequestResult, error) {
+	d := c.startDispatcher()
+
+	token := c.GetToken()
+	responseChan := make(chan any, 1)
+
+	d.mutex.Lock()
+	if d.err != nil {
+		d.mutex.Unlock()
+		return nil, d.err
+	}
+	d.waiting[token] = responseChan
+	timeout := d.timeout
+	d.mutex.Unlock()
+
+	removeWaiting := func() {
+		d.mutex.Lock()
+		delete(d.waiting, token)
+		d.mutex.Unlock()
+	}
+
+	if err := send(token); err != nil {
+		removeWaiting()
+		return nil, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case msg := <-responseChan:
+		requestResult, requestError := GetResultOrError(msg)
+		if requestError != nil {
+			return nil, &RequestFailedError{Status: requestError.Status, Message: requestError.Error}
+		}
+		return requestResult, nil
+	case <-d.done:
+		removeWaiting()
+		return nil, errors.Join(errors.New("Connection closed"), d.err)
+	case <-timer.C:
+		removeWaiting()
+		return nil, fmt.Errorf("No response for request '%s' within %v", token, timeout)
+	}
+}
+
+// Error response to a request sent in sync mode
+type RequestFailedError struct {
+	Status  int
+	Message string
+}
+
+func (e *RequestFailedError) Error() string {
+	return fmt.Sprintf("Request failed with status %d: %s", e.Status, e.Message)
+}
+
+// Send request and wait for its response, result is discarded (sync mode)
+func (c *Client) syncRequest(send func(token string) error) error {
+	_, err := c.Request(send)
+	return err
+}
+
+// Time to wait for a response or an event in sync mode
+func (c *Client) requestTimeout() time.Duration {
+	d := &c.dispatcher
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.timeout == 0 {
+		return DefaultRequestTimeout
+	}
+	return d.timeout
+}
+
+// Subscribe to server events (sync mode), call the returned function to unsubscribe
+func (c *Client) SubscribeEvents() (<-chan any, func()) {
+	d := c.startDispatcher()
+
+	eventsChan := make(chan any, eventsBufferSize)
+
+	d.mutex.Lock()
+	id := d.nextID
+	d.nextID++
+	d.subscribers[id] = eventsChan
+	d.mutex.Unlock()
+
+	return eventsChan, func() {
+		d.mutex.Lock()
+		delete(d.subscribers, id)
+		d.mutex.Unlock()
+	}
+}
+
+// Wait for the first event accepted by the match function, other events are discarded
+func (c *Client) WaitForEvent(ctx context.Context, events <-chan any, match func(event any) bool) (any, error) {
+	d := c.startDispatcher()
+
+	for {
+		select {
+		case event := <-events:
+			if match == nil || match(event) {
+				return event, nil
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-d.done:
+			return nil, errors.Join(errors.New("Connection closed"), d.err)
+		}
+	}
+}
+
+// Check if the event is a raster breakpoint event
+func IsRasterBreakpointEvent(event any) bool {
+	_, ok := event.(RasterBreakpointEvent)
+	return ok
+}
+
+// Check if the event is a CPU (address) breakpoint event
+func IsCPUBreakpointEvent(event any) bool {
+	return breakpointEventType(event) == BreakpointEventCPUAddr
+}
+
+// Check if the event is a CPU memory (data) breakpoint event
+func IsMemoryBreakpointEvent(event any) bool {
+	return breakpointEventType(event) == BreakpointEventCPUData
+}
+
+func breakpointEventType(event any) BreakpointEventType {
+	switch event := event.(type) {
+	case RasterBreakpointEvent:
+		return BreakpointEventType(event.Type)
+	case CPUAddrBreakpointEvent:
+		return BreakpointEventType(event.Type)
+	case CPUDataBreakpointEvent:
+		return BreakpointEventType(event.Type)
+	case BreakpointEventBase:
+		return BreakpointEventType(event.Type)
+	}
+	return ""
+}
@@ -0,0 +1,175 @@
+package c64dws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/mojzesh/c64d-ws-client/asm6502"
+)
+
+// Trace log formats
+type TraceFormat int
+
+const (
+	TraceFormatVICE  TraceFormat = iota // text, compatible with VICE CPU history ('chis') output
+	TraceFormatJSONL                    // one JSON object per line
+)
+
+// Trace options
+type TraceOptions struct {
+	Output          io.Writer      // trace log destination, nil disables logging
+	Format          TraceFormat    // trace log format
+	Ranges          []AddressRange // record only instructions within ranges, all instructions when empty
+	MaxInstructions int            // stop after executing this many instructions, 0 means no limit
+	StopAt          []uint16       // stop when PC reaches one of the addresses (breakpoint)
+}
+
+// Why the trace has stopped
+type TraceStopReason string
+
+const (
+	TraceStopMaxInstructions TraceStopReason = "maxInstructions"
+	TraceStopBreakpoint      TraceStopReason = "breakpoint"
+	TraceStopCancelled       TraceStopReason = "cancelled"
+)
+
+// Single traced instruction, registers are captured before the instruction is executed
+type TraceEntry struct {
+	PC          uint16 `json:"pc"`
+	Bytes       string `json:"bytes"`
+	Disassembly string `json:"disassembly"`
+	Label       string `json:"label,omitempty"`
+	A           uint8  `json:"a"`
+	X           uint8  `json:"x"`
+	Y           uint8  `json:"y"`
+	SP          uint8  `json:"sp"`
+	P           uint8  `json:"p"`
+	Flags       string `json:"flags"`
+	Cycle       uint64 `json:"cycle"`
+	Frame       uint64 `json:"frame"`
+	RasterX     uint16 `json:"rasterX"`
+	RasterY     uint16 `json:"rasterY"`
+}
+
+// Trace summary
+type TraceResult struct {
+	Executed   int             // number of executed instructions
+	Recorded   int             // number of instructions written to the trace log
+	LastPC     uint16          // PC after the last executed instruction
+	StopReason TraceStopReason // why the trace has stopped
+}
+
+// Trace execution instruction by instruction (sync mode).
+// Emulation should be paused, every instruction is executed with StepInstruction.
+func (c *Client) Trace(ctx context.Context, opts TraceOptions) (TraceResult, error) {
+	var result TraceResult
+
+	for {
+		if ctx.Err() != nil {
+			result.StopReason = TraceStopCancelled
+			return result, nil
+		}
+
+		state, err := c.ReadCPUStatus()
+		if err != nil {
+			return result, err
+		}
+		result.LastPC = state.PC
+
+		if result.Executed > 0 && slices.Contains(opts.StopAt, state.PC) {
+			result.StopReason = TraceStopBreakpoint
+			return result, nil
+		}
+		if opts.MaxInstructions > 0 && result.Executed >= opts.MaxInstructions {
+			result.StopReason = TraceStopMaxInstructions
+			return result, nil
+		}
+
+		if opts.Output != nil && inRanges(opts.Ranges, state.PC) {
+			entry, err := c.traceEntry(state)
+			if err != nil {
+				return result, err
+			}
+			if err := writeTraceEntry(opts.Output, opts.Format, entry); err != nil {
+				return result, err
+			}
+			result.Recorded++
+		}
+
+		if _, err := c.Request(func(token string) error {
+			return c.StepInstruction(token)
+		}); err != nil {
+			return result, err
+		}
+		result.Executed++
+	}
+}
+
+// Collect instruction bytes and counters for the trace entry
+func (c *Client) traceEntry(state CPUState) (TraceEntry, error) {
+	counters, err := c.ReadCPUCounters()
+	if err != nil {
+		return TraceEntry{}, err
+	}
+
+	size := min(3, 0x10000-int(state.PC))
+	code, err := c.ReadMemory(MemorySpaceCPU, state.PC, size)
+	if err != nil {
+		return TraceEntry{}, err
+	}
+	instruction := asm6502.Disassemble(state.PC, code)
+
+	return TraceEntry{
+		PC:          state.PC,
+		Bytes:       instruction.HexBytes(),
+		Disassembly: instruction.String(),
+		Label:       c.symbolTable.Annotate(state.PC),
+		A:           state.A,
+		X:           state.X,
+		Y:           state.Y,
+		SP:          state.SP,
+		P:           state.P,
+		Flags:       state.FlagsString(),
+		Cycle:       counters.Cycle,
+		Frame:       counters.Frame,
+		RasterX:     state.RasterX,
+		RasterY:     state.RasterY,
+	}, nil
+}
+
+// Write single trace entry in the requested format
+func writeTraceEntry(w io.Writer, format TraceFormat, entry TraceEntry) error {
+	switch format {
+	case TraceFormatJSONL:
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+	default:
+		_, err := io.WriteString(w, FormatTraceEntryVICE(entry)+"\n")
+		return err
+	}
+}
+
+// Format trace entry like VICE CPU history, e.g.
+// '.C:0816  4C 16 08  JMP $0816      - A:00 X:00 Y:00 SP:f3 ..-..I..   12345678'
+func FormatTraceEntryVICE(entry TraceEntry) string {
+	return fmt.Sprintf(
+		".C:%04x  %-8s  %-14s - A:%02x X:%02x Y:%02x SP:%02x %s %10d",
+		entry.PC,
+		strings.ToUpper(entry.Bytes),
+		strings.ToUpper(entry.Disassembly),
+		entry.A,
+		entry.X,
+		entry.Y,
+		entry.SP,
+		entry.Flags,
+		entry.Cycle,
+	)
+}
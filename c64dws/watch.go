@@ -0,0 +1,214 @@
+package c64dws
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Default polling interval of the memory watch
+const DefaultWatchInterval = 20 * time.Millisecond
+
+// Default number of buffered watch events
+const DefaultWatchBuffer = 256
+
+// Memory watch options
+type WatchOptions struct {
+	Space      MemorySpace   // watched memory space
+	Interval   time.Duration // polling interval, DefaultWatchInterval when 0; unused when EveryFrame is set
+	EveryFrame bool          // poll every frame, synchronized with a raster breakpoint
+	RasterLine uint8         // raster line of the breakpoint (EveryFrame)
+	MaxGap     int           // ranges separated by at most MaxGap bytes are read by a single request
+	Buffer     int           // number of buffered events, DefaultWatchBuffer when 0
+}
+
+// Change of a watched byte
+type WatchEvent struct {
+	Address uint16
+	Old     uint8
+	New     uint8
+	Frame   uint64 // frame counter when the change was detected
+}
+
+// Polls memory ranges and reports changed bytes
+type Watcher struct {
+	client *Client
+	opts   WatchOptions
+	ranges []AddressRange // watched ranges
+	reads  []AddressRange // merged ranges read by block requests
+	data   map[uint16][]byte
+	events chan WatchEvent
+}
+
+// Sort ranges and merge overlapping ones and ranges separated by at most gap bytes
+func MergeRanges(ranges []AddressRange, gap int) []AddressRange {
+	sorted := append([]AddressRange{}, ranges...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Start < sorted[j].Start
+	})
+
+	merged := []AddressRange{}
+	for _, r := range sorted {
+		if len(merged) > 0 {
+			last := &merged[len(merged)-1]
+			if int(r.Start) <= int(last.End)+gap+1 {
+				last.End = max(last.End, r.End)
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// Create watcher of the memory ranges
+func (c *Client) NewWatcher(ranges []AddressRange, opts WatchOptions) (*Watcher, error) {
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("No ranges to watch")
+	}
+	for _, r := range ranges {
+		if r.End < r.Start {
+			return nil, fmt.Errorf("Invalid range %s", r)
+		}
+		if err := checkMemoryBounds(opts.Space, r.Start, r.Size()); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultWatchInterval
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = DefaultWatchBuffer
+	}
+
+	return &Watcher{
+		client: c,
+		opts:   opts,
+		ranges: ranges,
+		reads:  MergeRanges(ranges, max(opts.MaxGap, 0)),
+		data:   map[uint16][]byte{},
+		events: make(chan WatchEvent, opts.Buffer),
+	}, nil
+}
+
+// Change events, the channel is closed when Run returns
+func (w *Watcher) Events() <-chan WatchEvent {
+	return w.events
+}
+
+// Number of block read requests per poll
+func (w *Watcher) Reads() int {
+	return len(w.reads)
+}
+
+// Poll memory and send change events until ctx is done (sync mode).
+// The first poll reads initial values, emulation keeps running when Run returns.
+func (w *Watcher) Run(ctx context.Context) error {
+	defer close(w.events)
+	if _, err := w.poll(ctx); err != nil {
+		return err
+	}
+	if w.opts.EveryFrame {
+		return w.runEveryFrame(ctx)
+	}
+
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if err := w.pollAndSend(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+func (w *Watcher) runEveryFrame(ctx context.Context) error {
+	c := w.client
+	events, unsubscribe := c.SubscribeEvents()
+	defer unsubscribe()
+
+	if err := c.syncRequest(func(token string) error {
+		return c.AddRasterBreakpoint(w.opts.RasterLine, token)
+	}); err != nil {
+		return err
+	}
+	defer c.syncRequest(func(token string) error {
+		return c.ContinueEmulation(token)
+	})
+	defer c.syncRequest(func(token string) error {
+		return c.RemoveRasterBreakpoint(w.opts.RasterLine, token)
+	})
+
+	for {
+		if err := c.syncRequest(func(token string) error {
+			return c.ContinueEmulation(token)
+		}); err != nil {
+			return err
+		}
+		if _, err := c.WaitForEvent(ctx, events, IsRasterBreakpointEvent); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if err := w.pollAndSend(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+func (w *Watcher) pollAndSend(ctx context.Context) error {
+	changes, err := w.poll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		select {
+		case w.events <- change:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
+
+// Read merged ranges and compare watched bytes with the previous poll
+func (w *Watcher) poll(ctx context.Context) ([]WatchEvent, error) {
+	var changes []WatchEvent
+	var frame uint64
+	for _, r := range w.reads {
+		data, err := w.client.ReadMemory(w.opts.Space, r.Start, r.Size())
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, nil
+			}
+			return nil, err
+		}
+
+		previous, exist := w.data[r.Start]
+		w.data[r.Start] = data
+		if !exist {
+			continue
+		}
+		for offset, value := range data {
+			address := r.Start + uint16(offset)
+			if value == previous[offset] || !inRanges(w.ranges, address) {
+				continue
+			}
+			if changes == nil {
+				counters, err := w.client.ReadCPUCounters()
+				if err != nil {
+					return nil, err
+				}
+				frame = counters.Frame
+			}
+			changes = append(changes, WatchEvent{Address: address, Old: previous[offset], New: value, Frame: frame})
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,47 @@
+// VICE binary monitor protocol bridge for the Retro Debugger.
+//
+// Usage:
+//
+//	c64dws-binmon [-host localhost] [-port 3563] [-listen :6502]
+//
+// Tools speaking the VICE binary monitor protocol connect to the listen address,
+// their commands are translated to Retro Debugger WebSocket API calls.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/mojzesh/c64d-ws-client/binmon"
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+)
+
+func main() {
+	host := flag.String("host", c64dws.WS_HOST, "Retro Debugger host")
+	port := flag.Int("port", c64dws.WS_PORT_0x0DEB, "Retro Debugger WebSocket port")
+	listen := flag.String("listen", fmt.Sprintf("127.0.0.1:%d", binmon.DefaultPort), "binary monitor listen address")
+	flag.Parse()
+
+	// -------------------------------------------------------------
+	// Connect to C64D WebSocket Server
+	// -------------------------------------------------------------
+	hostDesc, err := c64dws.GetCustomHost(*host, *port, c64dws.WS_SCHEME)
+	if err != nil {
+		log.Fatal(err)
+	}
+	client := c64dws.NewCustomClient(c64dws.EmulatorC64, c64dws.StreamAPI, c64dws.TokenTypeAutoIncrement, c64dws.WS_DEFAULT_TOKEN_FORMAT, hostDesc)
+	if _, err := client.Connect(); err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+
+	// -------------------------------------------------------------
+	// Serve binary monitor connections
+	// -------------------------------------------------------------
+	log.Printf("Binary monitor listening on %s", *listen)
+	server := binmon.NewServer(client)
+	if err := server.ListenAndServe(*listen); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,26 @@
+// Debug Adapter Protocol server for the Retro Debugger, speaks DAP over stdio.
+//
+// Usage:
+//
+//	c64dws-dap
+//
+// Connection to the Retro Debugger is configured by the launch or attach request,
+// see README for the VS Code launch configuration.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/mojzesh/c64d-ws-client/dap"
+)
+
+func main() {
+	// stdout is used by the protocol
+	log.SetOutput(os.Stderr)
+
+	server := dap.NewServer(os.Stdin, os.Stdout)
+	if err := server.Serve(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,46 @@
+// GDB remote serial protocol stub for the Retro Debugger.
+//
+// Usage:
+//
+//	c64dws-gdb [-host localhost] [-port 3563] [-listen :2345]
+//
+// Debuggers with 6502 support connect with 'target remote localhost:2345'.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"github.com/mojzesh/c64d-ws-client/gdbstub"
+)
+
+func main() {
+	host := flag.String("host", c64dws.WS_HOST, "Retro Debugger host")
+	port := flag.Int("port", c64dws.WS_PORT_0x0DEB, "Retro Debugger WebSocket port")
+	listen := flag.String("listen", fmt.Sprintf("127.0.0.1:%d", gdbstub.DefaultPort), "GDB stub listen address")
+	flag.Parse()
+
+	// -------------------------------------------------------------
+	// Connect to C64D WebSocket Server
+	// -------------------------------------------------------------
+	hostDesc, err := c64dws.GetCustomHost(*host, *port, c64dws.WS_SCHEME)
+	if err != nil {
+		log.Fatal(err)
+	}
+	client := c64dws.NewCustomClient(c64dws.EmulatorC64, c64dws.StreamAPI, c64dws.TokenTypeAutoIncrement, c64dws.WS_DEFAULT_TOKEN_FORMAT, hostDesc)
+	if _, err := client.Connect(); err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+
+	// -------------------------------------------------------------
+	// Serve GDB connections
+	// -------------------------------------------------------------
+	log.Printf("GDB stub listening on %s", *listen)
+	server := gdbstub.NewServer(client)
+	if err := server.ListenAndServe(*listen); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,201 @@
+// Machine code monitor for the Retro Debugger, modeled on the VICE / Action Replay monitors.
+//
+// Usage:
+//
+//	c64dws-mon [-host localhost] [-port 3563] [-script file] [-style ar|kickass]
+//
+// Type 'help' in the monitor for the list of commands.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"github.com/mojzesh/c64d-ws-client/monitor"
+)
+
+const prompt = "(C:$%04x) "
+
+// Maximum number of lines kept in the history file
+const maxHistoryLines = 1000
+
+func main() {
+	os.Exit(run())
+}
+
+// Run the monitor and return the exit code, deferred cleanup (history, connection)
+// is done before exiting
+func run() int {
+	host := flag.String("host", c64dws.WS_HOST, "Retro Debugger host")
+	port := flag.Int("port", c64dws.WS_PORT_0x0DEB, "Retro Debugger WebSocket port")
+	script := flag.String("script", "", "execute commands from the file")
+	interactive := flag.Bool("i", false, "stay interactive after the script is executed")
+	styleName := flag.String("style", "ar", "memory dump style: ar or kickass")
+	columns := flag.Int("columns", monitor.DefaultDumpColumns, "bytes per memory dump line")
+	symbolsPath := flag.String("symbols", "", "load symbol file")
+	historyPath := flag.String("history", defaultHistoryPath(), "history file, empty disables history")
+	noPause := flag.Bool("nopause", false, "don't pause emulation on start")
+	flag.Parse()
+
+	style, err := monitor.ParseDumpStyle(*styleName)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	// -------------------------------------------------------------
+	// Connect to C64D WebSocket Server
+	// -------------------------------------------------------------
+	hostDesc, err := c64dws.GetCustomHost(*host, *port, c64dws.WS_SCHEME)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	client := c64dws.NewCustomClient(c64dws.EmulatorC64, c64dws.StreamAPI, c64dws.TokenTypeAutoIncrement, c64dws.WS_DEFAULT_TOKEN_FORMAT, hostDesc)
+	if _, err := client.Connect(); err != nil {
+		log.Print(err)
+		return 1
+	}
+	defer client.Close()
+
+	if *symbolsPath != "" {
+		if err := client.LoadSymbols(*symbolsPath); err != nil {
+			log.Print(err)
+			return 1
+		}
+	}
+
+	if !*noPause {
+		if _, err := client.Request(func(token string) error {
+			return client.PauseEmulation(token)
+		}); err != nil {
+			log.Print(err)
+			return 1
+		}
+	}
+
+	mon := monitor.New(client, os.Stdout)
+	mon.SetDumpStyle(style, *columns)
+	mon.SetHistory(loadHistory(*historyPath))
+	defer saveHistory(*historyPath, mon)
+
+	// -------------------------------------------------------------
+	// Script, Ctrl-C stops it
+	// -------------------------------------------------------------
+	if *script != "" {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		err := mon.RunScript(ctx, *script)
+		stop()
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+		if !*interactive {
+			return 0
+		}
+	}
+
+	// -------------------------------------------------------------
+	// Interactive mode, Ctrl-C interrupts the current command,
+	// at the prompt it exits
+	// -------------------------------------------------------------
+	lines := readLines(os.Stdin)
+	for {
+		printPrompt(client)
+		line, ok := nextLine(lines)
+		if !ok {
+			fmt.Println()
+			return 0
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		err := mon.Execute(ctx, line)
+		stop()
+
+		if errors.Is(err, monitor.ErrQuit) {
+			return 0
+		}
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+		}
+	}
+}
+
+// Read input lines in the background, so waiting for a line can be interrupted
+func readLines(input io.Reader) <-chan string {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(input)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	return lines
+}
+
+// Wait for the next input line, false at the end of input or on Ctrl-C
+func nextLine(lines <-chan string) (string, bool) {
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	select {
+	case line, ok := <-lines:
+		return line, ok
+	case <-interrupts:
+		return "", false
+	}
+}
+
+// Prompt shows current PC
+func printPrompt(client *c64dws.Client) {
+	state, err := client.ReadCPUStatus()
+	if err != nil {
+		fmt.Print("(C:????) ")
+		return
+	}
+	fmt.Printf(prompt, state.PC)
+}
+
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".c64dws_mon_history")
+}
+
+func loadHistory(path string) []string {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || len(strings.TrimSpace(string(data))) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+}
+
+func saveHistory(path string, mon *monitor.Monitor) {
+	if path == "" {
+		return
+	}
+	history := mon.History()
+	if len(history) > maxHistoryLines {
+		history = history[len(history)-maxHistoryLines:]
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(history, "\n")+"\n"), 0600); err != nil {
+		log.Print(err)
+	}
+}
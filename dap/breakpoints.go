@@ -0,0 +1,198 @@
+package dap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mojzesh/c64d-ws-client/symbols"
+)
+
+func (s *Server) setBreakpoints(request *Request) (any, error) {
+	var arguments SetBreakpointsArguments
+	if err := decodeArguments(request, &arguments); err != nil {
+		return nil, err
+	}
+
+	path := arguments.Source.Path
+	if err := s.sources.AddFile(path); err != nil {
+		return nil, err
+	}
+
+	var addresses []uint16
+	breakpoints := make([]Breakpoint, 0, len(arguments.Breakpoints))
+	for _, sourceBreakpoint := range arguments.Breakpoints {
+		breakpoint := Breakpoint{Source: &arguments.Source, Line: sourceBreakpoint.Line}
+
+		label, exist := s.sources.LabelAt(path, sourceBreakpoint.Line)
+		if !exist {
+			breakpoint.Message = "No label on this line, breakpoints can be set only on labeled lines"
+		} else if address, exist := lookupLabel(s.client.Symbols(), label); !exist {
+			breakpoint.Message = fmt.Sprintf("Label '%s' not found in symbol files", label)
+		} else {
+			breakpoint.Verified = true
+			breakpoint.InstructionReference = formatReference(address)
+			addresses = append(addresses, address)
+		}
+		breakpoints = append(breakpoints, breakpoint)
+	}
+
+	s.mutex.Lock()
+	s.sourceBreakpoints[path] = addresses
+	s.mutex.Unlock()
+	if err := s.syncBreakpoints(); err != nil {
+		return nil, err
+	}
+
+	return map[string]any{"breakpoints": breakpoints}, nil
+}
+
+func (s *Server) setFunctionBreakpoints(request *Request) (any, error) {
+	var arguments SetFunctionBreakpointsArguments
+	if err := decodeArguments(request, &arguments); err != nil {
+		return nil, err
+	}
+
+	var addresses []uint16
+	breakpoints := make([]Breakpoint, 0, len(arguments.Breakpoints))
+	for _, functionBreakpoint := range arguments.Breakpoints {
+		breakpoint := Breakpoint{}
+		if address, err := s.client.ResolveAddress(functionBreakpoint.Name); err != nil {
+			breakpoint.Message = err.Error()
+		} else {
+			breakpoint.Verified = true
+			breakpoint.InstructionReference = formatReference(address)
+			s.setSourceLocation(&breakpoint.Source, &breakpoint.Line, address)
+			addresses = append(addresses, address)
+		}
+		breakpoints = append(breakpoints, breakpoint)
+	}
+
+	s.mutex.Lock()
+	s.functionBPs = addresses
+	s.mutex.Unlock()
+	if err := s.syncBreakpoints(); err != nil {
+		return nil, err
+	}
+
+	return map[string]any{"breakpoints": breakpoints}, nil
+}
+
+func (s *Server) setInstructionBreakpoints(request *Request) (any, error) {
+	var arguments SetInstructionBreakpointsArguments
+	if err := decodeArguments(request, &arguments); err != nil {
+		return nil, err
+	}
+
+	var addresses []uint16
+	breakpoints := make([]Breakpoint, 0, len(arguments.Breakpoints))
+	for _, instructionBreakpoint := range arguments.Breakpoints {
+		breakpoint := Breakpoint{}
+		if address, err := s.parseReference(instructionBreakpoint.InstructionReference, instructionBreakpoint.Offset); err != nil {
+			breakpoint.Message = err.Error()
+		} else {
+			breakpoint.Verified = true
+			breakpoint.InstructionReference = formatReference(address)
+			addresses = append(addresses, address)
+		}
+		breakpoints = append(breakpoints, breakpoint)
+	}
+
+	s.mutex.Lock()
+	s.instructionBPs = addresses
+	s.mutex.Unlock()
+	if err := s.syncBreakpoints(); err != nil {
+		return nil, err
+	}
+
+	return map[string]any{"breakpoints": breakpoints}, nil
+}
+
+// Add and remove emulator breakpoints so they match all requested breakpoints
+func (s *Server) syncBreakpoints() error {
+	s.mutex.Lock()
+	wanted := map[uint16]bool{}
+	for _, addresses := range s.sourceBreakpoints {
+		for _, address := range addresses {
+			wanted[address] = true
+		}
+	}
+	for _, address := range append(s.functionBPs, s.instructionBPs...) {
+		wanted[address] = true
+	}
+	if s.stepOutTarget != nil {
+		wanted[*s.stepOutTarget] = true
+	}
+
+	var added, removed []uint16
+	for address := range wanted {
+		if !s.activeBPs[address] {
+			added = append(added, address)
+		}
+	}
+	for address := range s.activeBPs {
+		if !wanted[address] {
+			removed = append(removed, address)
+		}
+	}
+	s.mutex.Unlock()
+
+	sort.Slice(added, func(i, j int) bool { return added[i] < added[j] })
+	sort.Slice(removed, func(i, j int) bool { return removed[i] < removed[j] })
+
+	for _, address := range removed {
+		if err := s.request(func(token string) error {
+			return s.client.RemoveCPUBreakpoint(address, token)
+		}); err != nil {
+			return err
+		}
+		s.mutex.Lock()
+		delete(s.activeBPs, address)
+		s.mutex.Unlock()
+	}
+	for _, address := range added {
+		if err := s.request(func(token string) error {
+			return s.client.AddCPUBreakpoint(address, token)
+		}); err != nil {
+			return err
+		}
+		s.mutex.Lock()
+		s.activeBPs[address] = true
+		s.mutex.Unlock()
+	}
+	return nil
+}
+
+// Find label address, labels in sources may be defined in KickAssembler namespaces ('init' in 'music.init')
+func lookupLabel(symbolTable *symbols.SymbolTable, label string) (uint16, bool) {
+	if symbolTable == nil {
+		return 0, false
+	}
+	if address, exist := symbolTable.Lookup(label); exist {
+		return address, true
+	}
+	for _, symbol := range symbolTable.Symbols() {
+		if strings.HasSuffix(symbol.Name, "."+label) {
+			return symbol.Address, true
+		}
+	}
+	return 0, false
+}
+
+// Memory and instruction references are formatted as '0x0810'
+func formatReference(address uint16) string {
+	return fmt.Sprintf("0x%04x", address)
+}
+
+// Parse memory or instruction reference with offset
+func (s *Server) parseReference(reference string, offset int) (uint16, error) {
+	address, err := s.client.ResolveAddress(reference)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid reference '%s': %w", reference, err)
+	}
+	result := int(address) + offset
+	if result < 0 || result > 0xffff {
+		return 0, fmt.Errorf("Reference '%s' with offset %d out of range", reference, offset)
+	}
+	return uint16(result), nil
+}
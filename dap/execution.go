@@ -0,0 +1,143 @@
+package dap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+)
+
+// Start of BASIC program area
+const basicStart uint16 = 0x0801
+
+// BASIC token of SYS
+const tokenSYS = 0x9e
+
+// Load PRG file into RAM, returns its load address and contents
+func (s *Server) loadProgram(path string) (uint16, []byte, error) {
+	prg, err := os.ReadFile(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(prg) < 3 {
+		return 0, nil, fmt.Errorf("Invalid PRG file '%s'", path)
+	}
+
+	loadAddress := uint16(prg[0]) | uint16(prg[1])<<8
+	if int(loadAddress)+len(prg)-2 > 0x10000 {
+		return 0, nil, fmt.Errorf("PRG file '%s' doesn't fit in memory", path)
+	}
+	return loadAddress, prg[2:], s.client.WriteMemory(c64dws.MemorySpaceRAM, loadAddress, prg[2:])
+}
+
+// Address of 'SYS nnnn' in the first BASIC line: link (2), line number (2), tokens, 0
+func basicSYSAddress(program []byte) (uint16, bool) {
+	if len(program) < 5 {
+		return 0, false
+	}
+	line := program[4:]
+	if end := bytes.IndexByte(line, 0); end != -1 {
+		line = line[:end]
+	}
+	idx := bytes.IndexByte(line, tokenSYS)
+	if idx == -1 {
+		return 0, false
+	}
+	digits := strings.TrimLeft(string(line[idx+1:]), " (")
+	if end := strings.IndexFunc(digits, func(r rune) bool { return r < '0' || r > '9' }); end != -1 {
+		digits = digits[:end]
+	}
+	address, err := strconv.ParseUint(digits, 10, 16)
+	if err != nil {
+		return 0, false
+	}
+	return uint16(address), true
+}
+
+func (s *Server) continueExecution(request *Request) (any, error) {
+	if err := s.request(func(token string) error {
+		return s.client.ContinueEmulation(token)
+	}); err != nil {
+		return nil, err
+	}
+	return map[string]any{"allThreadsContinued": true}, nil
+}
+
+func (s *Server) pause(request *Request) (any, error) {
+	return nil, s.request(func(token string) error {
+		return s.client.PauseEmulation(token)
+	})
+}
+
+// Step over: JSR is executed as a single step
+func (s *Server) next(request *Request) (any, error) {
+	return nil, s.request(func(token string) error {
+		return s.client.StepSubroutine(token)
+	})
+}
+
+func (s *Server) stepIn(request *Request) (any, error) {
+	return nil, s.request(func(token string) error {
+		return s.client.StepInstruction(token)
+	})
+}
+
+// Step out: run until the return address of the innermost JSR frame,
+// the stopped event is sent when the temporary breakpoint is hit
+func (s *Server) stepOut(request *Request) (any, error) {
+	frames, err := s.client.CallStack()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, frame := range frames {
+		if frame.Kind != c64dws.FrameJSR {
+			continue
+		}
+		returnAddress := frame.ReturnAddress
+		s.mutex.Lock()
+		s.stepOutTarget = &returnAddress
+		s.mutex.Unlock()
+		if err := s.syncBreakpoints(); err != nil {
+			return nil, err
+		}
+		return nil, s.request(func(token string) error {
+			return s.client.ContinueEmulation(token)
+		})
+	}
+	return nil, errors.New("No caller frame to step out to")
+}
+
+// Report breakpoints hit while running, until done is closed
+func (s *Server) listenForEvents(events <-chan any, done <-chan struct{}) {
+	for {
+		var event any
+		select {
+		case <-done:
+			return
+		case event = <-events:
+		}
+		if !c64dws.IsCPUBreakpointEvent(event) && !c64dws.IsRasterBreakpointEvent(event) {
+			continue
+		}
+
+		reason := "breakpoint"
+		s.mutex.Lock()
+		stepOutTarget := s.stepOutTarget
+		s.stepOutTarget = nil
+		s.mutex.Unlock()
+
+		// step out finishes (or is cancelled by other breakpoint) on any stop
+		if stepOutTarget != nil {
+			if state, err := s.client.ReadCPUStatus(); err == nil && state.PC == *stepOutTarget {
+				reason = "step"
+			}
+			s.syncBreakpoints()
+		}
+		s.sendStopped(reason)
+	}
+}
@@ -0,0 +1,213 @@
+package dap
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+)
+
+// Variables references: CPU registers and chip registers
+const (
+	registersReference = 1
+	chipsReference     = 2 // + chip
+)
+
+func (s *Server) stackTrace(request *Request) (any, error) {
+	var arguments StackTraceArguments
+	if err := decodeArguments(request, &arguments); err != nil {
+		return nil, err
+	}
+
+	frames, err := s.client.CallStack()
+	if err != nil {
+		return nil, err
+	}
+
+	stackFrames := make([]StackFrame, 0, len(frames))
+	for idx, frame := range frames {
+		name := fmt.Sprintf("$%04x", frame.Address)
+		if frame.Label != "" {
+			name = frame.Label
+		}
+		stackFrame := StackFrame{
+			ID:                          idx,
+			Name:                        name,
+			Column:                      1,
+			InstructionPointerReference: formatReference(frame.Address),
+		}
+		s.setSourceLocation(&stackFrame.Source, &stackFrame.Line, frame.Address)
+		stackFrames = append(stackFrames, stackFrame)
+	}
+
+	total := len(stackFrames)
+	if arguments.StartFrame > 0 {
+		stackFrames = stackFrames[min(arguments.StartFrame, total):]
+	}
+	if arguments.Levels > 0 && arguments.Levels < len(stackFrames) {
+		stackFrames = stackFrames[:arguments.Levels]
+	}
+	return map[string]any{"stackFrames": stackFrames, "totalFrames": total}, nil
+}
+
+// Set source location of the nearest label placed at or below the address
+func (s *Server) setSourceLocation(source **Source, line *int, address uint16) {
+	symbolTable := s.client.Symbols()
+	if symbolTable == nil {
+		return
+	}
+	symbol, offset, exist := symbolTable.Nearest(address)
+	if !exist || offset > 0xff {
+		return
+	}
+	location, exist := s.sources.Location(symbol.Name)
+	if !exist {
+		return
+	}
+	*source = &Source{Name: location.Path[strings.LastIndexAny(location.Path, `/\`)+1:], Path: location.Path}
+	*line = location.Line
+}
+
+func (s *Server) scopes(request *Request) (any, error) {
+	scopes := []Scope{{Name: "Registers", VariablesReference: registersReference}}
+	for _, chip := range c64dws.Chips {
+		scopes = append(scopes, Scope{Name: chip.String(), VariablesReference: chipsReference + int(chip), Expensive: true})
+	}
+	return map[string]any{"scopes": scopes}, nil
+}
+
+func (s *Server) variables(request *Request) (any, error) {
+	var arguments VariablesArguments
+	if err := decodeArguments(request, &arguments); err != nil {
+		return nil, err
+	}
+
+	if arguments.VariablesReference == registersReference {
+		variables, err := s.registerVariables()
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"variables": variables}, nil
+	}
+
+	chip := c64dws.Chip(arguments.VariablesReference - chipsReference)
+	if chip < c64dws.ChipVIC || chip > c64dws.ChipSID {
+		return nil, fmt.Errorf("Unknown variables reference %d", arguments.VariablesReference)
+	}
+	values, err := s.client.ReadChipRegisters(chip)
+	if err != nil {
+		return nil, err
+	}
+
+	variables := make([]Variable, 0, len(values))
+	for offset, value := range values {
+		address := chip.BaseAddress() + uint16(offset)
+		variables = append(variables, Variable{
+			Name:            fmt.Sprintf("$%04x", address),
+			Value:           fmt.Sprintf("$%02x", value),
+			MemoryReference: formatReference(address),
+		})
+	}
+	return map[string]any{"variables": variables}, nil
+}
+
+func (s *Server) registerVariables() ([]Variable, error) {
+	state, err := s.client.ReadCPUStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	byteValue := func(value uint8) string {
+		return fmt.Sprintf("$%02x (%d)", value, value)
+	}
+	return []Variable{
+		{Name: "PC", Value: s.client.DescribeAddress(state.PC), MemoryReference: formatReference(state.PC)},
+		{Name: "A", Value: byteValue(state.A)},
+		{Name: "X", Value: byteValue(state.X)},
+		{Name: "Y", Value: byteValue(state.Y)},
+		{Name: "SP", Value: fmt.Sprintf("$%02x", state.SP), MemoryReference: formatReference(0x0100 | uint16(state.SP))},
+		{Name: "P", Value: fmt.Sprintf("$%02x %s", state.P, state.FlagsString())},
+		{Name: "$01", Value: fmt.Sprintf("$%02x", state.Memory0001)},
+		{Name: "Raster line", Value: fmt.Sprintf("$%03x (%d)", state.RasterY, state.RasterY)},
+		{Name: "Raster cycle", Value: fmt.Sprintf("%d", state.RasterCycle)},
+	}, nil
+}
+
+// Evaluate register name ('a', 'pc', ...), symbol or address, addresses are shown with their byte and word value
+func (s *Server) evaluate(request *Request) (any, error) {
+	var arguments EvaluateArguments
+	if err := decodeArguments(request, &arguments); err != nil {
+		return nil, err
+	}
+	expression := strings.TrimSpace(arguments.Expression)
+
+	variables, err := s.registerVariables()
+	if err != nil {
+		return nil, err
+	}
+	for _, variable := range variables {
+		if strings.EqualFold(variable.Name, expression) {
+			return map[string]any{"result": variable.Value, "variablesReference": 0, "memoryReference": variable.MemoryReference}, nil
+		}
+	}
+
+	address, err := s.client.ResolveAddress(expression)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot evaluate '%s': %w", expression, err)
+	}
+	data, err := s.client.ReadMemory(c64dws.MemorySpaceCPU, address, min(2, 0x10000-int(address)))
+	if err != nil {
+		return nil, err
+	}
+	result := fmt.Sprintf("%s: $%02x", s.client.DescribeAddress(address), data[0])
+	if len(data) == 2 {
+		result += fmt.Sprintf(", word $%04x", uint16(data[0])|uint16(data[1])<<8)
+	}
+	return map[string]any{"result": result, "variablesReference": 0, "memoryReference": formatReference(address)}, nil
+}
+
+func (s *Server) readMemory(request *Request) (any, error) {
+	var arguments ReadMemoryArguments
+	if err := decodeArguments(request, &arguments); err != nil {
+		return nil, err
+	}
+	address, err := s.parseReference(arguments.MemoryReference, arguments.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	count := min(arguments.Count, 0x10000-int(address))
+	body := map[string]any{"address": formatReference(address)}
+	if count <= 0 {
+		return body, nil
+	}
+	data, err := s.client.ReadMemory(c64dws.MemorySpaceCPU, address, count)
+	if err != nil {
+		return nil, err
+	}
+	body["data"] = base64.StdEncoding.EncodeToString(data)
+	if unreadable := arguments.Count - count; unreadable > 0 {
+		body["unreadableBytes"] = unreadable
+	}
+	return body, nil
+}
+
+func (s *Server) writeMemory(request *Request) (any, error) {
+	var arguments WriteMemoryArguments
+	if err := decodeArguments(request, &arguments); err != nil {
+		return nil, err
+	}
+	address, err := s.parseReference(arguments.MemoryReference, arguments.Offset)
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(arguments.Data)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid memory data: %w", err)
+	}
+	if err := s.client.WriteMemory(c64dws.MemorySpaceCPU, address, data); err != nil {
+		return nil, err
+	}
+	return map[string]any{"bytesWritten": len(data)}, nil
+}
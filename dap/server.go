@@ -0,0 +1,426 @@
+// # Debug Adapter Protocol server
+//
+// This package implements the Debug Adapter Protocol (DAP) on top of the
+// Retro Debugger WebSocket API client, so C64 programs can be debugged in
+// IDEs like VS Code. The 6510 CPU is exposed as a single thread.
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+)
+
+// The only thread: 6510 CPU
+const threadID = 1
+
+// Request handler, returns response body
+type handler func(s *Server, request *Request) (any, error)
+
+// Debug adapter serving a single debug session
+type Server struct {
+	in         *bufio.Reader
+	out        io.Writer
+	writeMutex sync.Mutex
+	seq        int
+
+	mutex             sync.Mutex // guards fields below, they are used by the events goroutine
+	client            *c64dws.Client
+	attached          bool // attach request was used instead of launch
+	stopOnEntry       bool
+	sources           *SourceMap
+	sourceBreakpoints map[string][]uint16 // source path -> addresses
+	functionBPs       []uint16
+	instructionBPs    []uint16
+	activeBPs         map[uint16]bool // breakpoints set in the emulator
+	stepOutTarget     *uint16         // temporary breakpoint used by stepOut
+	disconnected      bool
+	stopEvents        func() // stops the events goroutine, nil when not running
+}
+
+var handlers = map[string]handler{}
+
+func init() {
+	handlers = map[string]handler{
+		"initialize":                (*Server).initialize,
+		"launch":                    (*Server).launch,
+		"attach":                    (*Server).attach,
+		"configurationDone":         (*Server).configurationDone,
+		"setBreakpoints":            (*Server).setBreakpoints,
+		"setFunctionBreakpoints":    (*Server).setFunctionBreakpoints,
+		"setInstructionBreakpoints": (*Server).setInstructionBreakpoints,
+		"setExceptionBreakpoints":   (*Server).empty,
+		"threads":                   (*Server).threads,
+		"stackTrace":                (*Server).stackTrace,
+		"scopes":                    (*Server).scopes,
+		"variables":                 (*Server).variables,
+		"evaluate":                  (*Server).evaluate,
+		"readMemory":                (*Server).readMemory,
+		"writeMemory":               (*Server).writeMemory,
+		"continue":                  (*Server).continueExecution,
+		"pause":                     (*Server).pause,
+		"next":                      (*Server).next,
+		"stepIn":                    (*Server).stepIn,
+		"stepOut":                   (*Server).stepOut,
+		"disconnect":                (*Server).disconnect,
+		"terminate":                 (*Server).disconnect,
+	}
+}
+
+// Create debug adapter reading requests from in and writing responses and events to out
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:                bufio.NewReader(in),
+		out:               out,
+		sources:           NewSourceMap(),
+		sourceBreakpoints: map[string][]uint16{},
+		activeBPs:         map[uint16]bool{},
+	}
+}
+
+// Serve requests until disconnect request or end of input
+func (s *Server) Serve() error {
+	defer func() {
+		s.stopListening()
+		if s.client != nil {
+			s.client.Close()
+		}
+	}()
+
+	for {
+		content, err := ReadMessage(s.in)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var request Request
+		if err := json.Unmarshal(content, &request); err != nil {
+			return err
+		}
+		if request.Type != "request" {
+			continue
+		}
+
+		s.handle(&request)
+
+		s.mutex.Lock()
+		disconnected := s.disconnected
+		s.mutex.Unlock()
+		if disconnected {
+			return nil
+		}
+	}
+}
+
+func (s *Server) handle(request *Request) {
+	handle, exist := handlers[request.Command]
+	if !exist {
+		s.respond(request, nil, fmt.Errorf("Unsupported request '%s'", request.Command))
+		return
+	}
+	if request.Command != "initialize" && request.Command != "launch" && request.Command != "attach" &&
+		request.Command != "disconnect" && s.client == nil {
+		s.respond(request, nil, errors.New("Not connected, use launch or attach first"))
+		return
+	}
+
+	body, err := handle(s, request)
+	s.respond(request, body, err)
+
+	// events sent after the response
+	switch request.Command {
+	case "initialize":
+		if err == nil {
+			s.sendEvent("initialized", nil)
+		}
+	case "next", "stepIn":
+		if err == nil {
+			s.sendStopped("step")
+		}
+	case "pause":
+		if err == nil {
+			s.sendStopped("pause")
+		}
+	case "configurationDone":
+		if err == nil && s.sessionStopOnEntry() {
+			s.sendStopped("entry")
+		}
+	}
+}
+
+func (s *Server) nextSeq() int {
+	s.seq++
+	return s.seq
+}
+
+func (s *Server) respond(request *Request, body any, err error) {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	response := Response{
+		ProtocolMessage: ProtocolMessage{Seq: s.nextSeq(), Type: "response"},
+		RequestSeq:      request.Seq,
+		Success:         err == nil,
+		Command:         request.Command,
+		Body:            body,
+	}
+	if err != nil {
+		response.Message = err.Error()
+	}
+	WriteMessage(s.out, response)
+}
+
+func (s *Server) sendEvent(event string, body any) {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	WriteMessage(s.out, Event{
+		ProtocolMessage: ProtocolMessage{Seq: s.nextSeq(), Type: "event"},
+		Event:           event,
+		Body:            body,
+	})
+}
+
+func (s *Server) sendStopped(reason string) {
+	s.sendEvent("stopped", map[string]any{
+		"reason":            reason,
+		"threadId":          threadID,
+		"allThreadsStopped": true,
+	})
+}
+
+// Decode request arguments
+func decodeArguments(request *Request, arguments any) error {
+	if len(request.Arguments) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(request.Arguments, arguments); err != nil {
+		return fmt.Errorf("Invalid '%s' arguments: %w", request.Command, err)
+	}
+	return nil
+}
+
+// Send request and wait for its response
+func (s *Server) request(send func(token string) error) error {
+	_, err := s.client.Request(send)
+	return err
+}
+
+// ----------------------------------------------------------------------
+// Session
+// ----------------------------------------------------------------------
+func (s *Server) initialize(request *Request) (any, error) {
+	return map[string]any{
+		"supportsConfigurationDoneRequest": true,
+		"supportsFunctionBreakpoints":      true,
+		"supportsInstructionBreakpoints":   true,
+		"supportsReadMemoryRequest":        true,
+		"supportsWriteMemoryRequest":       true,
+		"supportsEvaluateForHovers":        true,
+		"supportsTerminateRequest":         true,
+		"supportTerminateDebuggee":         true,
+	}, nil
+}
+
+// Connect to Retro Debugger, load symbols and sources
+func (s *Server) connect(arguments LaunchArguments) error {
+	host := arguments.Host
+	if host == "" {
+		host = c64dws.WS_HOST
+	}
+	port := arguments.Port
+	if port == 0 {
+		port = c64dws.WS_PORT_0x0DEB
+	}
+	hostDesc, err := c64dws.GetCustomHost(host, port, c64dws.WS_SCHEME)
+	if err != nil {
+		return err
+	}
+	client := c64dws.NewCustomClient(c64dws.EmulatorC64, c64dws.StreamAPI, c64dws.TokenTypeAutoIncrement, c64dws.WS_DEFAULT_TOKEN_FORMAT, hostDesc)
+	if _, err := client.Connect(); err != nil {
+		return err
+	}
+
+	for _, path := range arguments.Symbols {
+		if err := client.LoadSymbols(path); err != nil {
+			client.Close()
+			return err
+		}
+	}
+	for _, path := range arguments.Sources {
+		if err := s.sources.AddFile(path); err != nil {
+			client.Close()
+			return err
+		}
+	}
+
+	// connection of a failed launch is replaced
+	s.stopListening()
+	if s.client != nil {
+		s.client.Close()
+	}
+	events, unsubscribe := client.SubscribeEvents()
+	done := make(chan struct{})
+	s.mutex.Lock()
+	s.client = client
+	s.stopOnEntry = arguments.StopOnEntry
+	s.stopEvents = func() {
+		unsubscribe()
+		close(done)
+	}
+	s.mutex.Unlock()
+
+	go s.listenForEvents(events, done)
+	return nil
+}
+
+// Stop the events goroutine of the session
+func (s *Server) stopListening() {
+	s.mutex.Lock()
+	stopEvents := s.stopEvents
+	s.stopEvents = nil
+	s.mutex.Unlock()
+	if stopEvents != nil {
+		stopEvents()
+	}
+}
+
+func (s *Server) sessionStopOnEntry() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stopOnEntry
+}
+
+func (s *Server) sessionAttached() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.attached
+}
+
+func (s *Server) launch(request *Request) (any, error) {
+	var arguments LaunchArguments
+	if err := decodeArguments(request, &arguments); err != nil {
+		return nil, err
+	}
+	if arguments.Program == "" {
+		return nil, errors.New("Launch requires 'program' (PRG file)")
+	}
+	if err := s.connect(arguments); err != nil {
+		return nil, err
+	}
+
+	if err := s.request(func(token string) error {
+		return s.client.PauseEmulation(token)
+	}); err != nil {
+		return nil, err
+	}
+	if arguments.HardReset {
+		if err := s.request(func(token string) error {
+			return s.client.HardReset(token)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	loadAddress, program, err := s.loadProgram(arguments.Program)
+	if err != nil {
+		return nil, err
+	}
+	entry := loadAddress
+	switch {
+	case arguments.Entry != "":
+		if entry, err = s.client.ResolveAddress(arguments.Entry); err != nil {
+			return nil, err
+		}
+	case loadAddress == basicStart:
+		// jumping to $0801 would execute the BASIC line link, start at the SYS address of the stub
+		sysAddress, found := basicSYSAddress(program)
+		if !found {
+			return nil, errors.New("Program loaded at $0801 has no SYS in its first BASIC line, set 'entry'")
+		}
+		entry = sysAddress
+	}
+	return nil, s.request(func(token string) error {
+		return s.client.CPUMakeJMP(entry, token)
+	})
+}
+
+func (s *Server) attach(request *Request) (any, error) {
+	var arguments LaunchArguments
+	if err := decodeArguments(request, &arguments); err != nil {
+		return nil, err
+	}
+	if err := s.connect(arguments); err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	s.attached = true
+	s.mutex.Unlock()
+
+	if arguments.StopOnEntry {
+		return nil, s.request(func(token string) error {
+			return s.client.PauseEmulation(token)
+		})
+	}
+	return nil, nil
+}
+
+func (s *Server) configurationDone(request *Request) (any, error) {
+	if s.sessionStopOnEntry() || s.sessionAttached() {
+		return nil, nil
+	}
+	return nil, s.request(func(token string) error {
+		return s.client.ContinueEmulation(token)
+	})
+}
+
+func (s *Server) disconnect(request *Request) (any, error) {
+	var arguments DisconnectArguments
+	if err := decodeArguments(request, &arguments); err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	s.disconnected = true
+	s.mutex.Unlock()
+
+	if s.client == nil {
+		return nil, nil
+	}
+
+	// remove breakpoints and let the program run
+	s.mutex.Lock()
+	s.sourceBreakpoints = map[string][]uint16{}
+	s.functionBPs = nil
+	s.instructionBPs = nil
+	s.stepOutTarget = nil
+	s.mutex.Unlock()
+	s.stopListening()
+	if err := s.syncBreakpoints(); err != nil {
+		return nil, err
+	}
+	if !arguments.TerminateDebuggee || s.sessionAttached() {
+		return nil, s.request(func(token string) error {
+			return s.client.ContinueEmulation(token)
+		})
+	}
+	return nil, nil
+}
+
+func (s *Server) empty(request *Request) (any, error) {
+	return nil, nil
+}
+
+func (s *Server) threads(request *Request) (any, error) {
+	return map[string]any{
+		"threads": []map[string]any{{"id": threadID, "name": "6510"}},
+	}, nil
+}
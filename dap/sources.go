@@ -0,0 +1,128 @@
+package dap
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mojzesh/c64d-ws-client/asm6502"
+)
+
+// Label with colon ('main:') anywhere, or label without colon in the first column ('main lda #0')
+var (
+	colonLabelRegexp  = regexp.MustCompile(`^\s*([A-Za-z_@][\w.@]*)\s*:`)
+	columnLabelRegexp = regexp.MustCompile(`^([A-Za-z_@][\w.@]*)(\s|;|$)`)
+)
+
+// Position of a label in the source file
+type SourceLocation struct {
+	Path string
+	Line int // 1-based
+}
+
+// Maps assembler source lines to labels and back.
+// Symbol files don't contain line numbers, so source lines are resolved
+// through labels: a line can have a breakpoint if it defines a label, or if
+// it's the first code line after a line with a label only.
+type SourceMap struct {
+	labels map[string]SourceLocation // label -> location
+	lines  map[string]map[int]string // path -> line -> label
+	code   map[string]map[int]bool   // path -> line -> line contains code
+	files  map[string]bool           // scanned files
+}
+
+func NewSourceMap() *SourceMap {
+	return &SourceMap{
+		labels: map[string]SourceLocation{},
+		lines:  map[string]map[int]string{},
+		code:   map[string]map[int]bool{},
+		files:  map[string]bool{},
+	}
+}
+
+// Scan source file for labels, files are scanned only once
+func (sm *SourceMap) AddFile(path string) error {
+	path = filepath.Clean(path)
+	if sm.files[path] {
+		return nil
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sm.AddSource(path, string(contents))
+	return nil
+}
+
+// Scan source contents for labels
+func (sm *SourceMap) AddSource(path string, contents string) {
+	path = filepath.Clean(path)
+	sm.files[path] = true
+	sm.lines[path] = map[int]string{}
+	sm.code[path] = map[int]bool{}
+
+	for idx, line := range strings.Split(contents, "\n") {
+		lineNum := idx + 1
+		line = strings.TrimRight(line, "\r")
+		if comment := strings.IndexAny(line, ";"); comment != -1 {
+			line = line[:comment]
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "//") {
+			continue
+		}
+
+		rest := line
+		if match := colonLabelRegexp.FindStringSubmatch(line); match != nil {
+			sm.addLabel(path, lineNum, match[1])
+			rest = line[len(match[0]):]
+		} else if match := columnLabelRegexp.FindStringSubmatch(line); match != nil && !asm6502.IsMnemonic(strings.ToLower(match[1])) {
+			sm.addLabel(path, lineNum, match[1])
+			rest = line[len(match[1]):]
+		}
+		if strings.TrimSpace(rest) != "" {
+			sm.code[path][lineNum] = true
+		}
+	}
+}
+
+func (sm *SourceMap) addLabel(path string, line int, label string) {
+	sm.lines[path][line] = label
+	if _, exist := sm.labels[label]; !exist {
+		sm.labels[label] = SourceLocation{Path: path, Line: line}
+	}
+}
+
+// Label which marks the line, see SourceMap
+func (sm *SourceMap) LabelAt(path string, line int) (string, bool) {
+	path = filepath.Clean(path)
+	lines := sm.lines[path]
+	if label, exist := lines[line]; exist {
+		return label, true
+	}
+	if !sm.code[path][line] {
+		return "", false
+	}
+	// first code line after label-only line(s)
+	for previous := line - 1; previous > 0; previous-- {
+		if sm.code[path][previous] {
+			return "", false
+		}
+		if label, exist := lines[previous]; exist {
+			return label, true
+		}
+	}
+	return "", false
+}
+
+// Location of the label in sources
+func (sm *SourceMap) Location(label string) (SourceLocation, bool) {
+	location, exist := sm.labels[label]
+	if !exist {
+		// KickAssembler namespaces: 'music.init' is defined as 'init'
+		if idx := strings.LastIndex(label, "."); idx != -1 {
+			location, exist = sm.labels[label[idx+1:]]
+		}
+	}
+	return location, exist
+}
@@ -4,9 +4,14 @@ go 1.23.4
 
 replace github.com/mojzesh/c64d-ws-client v0.0.0 => ../../
 
-require github.com/mojzesh/c64d-ws-client v0.0.0
+require (
+	github.com/gorilla/websocket v1.5.3
+	github.com/mojzesh/c64d-ws-client v0.0.0
+	gotest.tools v2.2.0+incompatible
+)
 
 require (
+	github.com/google/go-cmp v0.6.0 // indirect
 	github.com/google/uuid v1.6.0 // indirect
-	github.com/gorilla/websocket v1.5.3 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
 )
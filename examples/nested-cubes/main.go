@@ -5,7 +5,6 @@ import (
 	"log"
 	"os"
 	"os/signal"
-	"sync"
 	"syscall"
 	"time"
 
@@ -21,7 +20,6 @@ import (
 const waitAfterReset = true                               // set to true for waiting after hard reset
 const waitTimeAfterResetInSeconds = time.Millisecond * 20 // time to wait after hard reset
 
-const silentMode = true          // set to true for less verbose output
 const warpMode = false           // set to true to enable warp mode
 const rasterLineBreakpoint = 255 // raster line to wait for VSync
 
@@ -31,6 +29,17 @@ const color01 uint8 = colors.COL_DARK_BLUE_IDX
 const color10 uint8 = colors.COL_LIGHT_BLUE_IDX
 const color11 uint8 = colors.COL_CYAN_IDX
 
+// Memory layout, screen and bitmap are relative to the VIC bank
+const colorScreenSize = 40 * 25
+const bitmapSize = 40 * 200
+const bank0Addr uint16 = 0x0000
+const bank1Addr uint16 = 0x4000
+const screenAddr uint16 = 0x0400
+const bitmapAddr uint16 = 0x2000
+const colorRAM uint16 = 0xd800
+const frameBufferAddr uint16 = 0x8000 // back buffer in the local RAM
+const screenD018 = vic.D018_BITMAP_MODE_2000_3FFF_VALUE | vic.D018_SCREEN_MEMORY_0400_07FF_VALUE
+
 func main() {
 	// -------------------------------------------------------------
 	// Create cancellable context
@@ -47,11 +56,6 @@ func main() {
 	}
 	defer client.Close()
 
-	vsyncChannel := make(chan bool)
-	wg := sync.WaitGroup{}
-	wg.Add(1)
-	go listenForMessages(ctx, &wg, client, vsyncChannel)
-
 	// -------------------------------------------------------------
 	// Request Hard reset
 	// -------------------------------------------------------------
@@ -59,25 +63,10 @@ func main() {
 		log.Fatal(err)
 	}
 
-	// -------------------------------------------------------------
-	// Set raster breakpoint
-	// -------------------------------------------------------------
-	if err = client.AddRasterBreakpoint(rasterLineBreakpoint, client.GetToken("breakpoint-%d")); err != nil {
-		log.Fatal(err)
-	}
-
 	// -------------------------------------------------------------
 	// Init register $01 and put CPU into infinite loop
 	// -------------------------------------------------------------
-	if err := putCPUIntoInfiniteLoop(client, vsyncChannel); err != nil {
-		log.Fatal(err)
-	}
-
-	// -------------------------------------------------------------
-	// Wait one more frame to make sure CPU is in infinite loop
-	// -------------------------------------------------------------
-	<-vsyncChannel
-	if err := client.ContinueEmulation(); err != nil {
+	if err := putCPUIntoInfiniteLoop(client); err != nil {
 		log.Fatal(err)
 	}
 
@@ -85,12 +74,14 @@ func main() {
 	// Init VIC registers
 	// -------------------------------------------------------------
 	log.Println("Init VIC registers")
-	if err := client.VICWrite(map[string]uint8{
-		"0xD011": vic.D011_BITMAP_MODE_VALUE | vic.D011_SCREEN_HEIGHT_25_ROWS_VALUE | vic.D011_SCREEN_ON_VALUE | 0b00000011,
-		"0xD016": 0b11000000 | vic.D016_SCREEN_MULTI_VALUE | vic.D016_SCREEN_WIDTH_40_COLS_VALUE,
-		"0xD018": vic.D018_BITMAP_MODE_2000_3FFF_VALUE | vic.D018_SCREEN_MEMORY_0400_07FF_VALUE,
-		"0xD020": colors.COL_DARK_BLUE_IDX,
-		"0xD021": colors.COL_BLACK_IDX,
+	if err := syncRequest(client, func(token string) error {
+		return client.VICWrite(map[string]uint8{
+			"0xD011": vic.D011_BITMAP_MODE_VALUE | vic.D011_SCREEN_HEIGHT_25_ROWS_VALUE | vic.D011_SCREEN_ON_VALUE | 0b00000011,
+			"0xD016": 0b11000000 | vic.D016_SCREEN_MULTI_VALUE | vic.D016_SCREEN_WIDTH_40_COLS_VALUE,
+			"0xD018": screenD018,
+			"0xD020": colors.COL_DARK_BLUE_IDX,
+			"0xD021": colors.COL_BLACK_IDX,
+		}, token)
 	}); err != nil {
 		log.Fatal(err)
 	}
@@ -99,49 +90,54 @@ func main() {
 	// Init CIA registers
 	// -------------------------------------------------------------
 	log.Println("Init CIA registers")
-	if err := client.CIAWrite(
-		c64dws.CIAInfer,
-		map[string]uint8{
-			"0xDD00": cia.DD00_BANK_0_VALUE,
-		},
-	); err != nil {
+	if err := syncRequest(client, func(token string) error {
+		return client.CIAWrite(
+			c64dws.CIAInfer,
+			map[string]uint8{
+				"0xDD00": cia.DD00_BANK_0_VALUE,
+			},
+			token,
+		)
+	}); err != nil {
 		log.Fatal(err)
 	}
 
 	// -------------------------------------------------------------
-	// Init Color RAM, Screen RAM and Bitmap
+	// Init Color RAM, Screen RAM and Bitmap of both buffers
 	// -------------------------------------------------------------
 	log.Println("Init Color RAM, Screen RAM and Bitmap")
-	const colorScreenSize = 40 * 25
-	const bitmapSize = 40 * 200
-	const screenAddr uint16 = 0x0400
-	const bitmapAddr uint16 = 0x2000
-	const colorRAM uint16 = 0xd800
-	const frameBufferAddr uint16 = 0x8000
 
 	// Set color RAM
 	vicVideoRAM := make([]byte, colorScreenSize)
 	for i := 0; i < colorScreenSize; i++ {
 		vicVideoRAM[i] = color11
 	}
-	if err = client.CPUMemoryWriteBlock(colorRAM, vicVideoRAM); err != nil {
+	if err = client.WriteMemory(c64dws.MemorySpaceCPU, colorRAM, vicVideoRAM); err != nil {
 		log.Fatal(err)
 	}
 
-	// Clear screen
-	if err = client.RAMClear(screenAddr, colorScreenSize, color01<<4|color10); err != nil {
-		log.Fatal(err)
-	}
+	for _, bankAddr := range []uint16{bank0Addr, bank1Addr} {
+		// Clear screen
+		if err := syncRequest(client, func(token string) error {
+			return client.RAMClear(bankAddr+screenAddr, colorScreenSize, color01<<4|color10, token)
+		}); err != nil {
+			log.Fatal(err)
+		}
 
-	// Clear bitmap
-	if err = client.RAMClear(bitmapAddr, bitmapSize, 0); err != nil {
-		log.Fatal(err)
+		// Clear bitmap
+		if err := syncRequest(client, func(token string) error {
+			return client.RAMClear(bankAddr+bitmapAddr, bitmapSize, 0, token)
+		}); err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	// -------------------------------------------------------------
 	// Set warp mode if enabled
 	// -------------------------------------------------------------
-	if err = client.SetWarpMode(warpMode); err != nil {
+	if err := syncRequest(client, func(token string) error {
+		return client.SetWarpMode(warpMode, token)
+	}); err != nil {
 		log.Fatal(err)
 	}
 
@@ -149,69 +145,69 @@ func main() {
 	// Handle SIGINT and SIGTERM
 	// -------------------------------------------------------------
 	log.Println("Starting termination handler")
-	killSignalChan := make(chan os.Signal, 0)
-	doneChan := make(chan bool, 0)
+	killSignalChan := make(chan os.Signal, 1)
 	signal.Notify(killSignalChan, os.Interrupt, syscall.SIGTERM)
-	wg.Add(1)
 	go func() {
-		defer wg.Done()
-		// wait for kill signal
+		// wait for kill signal and stop the render loop
 		<-killSignalChan
-		// signal that we're done
-		doneChan <- true
+		cancelContext()
 	}()
 
 	// -------------------------------------------------------------
-	// Render loop
+	// Render loop: frames are rendered on the client side and streamed
+	// double-buffered (bank 0 and bank 1), synchronized with VSync
 	// -------------------------------------------------------------
-	log.Println("Starting render loop (press CTRL+C to exit)")
+	log.Println("Loading ROMs into the local RAM model")
 	ram := vc64.NewC64RAM()
-	currentFrame := 0
-	startTime := time.Now()
-	for {
-		select {
-		case <-doneChan:
-			// Received done signal
-			doCleanExit(client, cancelContext, &wg)
-		default:
-			// Render next frame
-			frameBuffer := effects.DrawNestedCubes(currentFrame, frameBufferAddr, bitmapAddr, ram)
-
-			// Send frame buffer to C64 Debugger
-			if err = client.CPUMemoryWriteBlock(bitmapAddr, frameBuffer); err != nil {
-				log.Fatal(err)
-			}
-
-			// wait for VSync (raster breakpoint)
-			if <-vsyncChannel {
-				if err = client.ContinueEmulation(); err != nil {
-					log.Fatal(err)
-				}
-			}
-
-			currentFrame++
-			log.Printf("Frame: %d, FPS: %.2f", currentFrame, float64(currentFrame)/time.Since(startTime).Seconds())
-		}
+	if err := ram.LoadROMsFromEmulator(client); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Starting render loop (press CTRL+C to exit)")
+	streamer, err := client.NewFrameStreamer(
+		c64dws.FrameStreamerOptions{
+			RasterLine: rasterLineBreakpoint,
+			Buffers: [2]c64dws.FrameBuffer{
+				{Address: bank0Addr + bitmapAddr, D018: screenD018, DD00: cia.DD00_BANK_0_VALUE},
+				{Address: bank1Addr + bitmapAddr, D018: screenD018, DD00: cia.DD00_BANK_1_VALUE},
+			},
+			FrameSize: bitmapSize,
+		},
+		func(frame int) ([]byte, error) {
+			frameBuffer := effects.DrawNestedCubes(frame, frameBufferAddr, bitmapAddr, ram)
+			return frameBuffer[:bitmapSize], nil
+		},
+	)
+	if err != nil {
+		log.Fatal(err)
 	}
+	streamer.OnFrame = func(stats c64dws.FrameStats) {
+		log.Printf("Frame: %d, FPS: %.2f, dropped: %d, latency: %v, sent: %d bytes in %d requests, saved: %d bytes",
+			stats.Frames, stats.FPS, stats.Dropped, stats.Latency.Round(time.Microsecond),
+			stats.Flush.Bytes, stats.Flush.Requests, stats.Flush.Saved)
+	}
+	if err := streamer.Run(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	doCleanExit(client)
 }
 
 // -------------------------------------------------------------
 // Clean exit
 // -------------------------------------------------------------
-func doCleanExit(client *c64dws.Client, cancelContext context.CancelFunc, wg *sync.WaitGroup) {
+func doCleanExit(client *c64dws.Client) {
 	log.Println("Exiting...")
-	log.Println("Removing raster breakpoint")
-	client.RemoveRasterBreakpoint(rasterLineBreakpoint)
 	log.Println("Requested hard reset")
-	client.HardReset()
+	syncRequest(client, func(token string) error {
+		return client.HardReset(token)
+	})
 	if warpMode {
 		log.Println("Disabling warp mode")
-		client.SetWarpMode(false)
+		syncRequest(client, func(token string) error {
+			return client.SetWarpMode(false, token)
+		})
 	}
-	cancelContext()
-	wg.Wait()
 	log.Println("Bye!")
-	os.Exit(0)
 }
 
 // -------------------------------------------------------------
@@ -219,7 +215,9 @@ func doCleanExit(client *c64dws.Client, cancelContext context.CancelFunc, wg *sy
 // -------------------------------------------------------------
 func resetEmulator(client *c64dws.Client, waitAfterReset bool) error {
 	log.Println("Requested hard reset")
-	if err := client.HardReset(); err != nil {
+	if err := syncRequest(client, func(token string) error {
+		return client.HardReset(token)
+	}); err != nil {
 		return err
 	}
 
@@ -233,42 +231,56 @@ func resetEmulator(client *c64dws.Client, waitAfterReset bool) error {
 // -------------------------------------------------------------
 // Put CPU into infinite loop
 // -------------------------------------------------------------
-func putCPUIntoInfiniteLoop(client *c64dws.Client, vsyncChannel chan bool) error {
+func putCPUIntoInfiniteLoop(client *c64dws.Client) error {
 	// -------------------------------------------------------------
-	// Wait for first VSync and pause emulation
+	// Pause emulation
 	// -------------------------------------------------------------
-	<-vsyncChannel
+	if err := syncRequest(client, func(token string) error {
+		return client.PauseEmulation(token)
+	}); err != nil {
+		return err
+	}
 
 	// -------------------------------------------------------------
 	// set $01 to $35 - whole RAM visible, except I/O area (0xD000-0xDFFF)
 	// -------------------------------------------------------------
-	if err := client.CPUMemoryWriteBlock(0x01, []byte{0x35}); err != nil {
-		log.Fatal(err)
+	if err := client.WriteMemory(c64dws.MemorySpaceCPU, 0x01, []byte{0x35}); err != nil {
+		return err
 	}
 
 	// Prepare simple assembly program which disables interrupts and then loops forever to keep CPU busy
 	jmpAddr := uint16(0x0815)
-	infiniteLoopProcedure := []byte{
-		0x78,                                                       // $0815: sei
-		0x4c, byte((jmpAddr + 1) & 0xff), byte((jmpAddr + 1) >> 8), // $0816: jmp $0816
-	}
-	if err := client.RAMWriteBlock(jmpAddr, infiniteLoopProcedure); err != nil {
+	if err := syncRequest(client, func(token string) error {
+		return client.AssembleAndWrite(jmpAddr, `
+			sei
+		loop:
+			jmp loop
+		`, token)
+	}); err != nil {
 		return err
 	}
 
 	// Run the program
-	if err := client.CPUMakeJMP(jmpAddr); err != nil {
+	if err := syncRequest(client, func(token string) error {
+		return client.CPUMakeJMP(jmpAddr, token)
+	}); err != nil {
 		return err
 	}
 
 	// -------------------------------------------------------------
 	// Continue emulation
 	// -------------------------------------------------------------
-	if err := client.ContinueEmulation(); err != nil {
-		log.Fatal(err)
-	}
+	return syncRequest(client, func(token string) error {
+		return client.ContinueEmulation(token)
+	})
+}
 
-	return nil
+// -------------------------------------------------------------
+// Send request and wait for its response (sync mode)
+// -------------------------------------------------------------
+func syncRequest(client *c64dws.Client, send func(token string) error) error {
+	_, err := client.Request(send)
+	return err
 }
 
 // -------------------------------------------------------------
@@ -287,46 +299,3 @@ func connectToWS(emulator c64dws.EmulatorType, apiType c64dws.APIType) (*c64dws.
 
 	return client, nil
 }
-
-// -------------------------------------------------------------
-// Listen for messages from C64D WebSocket Server
-// -------------------------------------------------------------
-func listenForMessages(ctx context.Context, wg *sync.WaitGroup, client *c64dws.Client, vsyncChannel chan bool) {
-	defer wg.Done()
-	for {
-		select {
-		case <-ctx.Done():
-			// context cancelled
-			return
-		default:
-			msgType, msg, err := client.ReceiveMessage()
-			if err != nil {
-				log.Fatal(err)
-			}
-
-			switch msgType {
-			case c64dws.C64DRequestResponse:
-				switch msg := msg.(type) {
-				case c64dws.RequestResult:
-					if !silentMode {
-						log.Printf("Success: %d, result: %+v, token: '%s', binaryData: %v\n", msg.Status, msg.Result, msg.Token, msg.BinaryData)
-					}
-				case c64dws.RequestError:
-					if !silentMode {
-						log.Printf("Error: %d, error: %s\n", msg.Status, msg.Error)
-					}
-				}
-			case c64dws.C64DServerEvent:
-				switch msg := msg.(type) {
-				case c64dws.RasterBreakpointEvent:
-					if !silentMode {
-						log.Printf("RasterBreakpointEvent: ID: %d, Raster: %d\n", msg.BreakpointId, msg.RasterLine)
-					}
-					vsyncChannel <- true
-				}
-			case c64dws.C64DUnknown:
-				log.Println("Unknown message")
-			}
-		}
-	}
-}
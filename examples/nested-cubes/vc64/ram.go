@@ -1,5 +1,7 @@
 package vc64
 
+import "github.com/mojzesh/c64d-ws-client/c64dws"
+
 type BUSType int
 
 const (
@@ -27,12 +29,13 @@ const (
 // %1xx: I/O area visible at $D000-$DFFF. (Except for the value %100, see above.)
 // --------------------------------------------------------------
 type C64RAM struct {
-	ram       [0x10000]byte // RAM: $0000-$FFFF
-	vicRAM    [0x10000]byte // RAM: $0000-$FFFF
-	io        [0x1000]byte  // RAM: $D000-$DFFF
-	basicROM  [0x2000]byte  // RAM: $A000-$BFFF
-	charROM   [0x1000]byte  // RAM: $D000-$DFFF
-	kernalROM [0x2000]byte  // RAM: $E000-$FFFF
+	ram       [0x10000]byte       // RAM: $0000-$FFFF
+	vicRAM    [0x10000]byte       // RAM: $0000-$FFFF
+	io        [0x1000]byte        // RAM: $D000-$DFFF
+	basicROM  [0x2000]byte        // RAM: $A000-$BFFF
+	charROM   [0x1000]byte        // RAM: $D000-$DFFF
+	kernalROM [0x2000]byte        // RAM: $E000-$FFFF
+	config    c64dws.MemoryConfig // areas banked in by $01
 }
 
 func NewC64RAM() *C64RAM {
@@ -65,7 +68,27 @@ func (ram *C64RAM) FillVICRAM(value byte, startAddr uint16, count uint16) {
 	}
 }
 
+// --------------------------------------------------------------
+// CPU reads see what $01 banks in: BASIC, KERNAL, CHAR ROM or I/O
+// --------------------------------------------------------------
 func (ram *C64RAM) CPUBusGetUByte8(address uint16) byte {
+	switch {
+	case address >= BASIC_ROM_ADDR && address < BASIC_ROM_ADDR+uint16(BASIC_ROM_SIZE):
+		if ram.config.BASIC {
+			return ram.basicROM[address-BASIC_ROM_ADDR]
+		}
+	case address >= CHAR_ROM_ADDR && address < CHAR_ROM_ADDR+uint16(CHAR_ROM_SIZE):
+		if ram.config.IO {
+			return ram.io[address-CHAR_ROM_ADDR]
+		}
+		if ram.config.CharROM {
+			return ram.charROM[address-CHAR_ROM_ADDR]
+		}
+	case address >= KERNAL_ROM_ADDR:
+		if ram.config.KERNAL {
+			return ram.kernalROM[address-KERNAL_ROM_ADDR]
+		}
+	}
 	return ram.ram[address]
 }
 
@@ -73,11 +96,27 @@ func (ram *C64RAM) CPUGetRAMSlice(startAddr uint16, count uint16) []byte {
 	return ram.ram[startAddr : startAddr+count]
 }
 
+// --------------------------------------------------------------
+// CPU writes go to I/O when it's banked in, otherwise to RAM (also under ROMs)
+// --------------------------------------------------------------
 func (ram *C64RAM) CPUBusSetUByte8(address uint16, b byte) {
+	if ram.config.IO && address >= CHAR_ROM_ADDR && address < CHAR_ROM_ADDR+uint16(CHAR_ROM_SIZE) {
+		ram.io[address-CHAR_ROM_ADDR] = b
+		return
+	}
 	ram.ram[address] = b
+	if address == MEM_CONFIG_0001 {
+		ram.config = c64dws.MemoryConfigFromPort(b)
+	}
 }
 
+// --------------------------------------------------------------
+// VIC sees the character ROM at $1000-$1FFF and $9000-$9FFF (banks 0 and 2)
+// --------------------------------------------------------------
 func (ram *C64RAM) VICBusGetUByte8(address uint16) byte {
+	if address&0x7000 == 0x1000 {
+		return ram.charROM[address&0x0fff]
+	}
 	return ram.vicRAM[address]
 }
 
@@ -0,0 +1,81 @@
+package vc64
+
+import (
+	"fmt"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+)
+
+const (
+	BASIC_ROM_ADDR  uint16 = 0xA000
+	BASIC_ROM_SIZE  int    = 0x2000
+	CHAR_ROM_ADDR   uint16 = 0xD000
+	CHAR_ROM_SIZE   int    = 0x1000
+	KERNAL_ROM_ADDR uint16 = 0xE000
+	KERNAL_ROM_SIZE int    = 0x2000
+)
+
+// -------------------------------------------
+// Copy ROM images into the local model
+// -------------------------------------------
+func (ram *C64RAM) SetROMs(basicROM []byte, charROM []byte, kernalROM []byte) error {
+	if len(basicROM) != BASIC_ROM_SIZE || len(charROM) != CHAR_ROM_SIZE || len(kernalROM) != KERNAL_ROM_SIZE {
+		return fmt.Errorf("Invalid ROM sizes: BASIC %d, CHAR %d, KERNAL %d", len(basicROM), len(charROM), len(kernalROM))
+	}
+	copy(ram.basicROM[:], basicROM)
+	copy(ram.charROM[:], charROM)
+	copy(ram.kernalROM[:], kernalROM)
+	return nil
+}
+
+// -------------------------------------------
+// Read BASIC, CHAR and KERNAL ROM from the running emulator (sync mode).
+// ROMs are read with all ROMs banked in, $01 of the emulated C64 is
+// restored afterwards and emulation stays paused.
+// -------------------------------------------
+func (ram *C64RAM) LoadROMsFromEmulator(client *c64dws.Client) error {
+	view, err := client.NewMemoryView(c64dws.MemoryConfigROMs)
+	if err != nil {
+		return err
+	}
+	basicROM, err := view.Read(BASIC_ROM_ADDR, BASIC_ROM_SIZE)
+	if err != nil {
+		return err
+	}
+	charROM, err := view.Read(CHAR_ROM_ADDR, CHAR_ROM_SIZE)
+	if err != nil {
+		return err
+	}
+	kernalROM, err := view.Read(KERNAL_ROM_ADDR, KERNAL_ROM_SIZE)
+	if err != nil {
+		return err
+	}
+	return ram.SetROMs(basicROM, charROM, kernalROM)
+}
+
+// -------------------------------------------
+// ROM accessors, addresses are C64 addresses ($A000-$BFFF, $D000-$DFFF, $E000-$FFFF)
+// -------------------------------------------
+func (ram *C64RAM) BasicROMGetUByte8(address uint16) byte {
+	return ram.basicROM[address-BASIC_ROM_ADDR]
+}
+
+func (ram *C64RAM) CharROMGetUByte8(address uint16) byte {
+	return ram.charROM[address-CHAR_ROM_ADDR]
+}
+
+func (ram *C64RAM) KernalROMGetUByte8(address uint16) byte {
+	return ram.kernalROM[address-KERNAL_ROM_ADDR]
+}
+
+func (ram *C64RAM) BasicROM() []byte {
+	return ram.basicROM[:]
+}
+
+func (ram *C64RAM) CharROM() []byte {
+	return ram.charROM[:]
+}
+
+func (ram *C64RAM) KernalROM() []byte {
+	return ram.kernalROM[:]
+}
@@ -0,0 +1,146 @@
+package vc64
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"gotest.tools/assert"
+)
+
+// ----------------------------------------------------------------------
+// Fake Retro Debugger server with pause and CPU memory block access,
+// CPU memory reads see ROMs banked in by $01: BASIC $ba, CHAR $c4, KERNAL $ee
+// ----------------------------------------------------------------------
+type fakeServer struct {
+	ram [0x10000]byte
+}
+
+func (fake *fakeServer) cpuRead(address uint16) byte {
+	config := c64dws.MemoryConfigFromPort(fake.ram[0x01])
+	switch {
+	case config.BASIC && address >= BASIC_ROM_ADDR && address < 0xc000:
+		return 0xba
+	case config.CharROM && address >= CHAR_ROM_ADDR && address < KERNAL_ROM_ADDR:
+		return 0xc4
+	case config.KERNAL && address >= KERNAL_ROM_ADDR:
+		return 0xee
+	}
+	return fake.ram[address]
+}
+
+func (fake *fakeServer) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		textPart, binaryPart := message, []byte(nil)
+		if idx := bytes.IndexByte(message, 0); idx != -1 {
+			textPart, binaryPart = message[:idx], message[idx+1:]
+		}
+		var request struct {
+			Fn     string         `json:"fn"`
+			Params map[string]any `json:"params"`
+			Token  string         `json:"token"`
+		}
+		if err := json.Unmarshal(textPart, &request); err != nil {
+			return
+		}
+
+		address, _ := request.Params["address"].(float64)
+		size, _ := request.Params["size"].(float64)
+		var data []byte
+		switch strings.TrimPrefix(request.Fn, "c64/") {
+		case "cpu/memory/readBlock":
+			for idx := 0; idx < int(size); idx++ {
+				data = append(data, fake.cpuRead(uint16(int(address)+idx)))
+			}
+		case "cpu/memory/writeBlock":
+			copy(fake.ram[int(address):], binaryPart)
+		}
+
+		response, _ := json.Marshal(map[string]any{"status": 200, "token": request.Token, "result": map[string]any{}})
+		response = append(append(response, 0), data...)
+		if err := conn.WriteMessage(websocket.BinaryMessage, response); err != nil {
+			return
+		}
+	}
+}
+
+func newFakeServerClient(t *testing.T) (*c64dws.Client, *fakeServer) {
+	fake := &fakeServer{}
+	fake.ram[0x01] = MEM_CONFIG_0001_DEFAULT
+	server := httptest.NewServer(http.HandlerFunc(fake.serveWebSocket))
+	t.Cleanup(server.Close)
+
+	serverURL, err := url.Parse(server.URL)
+	assert.NilError(t, err)
+	port, err := strconv.Atoi(serverURL.Port())
+	assert.NilError(t, err)
+	host, err := c64dws.GetCustomHost(serverURL.Hostname(), port, "ws")
+	assert.NilError(t, err)
+
+	client := c64dws.NewCustomClient(c64dws.EmulatorC64, c64dws.StreamAPI, c64dws.TokenTypeAutoIncrement, c64dws.WS_DEFAULT_TOKEN_FORMAT, host)
+	_, err = client.Connect()
+	assert.NilError(t, err)
+	t.Cleanup(client.Close)
+
+	return client, fake
+}
+
+func TestLoadROMsFromEmulator(t *testing.T) {
+	client, fake := newFakeServerClient(t)
+	ram := NewC64RAM()
+
+	// -------------------------------------------------------------
+	// test: ROMs are read with all ROMs banked in, $01 is restored
+	// -------------------------------------------------------------
+	assert.NilError(t, ram.LoadROMsFromEmulator(client))
+	assert.Equal(t, ram.BasicROMGetUByte8(0xa000), byte(0xba))
+	assert.Equal(t, ram.CharROMGetUByte8(0xdfff), byte(0xc4))
+	assert.Equal(t, ram.KernalROMGetUByte8(0xfffe), byte(0xee))
+	assert.Equal(t, fake.ram[0x01], MEM_CONFIG_0001_DEFAULT)
+
+	// -------------------------------------------------------------
+	// test: CPU bus follows $01
+	// -------------------------------------------------------------
+	ram.CPUBusSetUByte8(0xa000, 0x01)
+	ram.CPUBusSetUByte8(0xd000, 0x02)
+	ram.CPUBusSetUByte8(0xe000, 0x03)
+	assert.Equal(t, ram.CPUBusGetUByte8(0xa000), byte(0xba))
+	assert.Equal(t, ram.CPUBusGetUByte8(0xd000), byte(0x02)) // I/O
+	assert.Equal(t, ram.CPUBusGetUByte8(0xe000), byte(0xee))
+
+	ram.CPUBusSetUByte8(MEM_CONFIG_0001, 0x33)
+	assert.Equal(t, ram.CPUBusGetUByte8(0xa000), byte(0xba))
+	assert.Equal(t, ram.CPUBusGetUByte8(0xd000), byte(0xc4))
+
+	ram.CPUBusSetUByte8(MEM_CONFIG_0001, 0x34)
+	assert.Equal(t, ram.CPUBusGetUByte8(0xa000), byte(0x01))
+	assert.Equal(t, ram.CPUBusGetUByte8(0xd000), byte(0x00)) // RAM under I/O wasn't written
+	assert.Equal(t, ram.CPUBusGetUByte8(0xe000), byte(0x03))
+
+	// -------------------------------------------------------------
+	// test: VIC sees character ROM in banks 0 and 2
+	// -------------------------------------------------------------
+	ram.VICBusSetUByte8(0x1000, 0x04)
+	ram.VICBusSetUByte8(0x5000, 0x05)
+	assert.Equal(t, ram.VICBusGetUByte8(0x1000), byte(0xc4))
+	assert.Equal(t, ram.VICBusGetUByte8(0x9fff), byte(0xc4))
+	assert.Equal(t, ram.VICBusGetUByte8(0x5000), byte(0x05))
+}
@@ -0,0 +1,112 @@
+package gdbstub
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Interrupt sent by GDB to stop the running target (Ctrl-C)
+const interruptByte = 0x03
+
+// Message received from GDB: packet data or interrupt
+type message struct {
+	packet    string
+	interrupt bool
+}
+
+// Packet checksum: sum of data bytes modulo 256
+func checksum(data string) byte {
+	var sum byte
+	for idx := 0; idx < len(data); idx++ {
+		sum += data[idx]
+	}
+	return sum
+}
+
+// Frame packet data as '$data#checksum', '$', '#', '}' and '*' are escaped
+func EncodePacket(data string) string {
+	var escaped strings.Builder
+	for idx := 0; idx < len(data); idx++ {
+		switch data[idx] {
+		case '$', '#', '}', '*':
+			escaped.WriteByte('}')
+			escaped.WriteByte(data[idx] ^ 0x20)
+		default:
+			escaped.WriteByte(data[idx])
+		}
+	}
+	return fmt.Sprintf("$%s#%02x", escaped.String(), checksum(escaped.String()))
+}
+
+// Read next packet or interrupt, acknowledgements are skipped.
+// Packets with invalid checksum return error with the packet data.
+func readMessage(reader *bufio.Reader) (message, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return message{}, err
+		}
+		switch b {
+		case interruptByte:
+			return message{interrupt: true}, nil
+		case '$':
+			return readPacket(reader)
+		}
+		// '+' and '-' acknowledgements and noise
+	}
+}
+
+func readPacket(reader *bufio.Reader) (message, error) {
+	data, err := reader.ReadString('#')
+	if err != nil {
+		return message{}, err
+	}
+	data = data[:len(data)-1]
+
+	sum := make([]byte, 2)
+	if _, err := io.ReadFull(reader, sum); err != nil {
+		return message{}, err
+	}
+	var expected byte
+	if _, err := fmt.Sscanf(string(sum), "%02x", &expected); err != nil || expected != checksum(data) {
+		return message{packet: data}, fmt.Errorf("Invalid checksum of packet '%s'", data)
+	}
+
+	return message{packet: unescape(data)}, nil
+}
+
+// Unescape '}' sequences and expand run-length encoding ('*')
+func unescape(data string) string {
+	var result strings.Builder
+	for idx := 0; idx < len(data); idx++ {
+		switch {
+		case data[idx] == '}' && idx+1 < len(data):
+			idx++
+			result.WriteByte(data[idx] ^ 0x20)
+		case data[idx] == '*' && idx+1 < len(data) && result.Len() > 0:
+			idx++
+			last := result.String()[result.Len()-1]
+			for count := int(data[idx]) - 29; count > 0; count-- {
+				result.WriteByte(last)
+			}
+		default:
+			result.WriteByte(data[idx])
+		}
+	}
+	return result.String()
+}
+
+// Read next packet, acknowledgements and interrupts are skipped, used by clients
+func ReadPacket(reader *bufio.Reader) (string, error) {
+	for {
+		message, err := readMessage(reader)
+		if err != nil {
+			return "", err
+		}
+		if !message.interrupt {
+			return message.packet, nil
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package gdbstub
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+)
+
+// Register numbers, the 'g' packet contains registers in this order,
+// PC is little endian like all 6502 words
+const (
+	registerA = iota
+	registerX
+	registerY
+	registerP
+	registerSP
+	registerPC
+)
+
+// Size of the 'g' packet data in bytes
+const registersSize = 7
+
+// Target description sent with qXfer:features:read
+const targetXML = `<?xml version="1.0"?>
+<!DOCTYPE target SYSTEM "gdb-target.dtd">
+<target version="1.0">
+  <architecture>6502</architecture>
+  <feature name="org.gnu.gdb.6502.core">
+    <reg name="a" bitsize="8" regnum="0" type="uint8"/>
+    <reg name="x" bitsize="8" regnum="1" type="uint8"/>
+    <reg name="y" bitsize="8" regnum="2" type="uint8"/>
+    <reg name="p" bitsize="8" regnum="3" type="uint8"/>
+    <reg name="sp" bitsize="8" regnum="4" type="uint8"/>
+    <reg name="pc" bitsize="16" regnum="5" type="code_ptr"/>
+  </feature>
+</target>
+`
+
+// Encode registers as hex: A, X, Y, P, SP, PC (little endian)
+func encodeRegisters(state c64dws.CPUState) string {
+	return hex.EncodeToString([]byte{state.A, state.X, state.Y, state.P, state.SP, uint8(state.PC), uint8(state.PC >> 8)})
+}
+
+// Decode 'G' packet data into the state
+func decodeRegisters(data string, state *c64dws.CPUState) error {
+	values, err := hex.DecodeString(data)
+	if err != nil || len(values) != registersSize {
+		return fmt.Errorf("Invalid registers data '%s'", data)
+	}
+	state.A, state.X, state.Y, state.P, state.SP = values[0], values[1], values[2], values[3], values[4]
+	state.PC = uint16(values[5]) | uint16(values[6])<<8
+	return nil
+}
+
+// Encode single register as hex
+func encodeRegister(state c64dws.CPUState, register int) (string, error) {
+	registers, err := hex.DecodeString(encodeRegisters(state))
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case register >= registerA && register < registerPC:
+		return hex.EncodeToString(registers[register : register+1]), nil
+	case register == registerPC:
+		return hex.EncodeToString(registers[registerPC:]), nil
+	default:
+		return "", fmt.Errorf("Unknown register %d", register)
+	}
+}
+
+// Set single register from 'P' packet value
+func setRegister(state *c64dws.CPUState, register int, value string) error {
+	bytes, err := hex.DecodeString(value)
+	if err != nil || len(bytes) == 0 {
+		return fmt.Errorf("Invalid register value '%s'", value)
+	}
+	switch register {
+	case registerA:
+		state.A = bytes[0]
+	case registerX:
+		state.X = bytes[0]
+	case registerY:
+		state.Y = bytes[0]
+	case registerP:
+		state.P = bytes[0]
+	case registerSP:
+		state.SP = bytes[0]
+	case registerPC:
+		if len(bytes) < 2 {
+			return fmt.Errorf("Invalid PC value '%s'", value)
+		}
+		state.PC = uint16(bytes[0]) | uint16(bytes[1])<<8
+	default:
+		return fmt.Errorf("Unknown register %d", register)
+	}
+	return nil
+}
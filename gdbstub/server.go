@@ -0,0 +1,603 @@
+// # GDB remote serial protocol stub
+//
+// This package implements a gdbserver-style stub for the 6510 CPU on top of
+// the Retro Debugger WebSocket API client, so debuggers with 6502 support can
+// attach to the emulator. Registers are described by the target description
+// (qXfer:features:read), the 'g' packet contains A, X, Y, P, SP and PC.
+//
+// Breakpoints: Z0/Z1 are CPU breakpoints, Z2 (write) and Z3 (read) watchpoints are
+// memory breakpoints. The emulator keeps a single memory breakpoint per address,
+// so Z4 (access) watchpoints are not supported and a watchpoint replaces another
+// one at the same address. The emulator doesn't report the address of a memory
+// breakpoint, so watchpoint hits are reported as plain SIGTRAP.
+package gdbstub
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+)
+
+// Default gdbserver port
+const DefaultPort = 2345
+
+// Stop replies
+const (
+	replyTrap      = "S05" // breakpoint or step
+	replyInterrupt = "S02" // stopped by Ctrl-C
+	replyOK        = "OK"
+	replyError     = "E01"
+)
+
+// Maximum number of bytes covered by a single watchpoint,
+// every address needs its own memory breakpoint
+const MaxWatchpointLength = 0x100
+
+// Stub serving one GDB connection at a time
+type Server struct {
+	client      *c64dws.Client
+	conn        io.Writer
+	writeMutex  sync.Mutex
+	noAck       atomic.Bool
+	breakpoints map[uint16]bool
+	watchpoints map[uint16]c64dws.MemoryBreakpointAccess
+}
+
+// Create stub using the connected client
+func NewServer(client *c64dws.Client) *Server {
+	return &Server{
+		client:      client,
+		breakpoints: map[uint16]bool{},
+		watchpoints: map[uint16]c64dws.MemoryBreakpointAccess{},
+	}
+}
+
+// Listen on TCP address (e.g. ':2345') and serve connections one by one
+func (s *Server) ListenAndServe(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		s.ServeConn(conn)
+		conn.Close()
+	}
+}
+
+// Serve GDB until it detaches, kills the target or closes the connection.
+// Emulation is paused while GDB is attached, breakpoints are removed on exit.
+func (s *Server) ServeConn(conn io.ReadWriter) error {
+	s.conn = conn
+	s.noAck.Store(false)
+	defer s.removeAll()
+
+	events, unsubscribe := s.client.SubscribeEvents()
+	defer unsubscribe()
+
+	if err := s.request(func(token string) error {
+		return s.client.PauseEmulation(token)
+	}); err != nil {
+		return err
+	}
+
+	messages := make(chan message)
+	done := make(chan struct{})
+	defer close(done)
+	go s.readMessages(bufio.NewReader(conn), messages, done)
+
+	for message := range messages {
+		if message.interrupt {
+			// already stopped
+			continue
+		}
+
+		reply, quit := s.handle(message.packet, events, messages)
+		if errors.Is(quit, errKill) {
+			return nil
+		}
+		s.sendPacket(reply)
+		if errors.Is(quit, errDetach) {
+			return nil
+		}
+		if message.packet == "QStartNoAckMode" {
+			s.noAck.Store(true)
+		}
+	}
+	return nil
+}
+
+// Returned by handlers ending the session
+var (
+	errDetach = errors.New("Detach")
+	errKill   = errors.New("Kill")
+)
+
+// Read messages and acknowledge packets until the connection is closed
+func (s *Server) readMessages(reader *bufio.Reader, messages chan<- message, done <-chan struct{}) {
+	defer close(messages)
+	for {
+		message, err := readMessage(reader)
+		if err != nil && message.packet == "" {
+			return
+		}
+		if err != nil {
+			// invalid checksum, ask for retransmission
+			if !s.noAck.Load() {
+				s.write("-")
+			}
+			continue
+		}
+		if !message.interrupt && !s.noAck.Load() {
+			s.write("+")
+		}
+		select {
+		case messages <- message:
+		case <-done:
+			return
+		}
+	}
+}
+
+func (s *Server) write(data string) {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+	io.WriteString(s.conn, data)
+}
+
+func (s *Server) sendPacket(data string) {
+	s.write(EncodePacket(data))
+}
+
+// Send request and wait for its response
+func (s *Server) request(send func(token string) error) error {
+	_, err := s.client.Request(send)
+	return err
+}
+
+// Handle packet, returns reply or errDetach/errKill
+func (s *Server) handle(packet string, events <-chan any, messages <-chan message) (string, error) {
+	if packet == "" {
+		return "", nil
+	}
+	command, arguments := packet[0], packet[1:]
+
+	var reply string
+	var err error
+	switch command {
+	case '?':
+		return replyTrap, nil
+	case 'g':
+		reply, err = s.readRegisters()
+	case 'G':
+		err = s.writeRegisters(arguments)
+		reply = replyOK
+	case 'p':
+		reply, err = s.readRegister(arguments)
+	case 'P':
+		err = s.writeRegister(arguments)
+		reply = replyOK
+	case 'm':
+		reply, err = s.readMemory(arguments)
+	case 'M':
+		err = s.writeMemory(arguments)
+		reply = replyOK
+	case 'Z', 'z':
+		reply, err = s.breakpoint(command == 'Z', arguments)
+	case 'c':
+		reply, err = s.resume(arguments, events, messages)
+	case 's':
+		reply, err = s.step(arguments)
+	case 'v':
+		reply, err = s.handleV(arguments, events, messages)
+	case 'q', 'Q':
+		reply = s.query(packet)
+	case 'H', 'T':
+		// single thread
+		reply = replyOK
+	case 'D':
+		if err := s.removeAll(); err != nil {
+			return replyError, nil
+		}
+		if err := s.request(func(token string) error {
+			return s.client.ContinueEmulation(token)
+		}); err != nil {
+			return replyError, nil
+		}
+		return replyOK, errDetach
+	case 'k':
+		return "", errKill
+	default:
+		// unsupported packets get empty reply
+		return "", nil
+	}
+
+	if errors.Is(err, errKill) {
+		return "", err
+	}
+	if err != nil {
+		return replyError, nil
+	}
+	return reply, nil
+}
+
+// ----------------------------------------------------------------------
+// Queries
+// ----------------------------------------------------------------------
+func (s *Server) query(packet string) string {
+	name, arguments, _ := strings.Cut(packet, ":")
+	switch name {
+	case "qSupported":
+		return "PacketSize=4000;qXfer:features:read+;QStartNoAckMode+"
+	case "QStartNoAckMode":
+		return replyOK
+	case "qAttached":
+		return "1"
+	case "qC":
+		return "QC1"
+	case "qfThreadInfo":
+		return "m1"
+	case "qsThreadInfo":
+		return "l"
+	case "qOffsets":
+		return "Text=0;Data=0;Bss=0"
+	case "qXfer":
+		return s.transferFeatures(arguments)
+	}
+	return ""
+}
+
+// Send target description: 'features:read:target.xml:offset,length'
+func (s *Server) transferFeatures(arguments string) string {
+	parts := strings.Split(arguments, ":")
+	if len(parts) != 4 || parts[0] != "features" || parts[1] != "read" {
+		return ""
+	}
+	if parts[2] != "target.xml" {
+		return "E00"
+	}
+	offset, length, err := parseAddressLength(parts[3])
+	if err != nil {
+		return replyError
+	}
+	if int(offset) >= len(targetXML) {
+		return "l"
+	}
+	end := min(int(offset)+length, len(targetXML))
+	if end == len(targetXML) {
+		return "l" + targetXML[offset:end]
+	}
+	return "m" + targetXML[offset:end]
+}
+
+// ----------------------------------------------------------------------
+// Registers and memory
+// ----------------------------------------------------------------------
+func (s *Server) readRegisters() (string, error) {
+	state, err := s.client.ReadCPUStatus()
+	if err != nil {
+		return "", err
+	}
+	return encodeRegisters(state), nil
+}
+
+func (s *Server) writeRegisters(arguments string) error {
+	var state c64dws.CPUState
+	if err := decodeRegisters(arguments, &state); err != nil {
+		return err
+	}
+	return s.client.SetRegisters(state)
+}
+
+// 'p n', register number is hex
+func (s *Server) readRegister(arguments string) (string, error) {
+	register, err := strconv.ParseUint(arguments, 16, 8)
+	if err != nil {
+		return "", err
+	}
+	state, err := s.client.ReadCPUStatus()
+	if err != nil {
+		return "", err
+	}
+	return encodeRegister(state, int(register))
+}
+
+// 'P n=value'
+func (s *Server) writeRegister(arguments string) error {
+	number, value, found := strings.Cut(arguments, "=")
+	if !found {
+		return fmt.Errorf("Invalid register write '%s'", arguments)
+	}
+	register, err := strconv.ParseUint(number, 16, 8)
+	if err != nil {
+		return err
+	}
+	state, err := s.client.ReadCPUStatus()
+	if err != nil {
+		return err
+	}
+	if err := setRegister(&state, int(register), value); err != nil {
+		return err
+	}
+	return s.client.SetRegisters(state)
+}
+
+// Parse 'address,length' (hex)
+func parseAddressLength(arguments string) (uint16, int, error) {
+	addressText, lengthText, found := strings.Cut(arguments, ",")
+	if !found {
+		return 0, 0, fmt.Errorf("Invalid address and length '%s'", arguments)
+	}
+	address, err := strconv.ParseUint(addressText, 16, 16)
+	if err != nil {
+		return 0, 0, err
+	}
+	length, err := strconv.ParseUint(lengthText, 16, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint16(address), int(length), nil
+}
+
+// 'm address,length', memory as seen by CPU
+func (s *Server) readMemory(arguments string) (string, error) {
+	address, length, err := parseAddressLength(arguments)
+	if err != nil {
+		return "", err
+	}
+	length = min(length, 0x10000-int(address))
+	if length == 0 {
+		return "", nil
+	}
+	data, err := s.client.ReadMemory(c64dws.MemorySpaceCPU, address, length)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// 'M address,length:data'
+func (s *Server) writeMemory(arguments string) error {
+	addressLength, dataText, found := strings.Cut(arguments, ":")
+	if !found {
+		return fmt.Errorf("Invalid memory write '%s'", arguments)
+	}
+	address, length, err := parseAddressLength(addressLength)
+	if err != nil {
+		return err
+	}
+	data, err := hex.DecodeString(dataText)
+	if err != nil || len(data) != length {
+		return fmt.Errorf("Invalid memory data '%s'", dataText)
+	}
+	if length == 0 {
+		return nil
+	}
+	return s.client.WriteMemory(c64dws.MemorySpaceCPU, address, data)
+}
+
+// ----------------------------------------------------------------------
+// Breakpoints
+// ----------------------------------------------------------------------
+
+// 'Z type,address,kind' and 'z type,address,kind'
+func (s *Server) breakpoint(insert bool, arguments string) (string, error) {
+	breakpointType, addressLength, found := strings.Cut(arguments, ",")
+	if !found {
+		return "", fmt.Errorf("Invalid breakpoint '%s'", arguments)
+	}
+	address, kind, err := parseAddressLength(addressLength)
+	if err != nil {
+		return "", err
+	}
+
+	var access c64dws.MemoryBreakpointAccess
+	switch breakpointType {
+	case "0", "1":
+		if insert {
+			return replyOK, s.addBreakpoint(address)
+		}
+		return replyOK, s.removeBreakpoint(address)
+	case "2":
+		access = c64dws.MemoryBreakpointAccessWrite
+	case "3":
+		access = c64dws.MemoryBreakpointAccessRead
+	default:
+		// unsupported breakpoint type, Z4 would need read and write breakpoints at one address
+		return "", nil
+	}
+
+	// kind is the watched length
+	if kind < 1 || kind > MaxWatchpointLength || int(address)+kind > 0x10000 {
+		return "", fmt.Errorf("Invalid watchpoint length %d", kind)
+	}
+	for offset := 0; offset < kind; offset++ {
+		if insert {
+			err = s.addWatchpoint(address+uint16(offset), access)
+		} else {
+			err = s.removeWatchpoint(address + uint16(offset))
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return replyOK, nil
+}
+
+func (s *Server) addBreakpoint(address uint16) error {
+	if s.breakpoints[address] {
+		return nil
+	}
+	if err := s.request(func(token string) error {
+		return s.client.AddCPUBreakpoint(address, token)
+	}); err != nil {
+		return err
+	}
+	s.breakpoints[address] = true
+	return nil
+}
+
+func (s *Server) removeBreakpoint(address uint16) error {
+	if !s.breakpoints[address] {
+		return nil
+	}
+	delete(s.breakpoints, address)
+	return s.request(func(token string) error {
+		return s.client.RemoveCPUBreakpoint(address, token)
+	})
+}
+
+func (s *Server) addWatchpoint(address uint16, access c64dws.MemoryBreakpointAccess) error {
+	if s.watchpoints[address] != "" {
+		if err := s.removeWatchpoint(address); err != nil {
+			return err
+		}
+	}
+	// value 0 with '>=' matches any value
+	if err := s.request(func(token string) error {
+		return s.client.AddCPUMemoryBreakpoint(address, 0, access, ">=", token)
+	}); err != nil {
+		return err
+	}
+	s.watchpoints[address] = access
+	return nil
+}
+
+func (s *Server) removeWatchpoint(address uint16) error {
+	if s.watchpoints[address] == "" {
+		return nil
+	}
+	delete(s.watchpoints, address)
+	return s.request(func(token string) error {
+		return s.client.RemoveCPUMemoryBreakpoint(address, 0, token)
+	})
+}
+
+// Remove all breakpoints and watchpoints
+func (s *Server) removeAll() error {
+	var addresses []uint16
+	for address := range s.breakpoints {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool { return addresses[i] < addresses[j] })
+	for _, address := range addresses {
+		if err := s.removeBreakpoint(address); err != nil {
+			return err
+		}
+	}
+
+	addresses = addresses[:0]
+	for address := range s.watchpoints {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool { return addresses[i] < addresses[j] })
+	for _, address := range addresses {
+		if err := s.removeWatchpoint(address); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------
+// Execution
+// ----------------------------------------------------------------------
+
+// Jump to the optional resume address ('c addr', 's addr')
+func (s *Server) jumpTo(arguments string) error {
+	if arguments == "" {
+		return nil
+	}
+	address, err := strconv.ParseUint(arguments, 16, 16)
+	if err != nil {
+		return err
+	}
+	return s.request(func(token string) error {
+		return s.client.CPUMakeJMP(uint16(address), token)
+	})
+}
+
+func (s *Server) step(arguments string) (string, error) {
+	if err := s.jumpTo(arguments); err != nil {
+		return "", err
+	}
+	if err := s.request(func(token string) error {
+		return s.client.StepInstruction(token)
+	}); err != nil {
+		return "", err
+	}
+	return replyTrap, nil
+}
+
+// Continue until a breakpoint is hit or GDB sends interrupt
+func (s *Server) resume(arguments string, events <-chan any, messages <-chan message) (string, error) {
+	if err := s.jumpTo(arguments); err != nil {
+		return "", err
+	}
+
+	// drop events received while stopped
+	for drained := false; !drained; {
+		select {
+		case <-events:
+		default:
+			drained = true
+		}
+	}
+
+	if err := s.request(func(token string) error {
+		return s.client.ContinueEmulation(token)
+	}); err != nil {
+		return "", err
+	}
+
+	for {
+		select {
+		case event := <-events:
+			if c64dws.IsCPUBreakpointEvent(event) || c64dws.IsMemoryBreakpointEvent(event) || c64dws.IsRasterBreakpointEvent(event) {
+				return replyTrap, nil
+			}
+		case message, ok := <-messages:
+			if !ok {
+				return "", errKill
+			}
+			if message.interrupt {
+				return replyInterrupt, s.request(func(token string) error {
+					return s.client.PauseEmulation(token)
+				})
+			}
+		}
+	}
+}
+
+// 'vCont?' and 'vCont;action[:thread]...', only the first action is used
+func (s *Server) handleV(arguments string, events <-chan any, messages <-chan message) (string, error) {
+	switch {
+	case arguments == "Cont?":
+		return "vCont;c;s", nil
+	case strings.HasPrefix(arguments, "Cont;"):
+		action, _, _ := strings.Cut(strings.TrimPrefix(arguments, "Cont;"), ";")
+		action, _, _ = strings.Cut(action, ":")
+		switch action {
+		case "c":
+			return s.resume("", events, messages)
+		case "s":
+			return s.step("")
+		}
+	}
+	return "", nil
+}
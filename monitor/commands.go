@@ -0,0 +1,583 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mojzesh/c64d-ws-client/asm6502"
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+)
+
+// Monitor command
+type command struct {
+	usage   string
+	help    string
+	minArgs int
+	run     func(ctx context.Context, m *Monitor, args []string) error
+}
+
+// Default number of bytes shown by 'm'
+const defaultDumpSize = 0x80
+
+// Default number of instructions shown by 'd'
+const defaultDisassemblyLines = 16
+
+// Cheat candidates are listed after each search when there are at most this many
+const maxListedCheatCandidates = 16
+
+var commands map[string]*command
+
+func init() {
+	commands = map[string]*command{
+		"m": {
+			usage: "m [start [end]]",
+			help:  "memory dump",
+			run:   cmdMemory,
+		},
+		"d": {
+			usage: "d [start [end]]",
+			help:  "disassemble",
+			run:   cmdDisassemble,
+		},
+		"r": {
+			usage: "r",
+			help:  "show registers",
+			run:   cmdRegisters,
+		},
+		"g": {
+			usage: "g [address]",
+			help:  "go (continue emulation), waits for a breakpoint if any is set",
+			run:   cmdGo,
+		},
+		"b": {
+			usage: "b [address]",
+			help:  "set breakpoint, list breakpoints without address",
+			run:   cmdBreakpoint,
+		},
+		"bd": {
+			usage: "bd [address]",
+			help:  "delete breakpoint, all breakpoints without address",
+			run:   cmdDeleteBreakpoint,
+		},
+		"z": {
+			usage: "z [count]",
+			help:  "step instruction",
+			run:   cmdStep,
+		},
+		"n": {
+			usage: "n [count]",
+			help:  "step over subroutine",
+			run:   cmdNext,
+		},
+		"f": {
+			usage:   "f start end byte [byte...]",
+			help:    "fill memory with the byte pattern",
+			minArgs: 3,
+			run:     cmdFill,
+		},
+		"h": {
+			usage:   "h start end byte|??|a:text|p:text|s:text [...]",
+			help:    "hunt for the pattern, ?? matches any byte, a:/p:/s: strings are ASCII/PETSCII/screen codes",
+			minArgs: 3,
+			run:     cmdHunt,
+		},
+		"cheat": {
+			usage:   "cheat start [start end]|dec|inc|same|diff|eq value|list|poke value [address]",
+			help:    "cheat finder: start search, narrow candidates, list and poke them",
+			minArgs: 1,
+			run:     cmdCheat,
+		},
+		"l": {
+			usage:   "l \"file\" [address]",
+			help:    "load PRG file (or raw file at the address)",
+			minArgs: 1,
+			run:     cmdLoad,
+		},
+		"s": {
+			usage:   "s \"file\" start end",
+			help:    "save memory as PRG file",
+			minArgs: 3,
+			run:     cmdSave,
+		},
+		">": {
+			usage:   "> address byte [byte...]",
+			help:    "write bytes to memory",
+			minArgs: 2,
+			run:     cmdPoke,
+		},
+		"ll": {
+			usage:   "ll \"file\"",
+			help:    "load symbols (KickAssembler, VICE, ACME)",
+			minArgs: 1,
+			run:     cmdLoadSymbols,
+		},
+		"bt": {
+			usage: "bt",
+			help:  "show call stack",
+			run:   cmdBacktrace,
+		},
+		"style": {
+			usage:   "style ar|kickass [columns]",
+			help:    "set memory dump style",
+			minArgs: 1,
+			run:     cmdStyle,
+		},
+		"history": {
+			usage: "history",
+			help:  "show command history, repeat with !! or !n",
+			run:   cmdHistory,
+		},
+		"help": {
+			usage: "help",
+			help:  "show this help",
+			run:   cmdHelp,
+		},
+		"x": {
+			usage: "x",
+			help:  "exit monitor",
+			run:   cmdQuit,
+		},
+	}
+	commands["?"] = commands["help"]
+	commands["q"] = commands["x"]
+}
+
+func cmdMemory(ctx context.Context, m *Monitor, args []string) error {
+	start, end, err := m.parseRange(args, defaultDumpSize)
+	if err != nil {
+		return err
+	}
+	data, err := m.client.ReadMemory(c64dws.MemorySpaceCPU, start, int(end)-int(start)+1)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(m.out, FormatDump(start, data, m.dumpColumns, m.dumpStyle))
+	m.nextAddress = end + 1
+	return nil
+}
+
+func cmdDisassemble(ctx context.Context, m *Monitor, args []string) error {
+	start, end, err := m.parseRange(args, defaultDisassemblyLines*3)
+	if err != nil {
+		return err
+	}
+	lines := 0
+	if len(args) < 2 {
+		lines = defaultDisassemblyLines
+	}
+	next, err := m.disassemble(start, end, lines)
+	if err != nil {
+		return err
+	}
+	m.nextAddress = next
+	return nil
+}
+
+// Print instructions from start to end, at most lines instructions if lines > 0.
+// Returns address of the next instruction.
+func (m *Monitor) disassemble(start uint16, end uint16, lines int) (uint16, error) {
+	size := min(int(end)-int(start)+3, 0x10000-int(start))
+	data, err := m.client.ReadMemory(c64dws.MemorySpaceCPU, start, size)
+	if err != nil {
+		return 0, err
+	}
+
+	labeler := func(address uint16) string {
+		name, _ := m.client.Symbols().NameAt(address)
+		return name
+	}
+
+	next := start
+	for idx, instruction := range asm6502.DisassembleBlock(start, data) {
+		if instruction.Address > end || (lines > 0 && idx >= lines) {
+			break
+		}
+		if label := labeler(instruction.Address); label != "" {
+			fmt.Fprintf(m.out, "%s:\n", label)
+		}
+		fmt.Fprintf(m.out, ".C:%04x  %-8s  %s\n", instruction.Address, instruction.HexBytes(), instruction.Format(labeler))
+		next = instruction.Address + uint16(instruction.Size())
+	}
+	return next, nil
+}
+
+func cmdRegisters(ctx context.Context, m *Monitor, args []string) error {
+	return m.printRegisters()
+}
+
+func (m *Monitor) printRegisters() error {
+	state, err := m.client.ReadCPUStatus()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(m.out, "  ADDR A  X  Y  SP 01 NV-BDIZC LIN CYC")
+	fmt.Fprintf(m.out, ".;%04x %02x %02x %02x %02x %02x %s %03x %03d\n",
+		state.PC, state.A, state.X, state.Y, state.SP, state.Memory0001, state.FlagsString(), state.RasterY, state.RasterCycle)
+	return nil
+}
+
+func cmdGo(ctx context.Context, m *Monitor, args []string) error {
+	if len(args) > 0 {
+		address, err := m.parseAddress(args[0])
+		if err != nil {
+			return err
+		}
+		if _, err := m.client.Request(func(token string) error {
+			return m.client.CPUMakeJMP(address, token)
+		}); err != nil {
+			return err
+		}
+	}
+
+	events, unsubscribe := m.client.SubscribeEvents()
+	defer unsubscribe()
+
+	if _, err := m.client.Request(func(token string) error {
+		return m.client.ContinueEmulation(token)
+	}); err != nil {
+		return err
+	}
+	if len(m.breakpoints) == 0 {
+		return nil
+	}
+
+	if _, err := m.client.WaitForEvent(ctx, events, c64dws.IsCPUBreakpointEvent); err != nil {
+		return err
+	}
+	fmt.Fprintln(m.out, "BREAK")
+	if err := m.printRegisters(); err != nil {
+		return err
+	}
+	state, err := m.client.ReadCPUStatus()
+	if err != nil {
+		return err
+	}
+	m.nextAddress, err = m.disassemble(state.PC, state.PC, 1)
+	return err
+}
+
+func cmdBreakpoint(ctx context.Context, m *Monitor, args []string) error {
+	if len(args) == 0 {
+		for _, address := range m.sortedBreakpoints() {
+			fmt.Fprintf(m.out, "BREAK: %s\n", m.client.DescribeAddress(address))
+		}
+		return nil
+	}
+
+	address, err := m.parseAddress(args[0])
+	if err != nil {
+		return err
+	}
+	if _, err := m.client.Request(func(token string) error {
+		return m.client.AddCPUBreakpoint(address, token)
+	}); err != nil {
+		return err
+	}
+	m.breakpoints[address] = true
+	return nil
+}
+
+func cmdDeleteBreakpoint(ctx context.Context, m *Monitor, args []string) error {
+	addresses := m.sortedBreakpoints()
+	if len(args) > 0 {
+		address, err := m.parseAddress(args[0])
+		if err != nil {
+			return err
+		}
+		addresses = []uint16{address}
+	}
+
+	for _, address := range addresses {
+		if _, err := m.client.Request(func(token string) error {
+			return m.client.RemoveCPUBreakpoint(address, token)
+		}); err != nil {
+			return err
+		}
+		delete(m.breakpoints, address)
+	}
+	return nil
+}
+
+func cmdStep(ctx context.Context, m *Monitor, args []string) error {
+	return m.step(args, func(token string) error {
+		return m.client.StepInstruction(token)
+	})
+}
+
+func cmdNext(ctx context.Context, m *Monitor, args []string) error {
+	return m.step(args, func(token string) error {
+		return m.client.StepSubroutine(token)
+	})
+}
+
+// Step count times, then show the next instruction
+func (m *Monitor) step(args []string, send func(token string) error) error {
+	count := 1
+	if len(args) > 0 {
+		value, err := strconv.Atoi(args[0])
+		if err != nil || value < 1 {
+			return fmt.Errorf("Invalid count '%s'", args[0])
+		}
+		count = value
+	}
+
+	for step := 0; step < count; step++ {
+		if _, err := m.client.Request(send); err != nil {
+			return err
+		}
+	}
+
+	state, err := m.client.ReadCPUStatus()
+	if err != nil {
+		return err
+	}
+	m.nextAddress, err = m.disassemble(state.PC, state.PC, 1)
+	return err
+}
+
+func cmdFill(ctx context.Context, m *Monitor, args []string) error {
+	start, end, err := m.parseRange(args[:2], 0)
+	if err != nil {
+		return err
+	}
+	pattern, err := parseBytes(args[2:])
+	if err != nil {
+		return err
+	}
+
+	size := int(end) - int(start) + 1
+	data := bytes.Repeat(pattern, size/len(pattern)+1)[:size]
+	return m.client.WriteMemory(c64dws.MemorySpaceCPU, start, data)
+}
+
+func cmdHunt(ctx context.Context, m *Monitor, args []string) error {
+	start, end, err := m.parseRange(args[:2], 0)
+	if err != nil {
+		return err
+	}
+	pattern, err := c64dws.ParseHuntPattern(args[2:])
+	if err != nil {
+		return err
+	}
+
+	found, err := m.client.Hunt(c64dws.MemorySpaceCPU, []c64dws.AddressRange{{Start: start, End: end}}, pattern)
+	if err != nil {
+		return err
+	}
+	addresses := make([]string, len(found))
+	for idx, address := range found {
+		addresses[idx] = fmt.Sprintf("%04x", address)
+	}
+	if len(addresses) > 0 {
+		fmt.Fprintln(m.out, strings.Join(addresses, " "))
+	}
+	return nil
+}
+
+func cmdCheat(ctx context.Context, m *Monitor, args []string) error {
+	switch strings.ToLower(args[0]) {
+	case "start":
+		var ranges []c64dws.AddressRange
+		if len(args) > 1 {
+			start, end, err := m.parseRange(args[1:], 1)
+			if err != nil {
+				return err
+			}
+			ranges = append(ranges, c64dws.AddressRange{Start: start, End: end})
+		}
+		finder, err := m.client.NewCheatFinder(ranges)
+		if err != nil {
+			return err
+		}
+		m.cheat = finder
+		fmt.Fprintf(m.out, "%d candidates\n", finder.Len())
+		return nil
+	case "list":
+		if m.cheat == nil {
+			return errors.New("No cheat search, use 'cheat start'")
+		}
+		m.listCheatCandidates()
+		return nil
+	case "poke":
+		if m.cheat == nil {
+			return errors.New("No cheat search, use 'cheat start'")
+		}
+		if len(args) < 2 {
+			return errors.New("Missing value")
+		}
+		value, err := parseBytes(args[1:2])
+		if err != nil {
+			return err
+		}
+		if len(args) > 2 {
+			address, err := m.parseAddress(args[2])
+			if err != nil {
+				return err
+			}
+			return m.cheat.Poke(address, value[0])
+		}
+		return m.cheat.PokeAll(value[0])
+	}
+
+	condition, err := c64dws.ParseCheatCondition(args[0])
+	if err != nil {
+		return err
+	}
+	if m.cheat == nil {
+		return errors.New("No cheat search, use 'cheat start'")
+	}
+	var value []byte
+	if condition == c64dws.CheatEquals {
+		if len(args) < 2 {
+			return errors.New("Missing value")
+		}
+		if value, err = parseBytes(args[1:2]); err != nil {
+			return err
+		}
+	} else {
+		value = []byte{0}
+	}
+
+	count, err := m.cheat.Filter(condition, value[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(m.out, "%d candidates\n", count)
+	if count <= maxListedCheatCandidates {
+		m.listCheatCandidates()
+	}
+	return nil
+}
+
+// List cheat candidates in the Action Replay dump style
+func (m *Monitor) listCheatCandidates() {
+	for _, candidate := range m.cheat.Candidates() {
+		fmt.Fprint(m.out, FormatDump(candidate.Address, []byte{candidate.Value}, 1, DumpStyleActionReplay))
+	}
+}
+
+func cmdLoad(ctx context.Context, m *Monitor, args []string) error {
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	var address uint16
+	if len(args) > 1 {
+		// raw file at the address
+		if address, err = m.parseAddress(args[1]); err != nil {
+			return err
+		}
+	} else {
+		// PRG file, first two bytes are the load address
+		if len(data) < 2 {
+			return fmt.Errorf("File '%s' is too short to be a PRG file", args[0])
+		}
+		address = uint16(data[0]) | uint16(data[1])<<8
+		data = data[2:]
+	}
+
+	if err := m.client.WriteMemory(c64dws.MemorySpaceRAM, address, data); err != nil {
+		return err
+	}
+	fmt.Fprintf(m.out, "Loaded $%04x-$%04x\n", address, int(address)+len(data)-1)
+	return nil
+}
+
+func cmdSave(ctx context.Context, m *Monitor, args []string) error {
+	start, end, err := m.parseRange(args[1:3], 0)
+	if err != nil {
+		return err
+	}
+	data, err := m.client.ReadMemory(c64dws.MemorySpaceRAM, start, int(end)-int(start)+1)
+	if err != nil {
+		return err
+	}
+
+	prg := append([]byte{byte(start), byte(start >> 8)}, data...)
+	if err := os.WriteFile(args[0], prg, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(m.out, "Saved $%04x-$%04x\n", start, end)
+	return nil
+}
+
+func cmdPoke(ctx context.Context, m *Monitor, args []string) error {
+	address, err := m.parseAddress(args[0])
+	if err != nil {
+		return err
+	}
+	data, err := parseBytes(args[1:])
+	if err != nil {
+		return err
+	}
+	return m.client.WriteMemory(c64dws.MemorySpaceCPU, address, data)
+}
+
+func cmdLoadSymbols(ctx context.Context, m *Monitor, args []string) error {
+	if err := m.client.LoadSymbols(args[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(m.out, "Symbols loaded: %d\n", m.client.Symbols().Len())
+	return nil
+}
+
+func cmdBacktrace(ctx context.Context, m *Monitor, args []string) error {
+	frames, err := m.client.CallStack()
+	if err != nil {
+		return err
+	}
+	for idx, frame := range frames {
+		fmt.Fprintf(m.out, "#%d %s\n", idx, frame)
+	}
+	return nil
+}
+
+func cmdStyle(ctx context.Context, m *Monitor, args []string) error {
+	style, err := ParseDumpStyle(args[0])
+	if err != nil {
+		return err
+	}
+	columns := 0
+	if len(args) > 1 {
+		if columns, err = strconv.Atoi(args[1]); err != nil || columns < 1 {
+			return fmt.Errorf("Invalid columns count '%s'", args[1])
+		}
+	}
+	m.SetDumpStyle(style, columns)
+	return nil
+}
+
+func cmdHistory(ctx context.Context, m *Monitor, args []string) error {
+	for idx, line := range m.history {
+		fmt.Fprintf(m.out, "%4d  %s\n", idx+1, line)
+	}
+	return nil
+}
+
+func cmdHelp(ctx context.Context, m *Monitor, args []string) error {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		command := commands[name]
+		if !strings.HasPrefix(command.usage, name) {
+			// alias
+			continue
+		}
+		fmt.Fprintf(m.out, "%-34s %s\n", command.usage, command.help)
+	}
+	return nil
+}
+
+func cmdQuit(ctx context.Context, m *Monitor, args []string) error {
+	return ErrQuit
+}
@@ -0,0 +1,78 @@
+package monitor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Memory dump styles, same as in vc64.DumpRAM
+type DumpStyle int
+
+const (
+	DumpStyleActionReplay DumpStyle = iota // ':0810  a9 01 8d 20 d0 ...  ....'
+	DumpStyleKickAss                       // '.byte $a9, $01, $8d, $20, $d0, ...'
+)
+
+// Default number of bytes per dump line
+const DefaultDumpColumns = 8
+
+// Dump style name
+func (style DumpStyle) String() string {
+	switch style {
+	case DumpStyleActionReplay:
+		return "ar"
+	case DumpStyleKickAss:
+		return "kickass"
+	default:
+		return "unknown"
+	}
+}
+
+// Parse dump style name: 'ar' (Action Replay) or 'kickass'
+func ParseDumpStyle(name string) (DumpStyle, error) {
+	switch strings.ToLower(name) {
+	case "ar", "actionreplay":
+		return DumpStyleActionReplay, nil
+	case "ka", "kick", "kickass":
+		return DumpStyleKickAss, nil
+	}
+	return 0, fmt.Errorf("Unknown dump style '%s', use 'ar' or 'kickass'", name)
+}
+
+// Format memory dump, one line per columns bytes
+func FormatDump(address uint16, data []byte, columns int, style DumpStyle) string {
+	if columns <= 0 {
+		columns = DefaultDumpColumns
+	}
+
+	var output strings.Builder
+	for offset := 0; offset < len(data); offset += columns {
+		line := data[offset:min(len(data), offset+columns)]
+		values := make([]string, len(line))
+		for idx, value := range line {
+			values[idx] = fmt.Sprintf("%02x", value)
+		}
+
+		switch style {
+		case DumpStyleKickAss:
+			output.WriteString(".byte $" + strings.Join(values, ", $"))
+		default:
+			output.WriteString(fmt.Sprintf(":%04x  %-*s  %s", address+uint16(offset), columns*3-1, strings.Join(values, " "), printableText(line)))
+		}
+		output.WriteString("\n")
+	}
+	return output.String()
+}
+
+// Printable representation of bytes, non printable bytes are shown as '.'
+func printableText(data []byte) string {
+	text := make([]byte, len(data))
+	for idx, value := range data {
+		if value >= 0x20 && value < 0x7f {
+			text[idx] = value
+		} else {
+			text[idx] = '.'
+		}
+	}
+	return string(text)
+}
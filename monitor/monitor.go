@@ -0,0 +1,262 @@
+// # Machine code monitor
+//
+// This package implements a machine code monitor modeled on the VICE and
+// Action Replay monitors. All commands are executed with the Retro Debugger
+// WebSocket API client (sync mode). Numbers are hexadecimal by default,
+// symbol names and expressions like 'main+3' are accepted as addresses.
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+)
+
+// Returned by Execute when the user wants to leave the monitor
+var ErrQuit = errors.New("Quit")
+
+// Machine code monitor
+type Monitor struct {
+	client      *c64dws.Client
+	out         io.Writer
+	dumpStyle   DumpStyle
+	dumpColumns int
+	breakpoints map[uint16]bool
+	history     []string
+	nextAddress uint16 // address used by 'm' and 'd' without arguments
+	cheat       *c64dws.CheatFinder
+}
+
+// Create monitor writing its output to out
+func New(client *c64dws.Client, out io.Writer) *Monitor {
+	return &Monitor{
+		client:      client,
+		out:         out,
+		dumpStyle:   DumpStyleActionReplay,
+		dumpColumns: DefaultDumpColumns,
+		breakpoints: map[uint16]bool{},
+	}
+}
+
+// Set memory dump style and number of bytes per line (0 means default)
+func (m *Monitor) SetDumpStyle(style DumpStyle, columns int) {
+	m.dumpStyle = style
+	m.dumpColumns = columns
+	if columns <= 0 {
+		m.dumpColumns = DefaultDumpColumns
+	}
+}
+
+// Executed command lines, oldest first
+func (m *Monitor) History() []string {
+	return m.history
+}
+
+// Set history, e.g. loaded from a file
+func (m *Monitor) SetHistory(history []string) {
+	m.history = append([]string{}, history...)
+}
+
+// Execute commands read line by line until EOF or quit command.
+// Errors are printed and don't stop execution unless stopOnError is set.
+// Empty lines and lines starting with ';' or '#' are skipped.
+func (m *Monitor) Run(ctx context.Context, in io.Reader, prompt string, stopOnError bool) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		if prompt != "" {
+			fmt.Fprint(m.out, prompt)
+		}
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+
+		err := m.Execute(ctx, scanner.Text())
+		if errors.Is(err, ErrQuit) {
+			return nil
+		}
+		if err != nil {
+			if stopOnError {
+				return err
+			}
+			fmt.Fprintf(m.out, "Error: %s\n", err)
+		}
+	}
+}
+
+// Execute commands from the script file
+func (m *Monitor) RunScript(ctx context.Context, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return m.Run(ctx, file, "", true)
+}
+
+// Execute single command line.
+// '!!' repeats the last command, '!n' repeats n-th command from the history.
+func (m *Monitor) Execute(ctx context.Context, line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+		return nil
+	}
+
+	line, err := m.expandHistory(line)
+	if err != nil {
+		return err
+	}
+	m.history = append(m.history, line)
+
+	name, args, err := splitCommand(line)
+	if err != nil {
+		return err
+	}
+	command, exist := commands[name]
+	if !exist {
+		return fmt.Errorf("Unknown command '%s', type 'help' for the list of commands", name)
+	}
+	if len(args) < command.minArgs {
+		return fmt.Errorf("Usage: %s", command.usage)
+	}
+
+	return command.run(ctx, m, args)
+}
+
+func (m *Monitor) expandHistory(line string) (string, error) {
+	if !strings.HasPrefix(line, "!") {
+		return line, nil
+	}
+	if len(m.history) == 0 {
+		return "", errors.New("History is empty")
+	}
+	if line == "!!" {
+		return m.history[len(m.history)-1], nil
+	}
+	idx, err := strconv.Atoi(line[1:])
+	if err != nil || idx < 1 || idx > len(m.history) {
+		return "", fmt.Errorf("No such history entry '%s'", line)
+	}
+	return m.history[idx-1], nil
+}
+
+// Split command line into command name and arguments, quoted arguments may contain spaces.
+// '>' may be directly followed by the address, e.g. '>0810 a9 01'.
+func splitCommand(line string) (string, []string, error) {
+	var fields []string
+	var field strings.Builder
+	inField, quoted := false, false
+
+	for _, ch := range line {
+		switch {
+		case ch == '"':
+			quoted = !quoted
+			inField = true
+		case !quoted && (ch == ' ' || ch == '\t' || ch == ','):
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(ch)
+			inField = true
+		}
+	}
+	if quoted {
+		return "", nil, errors.New("Unterminated quote")
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	if len(fields) == 0 {
+		return "", nil, errors.New("Missing command, type 'help' for the list of commands")
+	}
+
+	name := strings.ToLower(fields[0])
+	if strings.HasPrefix(name, ">") && len(name) > 1 {
+		return ">", append([]string{fields[0][1:]}, fields[1:]...), nil
+	}
+	return name, fields[1:], nil
+}
+
+// Parse address: hexadecimal number ('0810', '$0810') or symbol expression ('main+3')
+func (m *Monitor) parseAddress(text string) (uint16, error) {
+	if value, err := parseHex(text); err == nil {
+		return uint16(value), nil
+	}
+	address, err := m.client.ResolveAddress(text)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid address '%s'", text)
+	}
+	return address, nil
+}
+
+// Parse start and optional end address, end defaults to start + defaultSize - 1
+func (m *Monitor) parseRange(args []string, defaultSize int) (uint16, uint16, error) {
+	start := m.nextAddress
+	if len(args) > 0 {
+		var err error
+		if start, err = m.parseAddress(args[0]); err != nil {
+			return 0, 0, err
+		}
+	}
+	end := uint16(min(int(start)+defaultSize-1, 0xffff))
+	if len(args) > 1 {
+		var err error
+		if end, err = m.parseAddress(args[1]); err != nil {
+			return 0, 0, err
+		}
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("End address $%04x is lower than start address $%04x", end, start)
+	}
+	return start, end, nil
+}
+
+// Parse byte values, hexadecimal by default
+func parseBytes(args []string) ([]byte, error) {
+	data := make([]byte, 0, len(args))
+	for _, arg := range args {
+		value, err := parseHex(arg)
+		if err != nil || value > 0xff {
+			return nil, fmt.Errorf("Invalid byte value '%s'", arg)
+		}
+		data = append(data, byte(value))
+	}
+	return data, nil
+}
+
+// Parse hexadecimal number, '$' and '0x' prefixes are optional, '+' prefix means decimal
+func parseHex(text string) (uint64, error) {
+	text = strings.ToLower(text)
+	switch {
+	case strings.HasPrefix(text, "+"):
+		return strconv.ParseUint(text[1:], 10, 16)
+	case strings.HasPrefix(text, "$"):
+		text = text[1:]
+	case strings.HasPrefix(text, "0x"):
+		text = text[2:]
+	}
+	return strconv.ParseUint(text, 16, 16)
+}
+
+// Breakpoint addresses in ascending order
+func (m *Monitor) sortedBreakpoints() []uint16 {
+	addresses := make([]uint16, 0, len(m.breakpoints))
+	for address := range m.breakpoints {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return addresses[i] < addresses[j]
+	})
+	return addresses
+}
@@ -0,0 +1,133 @@
+package symbols
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Symbol file formats
+type Format int
+
+const (
+	FormatKickAssembler Format = iota // KickAssembler .sym
+	FormatVICE                        // VICE label file / KickAssembler .vs
+	FormatACME                        // ACME label dump
+)
+
+var (
+	kickAssemblerSymbolRegexp    = regexp.MustCompile(`^\.(label|const|var)\s+([A-Za-z_@][\w@]*)\s*=\s*(\$[0-9a-fA-F]+|0x[0-9a-fA-F]+|%[01]+|\d+)`)
+	kickAssemblerNamespaceRegexp = regexp.MustCompile(`^\.namespace\s+([A-Za-z_][\w]*)\s*\{`)
+	viceLabelRegexp              = regexp.MustCompile(`^al\s+(?:[A-Za-z0-9]+:)?([0-9a-fA-F]{1,4})\s+\.?(\S+)`)
+	acmeLabelRegexp              = regexp.MustCompile(`^(?:!addr\s+)?([A-Za-z_.@][\w.@]*)\s*=\s*(\$[0-9a-fA-F]+|0x[0-9a-fA-F]+|%[01]+|\d+)`)
+)
+
+// Detect symbol file format from contents
+func detectFormat(contents string) Format {
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, ".label"), strings.HasPrefix(line, ".const"), strings.HasPrefix(line, ".namespace"):
+			return FormatKickAssembler
+		case strings.HasPrefix(line, "al "):
+			return FormatVICE
+		case strings.HasPrefix(line, ";"), strings.HasPrefix(line, "//"):
+			continue
+		default:
+			return FormatACME
+		}
+	}
+	return FormatACME
+}
+
+// Parse KickAssembler symbol file (generated with -symbolfile)
+func ParseKickAssembler(contents string) (*SymbolTable, error) {
+	st := NewSymbolTable()
+	namespaces := []string{}
+
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+
+		if match := kickAssemblerNamespaceRegexp.FindStringSubmatch(line); match != nil {
+			namespaces = append(namespaces, match[1])
+			continue
+		}
+		if line == "}" {
+			if len(namespaces) == 0 {
+				return nil, fmt.Errorf("line %d: unexpected '}'", lineNumber)
+			}
+			namespaces = namespaces[:len(namespaces)-1]
+			continue
+		}
+
+		match := kickAssemblerSymbolRegexp.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		address, err := ParseNumber(match[3])
+		if err != nil {
+			// constants don't have to be 16-bit addresses
+			continue
+		}
+		name := strings.Join(append(append([]string{}, namespaces...), match[2]), ".")
+		st.Add(name, address)
+	}
+
+	return st, scanner.Err()
+}
+
+// Parse VICE label file ('al C:0810 .main'), also used by KickAssembler .vs files
+func ParseVICE(contents string) (*SymbolTable, error) {
+	st := NewSymbolTable()
+
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+
+		match := viceLabelRegexp.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		address, err := ParseNumber("$" + match[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid address '%s'", lineNumber, match[1])
+		}
+		st.Add(match[2], address)
+	}
+
+	return st, scanner.Err()
+}
+
+// Parse ACME label dump (generated with --labeldump / -l)
+func ParseACME(contents string) (*SymbolTable, error) {
+	st := NewSymbolTable()
+
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+
+		match := acmeLabelRegexp.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		address, err := ParseNumber(match[2])
+		if err != nil {
+			// constants don't have to be 16-bit addresses
+			continue
+		}
+		st.Add(match[1], address)
+	}
+
+	return st, scanner.Err()
+}
@@ -0,0 +1,250 @@
+// # Symbol tables
+//
+// This package loads symbol (label) files produced by 6502 assemblers and
+// allows to address memory by name instead of raw addresses.
+//
+// Supported formats:
+//   - KickAssembler symbol file (.sym) - '.label main=$0810'
+//   - VICE label file, also produced by KickAssembler as .vs - 'al C:0810 .main'
+//   - ACME label dump - 'main = $0810 ; ?'
+package symbols
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Maximum distance from a label used when annotating addresses
+const MaxAnnotationOffset = 0xff
+
+// Single symbol
+type Symbol struct {
+	Name    string
+	Address uint16
+}
+
+// Symbol table with lookups by name and by address, safe for concurrent use
+type SymbolTable struct {
+	mutex     sync.RWMutex
+	byName    map[string]uint16
+	byAddress map[uint16][]string
+	sorted    []Symbol // sorted by address, rebuilt lazily
+}
+
+// Create an empty symbol table
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{
+		byName:    map[string]uint16{},
+		byAddress: map[uint16][]string{},
+	}
+}
+
+// Load symbol file, the format is detected from its contents
+func LoadFile(path string) (*SymbolTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(string(data))
+}
+
+// Parse symbols, the format is detected from the contents
+func Parse(contents string) (*SymbolTable, error) {
+	switch detectFormat(contents) {
+	case FormatKickAssembler:
+		return ParseKickAssembler(contents)
+	case FormatVICE:
+		return ParseVICE(contents)
+	default:
+		return ParseACME(contents)
+	}
+}
+
+// Add symbol, redefinition replaces previous address
+func (st *SymbolTable) Add(name string, address uint16) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	if previous, exist := st.byName[name]; exist {
+		st.removeFromAddress(previous, name)
+	}
+	st.byName[name] = address
+	st.byAddress[address] = append(st.byAddress[address], name)
+	st.sorted = nil
+}
+
+func (st *SymbolTable) removeFromAddress(address uint16, name string) {
+	names := st.byAddress[address]
+	for idx, existing := range names {
+		if existing == name {
+			st.byAddress[address] = append(names[:idx], names[idx+1:]...)
+			break
+		}
+	}
+	if len(st.byAddress[address]) == 0 {
+		delete(st.byAddress, address)
+	}
+}
+
+// Add all symbols from other table
+func (st *SymbolTable) Merge(other *SymbolTable) {
+	for _, symbol := range other.Symbols() {
+		st.Add(symbol.Name, symbol.Address)
+	}
+}
+
+// Number of symbols
+func (st *SymbolTable) Len() int {
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+	return len(st.byName)
+}
+
+// Find address of the symbol
+func (st *SymbolTable) Lookup(name string) (uint16, bool) {
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+	address, exist := st.byName[name]
+	return address, exist
+}
+
+// All symbols sorted by address and name
+func (st *SymbolTable) Symbols() []Symbol {
+	st.mutex.RLock()
+	sorted := st.sorted
+	st.mutex.RUnlock()
+	if sorted != nil {
+		return sorted
+	}
+
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	if st.sorted == nil {
+		st.sorted = make([]Symbol, 0, len(st.byName))
+		for name, address := range st.byName {
+			st.sorted = append(st.sorted, Symbol{Name: name, Address: address})
+		}
+		sort.Slice(st.sorted, func(i, j int) bool {
+			if st.sorted[i].Address == st.sorted[j].Address {
+				return st.sorted[i].Name < st.sorted[j].Name
+			}
+			return st.sorted[i].Address < st.sorted[j].Address
+		})
+	}
+	return st.sorted
+}
+
+// Find symbol name placed exactly at the address
+func (st *SymbolTable) NameAt(address uint16) (string, bool) {
+	if st == nil {
+		return "", false
+	}
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+	names := st.byAddress[address]
+	if len(names) == 0 {
+		return "", false
+	}
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+	return sorted[0], true
+}
+
+// Find the nearest symbol placed at or below the address
+func (st *SymbolTable) Nearest(address uint16) (Symbol, uint16, bool) {
+	symbols := st.Symbols()
+	idx := sort.Search(len(symbols), func(i int) bool {
+		return symbols[i].Address > address
+	})
+	if idx == 0 {
+		return Symbol{}, 0, false
+	}
+	symbol := symbols[idx-1]
+	// prefer the alphabetically first name at the same address, like NameAt
+	for idx > 1 && symbols[idx-2].Address == symbol.Address {
+		idx--
+		symbol = symbols[idx-1]
+	}
+	return symbol, address - symbol.Address, true
+}
+
+// Annotate address with a symbol, e.g. 'main' or 'main+3',
+// returns empty string if there is no symbol nearby
+func (st *SymbolTable) Annotate(address uint16) string {
+	if st == nil {
+		return ""
+	}
+	symbol, offset, exist := st.Nearest(address)
+	if !exist || offset > MaxAnnotationOffset {
+		return ""
+	}
+	if offset == 0 {
+		return symbol.Name
+	}
+	return fmt.Sprintf("%s+%d", symbol.Name, offset)
+}
+
+// Resolve symbol name or number into address.
+// Accepts: 'main', 'main+3', 'main-1', '$0810', '0x0810' and '2064'
+func (st *SymbolTable) Resolve(expression string) (uint16, error) {
+	expression = strings.TrimSpace(expression)
+	if expression == "" {
+		return 0, fmt.Errorf("empty address")
+	}
+
+	base, offset := expression, 0
+	if idx := strings.LastIndexAny(expression, "+-"); idx > 0 {
+		value, err := ParseNumber(expression[idx+1:])
+		if err != nil {
+			return 0, fmt.Errorf("invalid offset in '%s'", expression)
+		}
+		base = strings.TrimSpace(expression[:idx])
+		offset = int(value)
+		if expression[idx] == '-' {
+			offset = -offset
+		}
+	}
+
+	var address int
+	if value, err := ParseNumber(base); err == nil {
+		address = int(value)
+	} else if st != nil {
+		symbolAddress, exist := st.Lookup(base)
+		if !exist {
+			return 0, fmt.Errorf("unknown symbol '%s'", base)
+		}
+		address = int(symbolAddress)
+	} else {
+		return 0, fmt.Errorf("unknown symbol '%s'", base)
+	}
+
+	address += offset
+	if address < 0 || address > 0xffff {
+		return 0, fmt.Errorf("address of '%s' out of range", expression)
+	}
+
+	return uint16(address), nil
+}
+
+// Parse number in one of the formats: $0810, 0x0810, %0101 or 2064
+func ParseNumber(text string) (uint16, error) {
+	text = strings.TrimSpace(text)
+	base := 10
+	switch {
+	case strings.HasPrefix(text, "$"):
+		text, base = text[1:], 16
+	case strings.HasPrefix(text, "0x"), strings.HasPrefix(text, "0X"):
+		text, base = text[2:], 16
+	case strings.HasPrefix(text, "%"):
+		text, base = text[1:], 2
+	}
+	value, err := strconv.ParseUint(text, base, 16)
+	if err != nil {
+		return 0, err
+	}
+	return uint16(value), nil
+}
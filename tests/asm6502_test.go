@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mojzesh/c64d-ws-client/asm6502"
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"gotest.tools/assert"
+)
+
+func TestAssembleInfiniteLoop(t *testing.T) {
+	program, err := asm6502.Assemble(0x0815, `
+		sei
+	loop:
+		jmp loop
+	`)
+	assert.NilError(t, err)
+	assert.Equal(t, program.Origin, uint16(0x0815))
+	assert.DeepEqual(t, program.Code, []byte{0x78, 0x4c, 0x16, 0x08})
+	assert.Equal(t, program.Labels["loop"], uint16(0x0816))
+}
+
+func TestAssembleAddressingModes(t *testing.T) {
+	type testCase struct {
+		src      string
+		expected []byte
+	}
+
+	testCases := []testCase{
+		{src: "nop", expected: []byte{0xea}},
+		{src: "asl", expected: []byte{0x0a}},
+		{src: "lsr a", expected: []byte{0x4a}},
+		{src: "lda #$10", expected: []byte{0xa9, 0x10}},
+		{src: "lda #'A'", expected: []byte{0xa9, 0x41}},
+		{src: "lda $fb", expected: []byte{0xa5, 0xfb}},
+		{src: "lda $fb,x", expected: []byte{0xb5, 0xfb}},
+		{src: "ldx $fb,y", expected: []byte{0xb6, 0xfb}},
+		{src: "lda $fb,y", expected: []byte{0xb9, 0xfb, 0x00}},
+		{src: "lda $d020", expected: []byte{0xad, 0x20, 0xd0}},
+		{src: "sta $0400,x", expected: []byte{0x9d, 0x00, 0x04}},
+		{src: "sta $0400 , Y", expected: []byte{0x99, 0x00, 0x04}},
+		{src: "lda ($fb,x)", expected: []byte{0xa1, 0xfb}},
+		{src: "sta ($fb),y", expected: []byte{0x91, 0xfb}},
+		{src: "jmp ($fffe)", expected: []byte{0x6c, 0xfe, 0xff}},
+		{src: "lda ($10+2)*2", expected: []byte{0xa5, 0x24}},
+		{src: "LDA #%1010", expected: []byte{0xa9, 0x0a}},
+		{src: "ldy #0x20", expected: []byte{0xa0, 0x20}},
+	}
+
+	for _, testCase := range testCases {
+		program, err := asm6502.Assemble(0x1000, testCase.src)
+		assert.NilError(t, err, testCase.src)
+		assert.DeepEqual(t, program.Code, testCase.expected)
+	}
+}
+
+func TestAssembleExpressionsAndDirectives(t *testing.T) {
+	program, err := asm6502.Assemble(0xc000, `
+	border = $d020
+	screen = $0400
+
+	main:
+		lda #<message       ; low byte
+		ldx #>message       ; high byte
+		ldy #(end-message)
+	@loop:
+		dey
+		bne @loop
+		inc border
+		rts
+	other:
+	@loop:                  ; same local name in another scope
+		beq @loop
+		jmp main
+	message:
+		.byte "HI", 0, -1
+		.word screen, *+2
+	end:
+	`)
+	assert.NilError(t, err)
+
+	expected := []byte{
+		0xa9, 0x12, // lda #<message
+		0xa2, 0xc0, // ldx #>message
+		0xa0, 0x08, // ldy #8
+		0x88,       // dey
+		0xd0, 0xfd, // bne @loop
+		0xee, 0x20, 0xd0, // inc $d020
+		0x60,       // rts
+		0xf0, 0xfe, // beq @loop
+		0x4c, 0x00, 0xc0, // jmp main
+		'H', 'I', 0x00, 0xff, // .byte
+		0x00, 0x04, 0x1a, 0xc0, // .word
+	}
+	assert.DeepEqual(t, program.Code, expected)
+	assert.Equal(t, program.Labels["main@loop"], uint16(0xc006))
+	assert.Equal(t, program.Labels["other@loop"], uint16(0xc00d))
+	assert.Equal(t, program.Labels["end"], uint16(0xc01a))
+}
+
+func TestAssembleForwardReferenceUsesAbsoluteMode(t *testing.T) {
+	program, err := asm6502.Assemble(0x1000, `
+		lda value
+		rts
+	value = $fb
+	`)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, program.Code, []byte{0xad, 0xfb, 0x00, 0x60})
+}
+
+func TestAssembleSegments(t *testing.T) {
+	program, err := asm6502.Assemble(0x1000, `
+		jmp main
+	* = $1000 + 8
+	main:	rts
+	.org $1010
+	`)
+	assert.NilError(t, err)
+	assert.Equal(t, len(program.Code), 0x10)
+	assert.DeepEqual(t, program.Segments, []asm6502.Segment{
+		{Origin: 0x1000, Code: []byte{0x4c, 0x08, 0x10}},
+		{Origin: 0x1008, Code: []byte{0x60}},
+	})
+}
+
+func TestAssembleAndWriteSegments(t *testing.T) {
+	client, fake := newFakeServerClient(t)
+	fake.writeRAM(0x1000, bytes.Repeat([]byte{0xaa}, 0x10))
+
+	// -------------------------------------------------------------
+	// test: memory between segments is left unchanged
+	// -------------------------------------------------------------
+	err := client.AssembleAndWrite(0x1000, "jmp main\n* = $1008\nmain: rts")
+	assert.NilError(t, err)
+	data, err := client.ReadMemory(c64dws.MemorySpaceRAM, 0x1000, 10)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, data, []byte{0x4c, 0x08, 0x10, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0x60, 0xaa})
+}
+
+func TestAssembleErrors(t *testing.T) {
+	errorSources := []string{
+		"lda",
+		"xyz #1",
+		"lda #$100",
+		"jmp nowhere",
+		"label:\nlabel:",
+		"bne far\n* = $1100\nfar:",
+		".byte \"unterminated",
+		"lda ($20)",
+		"lda ($20),x",
+		"sta ($fb)",
+		"ldx ($20),y",
+	}
+
+	for _, src := range errorSources {
+		_, err := asm6502.Assemble(0x1000, src)
+		assert.Check(t, err != nil, src)
+	}
+}
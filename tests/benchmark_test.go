@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"gotest.tools/assert"
+)
+
+const benchmarkProgram = `
+	* = $0810
+caller:	jsr routine
+done:	jmp done
+
+routine:
+	lda #$01	; 2 cycles
+	sta $d020	; 4 cycles
+	rts		; 6 cycles
+`
+
+func TestMeasureRoutine(t *testing.T) {
+	client, fake := newFakeServerClient(t)
+
+	err := client.AssembleAndWrite(0x0810, benchmarkProgram)
+	assert.NilError(t, err)
+
+	// -------------------------------------------------------------
+	// test: JSR (6) + routine (12) cycles
+	// -------------------------------------------------------------
+	stats, err := client.MeasureRoutine(0x0810, 0x0813, 3)
+	assert.NilError(t, err)
+	assert.Equal(t, stats.Runs, 3)
+	assert.DeepEqual(t, stats.Cycles, []uint64{18, 18, 18})
+	assert.Equal(t, stats.Min, uint64(18))
+	assert.Equal(t, stats.Max, uint64(18))
+	assert.Equal(t, stats.StdDev, 0.0)
+
+	// breakpoint is removed afterwards
+	assert.Equal(t, fake.fnCount("cpu/breakpoint/add"), 1)
+	assert.Equal(t, fake.fnCount("cpu/breakpoint/remove"), 1)
+	assert.Equal(t, fake.fnCount("cpu/makejmp"), 3)
+}
+
+func TestRoutineStats(t *testing.T) {
+	stats := c64dws.NewRoutineStats([]uint64{10, 14, 12, 20})
+	assert.Equal(t, stats.Runs, 4)
+	assert.Equal(t, stats.Min, uint64(10))
+	assert.Equal(t, stats.Max, uint64(20))
+	assert.Equal(t, stats.Mean, 14.0)
+	assert.Equal(t, stats.Median, 13.0)
+	assert.Equal(t, stats.String(), "runs: 4, min: 10, max: 20, mean: 14.00, median: 13.00, stddev: 3.74")
+
+	stats = c64dws.NewRoutineStats([]uint64{7, 3, 5})
+	assert.Equal(t, stats.Median, 5.0)
+
+	stats = c64dws.NewRoutineStats(nil)
+	assert.Equal(t, stats.Runs, 0)
+}
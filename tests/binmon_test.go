@@ -0,0 +1,195 @@
+package tests
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mojzesh/c64d-ws-client/binmon"
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"gotest.tools/assert"
+)
+
+// Binary monitor connection driven through a pipe
+type binmonSession struct {
+	t         *testing.T
+	conn      net.Conn
+	requestID uint32
+	responses chan binmon.Response
+}
+
+func newBinmonSession(t *testing.T) (*binmonSession, *fakeServer) {
+	client, fake := newFakeServerClient(t)
+	server := binmon.NewServer(client)
+
+	serverConn, clientConn := net.Pipe()
+	go server.ServeConn(serverConn)
+	t.Cleanup(func() { clientConn.Close() })
+
+	session := &binmonSession{t: t, conn: clientConn, responses: make(chan binmon.Response, 100)}
+	go func() {
+		defer close(session.responses)
+		for {
+			response, err := binmon.ReadResponse(clientConn)
+			if err != nil {
+				return
+			}
+			session.responses <- response
+		}
+	}()
+	return session, fake
+}
+
+// Send command and wait for its response, events received in the meantime are skipped
+func (s *binmonSession) command(commandType byte, body []byte) binmon.Response {
+	s.t.Helper()
+	s.requestID++
+	err := binmon.WriteCommand(s.conn, binmon.Command{RequestID: s.requestID, Type: commandType, Body: body})
+	assert.NilError(s.t, err)
+
+	for {
+		response := s.next()
+		if response.RequestID == s.requestID {
+			return response
+		}
+	}
+}
+
+// Wait for event, other responses and events are skipped
+func (s *binmonSession) event(responseType byte) binmon.Response {
+	s.t.Helper()
+	for {
+		response := s.next()
+		if response.RequestID == binmon.EventRequestID && response.Type == responseType {
+			return response
+		}
+	}
+}
+
+func (s *binmonSession) next() binmon.Response {
+	s.t.Helper()
+	select {
+	case response, ok := <-s.responses:
+		assert.Assert(s.t, ok, "connection closed")
+		return response
+	case <-time.After(5 * time.Second):
+		s.t.Fatal("timeout waiting for response")
+		return binmon.Response{}
+	}
+}
+
+// Memory get/set body: side effects, start, end, memspace, bank
+func memoryCommandBody(start uint16, end uint16, data ...byte) []byte {
+	body := []byte{0}
+	body = binary.LittleEndian.AppendUint16(body, start)
+	body = binary.LittleEndian.AppendUint16(body, end)
+	body = append(body, binmon.MemspaceMain, 0, 0)
+	return append(body, data...)
+}
+
+// Value of the register in registers response body
+func registerValue(body []byte, id byte) uint16 {
+	count := int(binary.LittleEndian.Uint16(body))
+	for item, offset := 0, 2; item < count; item, offset = item+1, offset+1+int(body[offset]) {
+		if body[offset+1] == id {
+			return binary.LittleEndian.Uint16(body[offset+2:])
+		}
+	}
+	return 0xffff
+}
+
+func TestBinaryMonitorMemoryAndRegisters(t *testing.T) {
+	session, fake := newBinmonSession(t)
+
+	// -------------------------------------------------------------
+	// test: memory set and get
+	// -------------------------------------------------------------
+	response := session.command(binmon.CommandMemorySet, memoryCommandBody(0x1000, 0x1003, 0xa9, 0x05, 0xa2, 0x07))
+	assert.Equal(t, response.Error, binmon.ErrorOK)
+	assert.DeepEqual(t, fake.readRAM(0x1000, 4), []byte{0xa9, 0x05, 0xa2, 0x07})
+
+	response = session.command(binmon.CommandMemoryGet, memoryCommandBody(0x1001, 0x1002))
+	assert.Equal(t, response.Error, binmon.ErrorOK)
+	assert.DeepEqual(t, response.Body, []byte{0x02, 0x00, 0x05, 0xa2})
+
+	response = session.command(binmon.CommandMemoryGet, memoryCommandBody(0x1002, 0x1001))
+	assert.Equal(t, response.Error, binmon.ErrorInvalidParameter)
+
+	// -------------------------------------------------------------
+	// test: registers set via trampoline
+	// -------------------------------------------------------------
+	fake.writeRAM(c64dws.DefaultTrampolineAddress, []byte{0xaa, 0xbb})
+	body := []byte{binmon.MemspaceMain, 3, 0}
+	for _, register := range [][2]uint16{{uint16(binmon.RegisterA), 0x42}, {uint16(binmon.RegisterPC), 0x1000}, {uint16(binmon.RegisterSP), 0xf0}} {
+		body = append(body, 3, byte(register[0]))
+		body = binary.LittleEndian.AppendUint16(body, register[1])
+	}
+	response = session.command(binmon.CommandRegistersSet, body)
+	assert.Equal(t, response.Error, binmon.ErrorOK)
+	assert.Equal(t, response.Type, binmon.CommandRegistersGet)
+	assert.Equal(t, registerValue(response.Body, binmon.RegisterA), uint16(0x42))
+	assert.Equal(t, registerValue(response.Body, binmon.RegisterPC), uint16(0x1000))
+	assert.Equal(t, registerValue(response.Body, binmon.RegisterSP), uint16(0xf0))
+	assert.Equal(t, registerValue(response.Body, binmon.Register01), uint16(0x37))
+	assert.DeepEqual(t, fake.readRAM(c64dws.DefaultTrampolineAddress, 2), []byte{0xaa, 0xbb})
+
+	// -------------------------------------------------------------
+	// test: unknown command
+	// -------------------------------------------------------------
+	response = session.command(0x42, nil)
+	assert.Equal(t, response.Error, binmon.ErrorInvalidCommand)
+
+	// -------------------------------------------------------------
+	// test: oversized command is rejected without reading its body
+	// -------------------------------------------------------------
+	header := []byte{0x02, binmon.APIVersion, 0xff, 0xff, 0xff, 0xff, 0x07, 0x00, 0x00, 0x00, binmon.CommandMemorySet}
+	_, err := session.conn.Write(header)
+	assert.NilError(t, err)
+	response = session.next()
+	assert.Equal(t, response.RequestID, uint32(7))
+	assert.Equal(t, response.Error, binmon.ErrorInvalidLength)
+}
+
+func TestBinaryMonitorCheckpoints(t *testing.T) {
+	session, fake := newBinmonSession(t)
+	fake.writeRAM(0x1000, []byte{0xa9, 0x05, 0xa2, 0x07, 0xe8, 0x4c, 0x04, 0x10})
+	fake.setCPU(c64dws.CPUState{PC: 0x1000, SP: 0xff})
+
+	// -------------------------------------------------------------
+	// test: exec checkpoint is hit after exit
+	// -------------------------------------------------------------
+	response := session.command(binmon.CommandCheckpointSet, []byte{0x05, 0x10, 0x05, 0x10, 1, 1, binmon.OperationExec, 0})
+	assert.Equal(t, response.Error, binmon.ErrorOK)
+	number := binary.LittleEndian.Uint32(response.Body)
+	assert.Equal(t, fake.fnCount("cpu/breakpoint/add"), 1)
+
+	session.command(binmon.CommandExit, nil)
+	hit := session.event(binmon.CommandCheckpointGet)
+	assert.Equal(t, binary.LittleEndian.Uint32(hit.Body), number)
+	assert.Equal(t, hit.Body[4], byte(1))
+	stopped := session.event(binmon.ResponseStopped)
+	assert.DeepEqual(t, stopped.Body, []byte{0x05, 0x10})
+
+	// -------------------------------------------------------------
+	// test: advance instructions
+	// -------------------------------------------------------------
+	session.command(binmon.CommandAdvanceInstructions, []byte{0, 1, 0})
+	stopped = session.event(binmon.ResponseStopped)
+	assert.DeepEqual(t, stopped.Body, []byte{0x04, 0x10})
+
+	// -------------------------------------------------------------
+	// test: list and delete checkpoints
+	// -------------------------------------------------------------
+	response = session.command(binmon.CommandCheckpointList, nil)
+	assert.Equal(t, response.Type, binmon.CommandCheckpointGet)
+	assert.Equal(t, binary.LittleEndian.Uint32(response.Body[17:]), uint32(0)) // ignore count
+	assert.Equal(t, binary.LittleEndian.Uint32(response.Body[13:]), uint32(1)) // hit count
+
+	deleteBody := binary.LittleEndian.AppendUint32(nil, number)
+	response = session.command(binmon.CommandCheckpointDelete, deleteBody)
+	assert.Equal(t, response.Error, binmon.ErrorOK)
+	assert.Equal(t, fake.fnCount("cpu/breakpoint/remove"), 1)
+	response = session.command(binmon.CommandCheckpointDelete, deleteBody)
+	assert.Equal(t, response.Error, binmon.ErrorObjectMissing)
+}
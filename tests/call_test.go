@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"gotest.tools/assert"
+)
+
+const callProgram = `
+routine:
+	inx	; 2 cycles
+	iny	; 2 cycles
+	txa	; 2 cycles
+	sec	; 2 cycles
+	rts	; 6 cycles
+`
+
+func TestCallSubroutine(t *testing.T) {
+	client, fake := newFakeServerClient(t)
+
+	err := client.AssembleAndWrite(0xc000, callProgram)
+	assert.NilError(t, err)
+	cassetteBuffer := bytes.Repeat([]byte{0xaa}, 17)
+	fake.writeRAM(c64dws.DefaultTrampolineAddress, cassetteBuffer)
+
+	// -------------------------------------------------------------
+	// test: registers are passed and returned
+	// -------------------------------------------------------------
+	result, err := client.CallSubroutine(0xc000, c64dws.Regs{A: 0x01, X: 0x02, Y: 0x03, P: 0x20})
+	assert.NilError(t, err)
+	assert.Equal(t, result.Regs, c64dws.Regs{A: 0x03, X: 0x03, Y: 0x04, P: 0x21})
+	assert.Equal(t, result.SP, uint8(0xff))
+	// JSR (6) + routine (14)
+	assert.Equal(t, result.Cycles, uint64(20))
+
+	// memory under the trampoline is restored
+	assert.DeepEqual(t, fake.readRAM(c64dws.DefaultTrampolineAddress, 17), cassetteBuffer)
+
+	// -------------------------------------------------------------
+	// test: custom trampoline address
+	// -------------------------------------------------------------
+	client.SetTrampolineAddress(0xcf00)
+	result, err = client.CallSubroutine(0xc000, c64dws.Regs{X: 0xff})
+	assert.NilError(t, err)
+	assert.Equal(t, result.Regs.X, uint8(0x00))
+	assert.Equal(t, result.Regs.A, uint8(0x00))
+	assert.Equal(t, result.Regs.String(), "A:00 X:00 Y:01 P:21")
+	assert.DeepEqual(t, fake.readRAM(0xcf00, 17), make([]byte, 17))
+
+	// -------------------------------------------------------------
+	// test: interrupted program continues after the call
+	// -------------------------------------------------------------
+	client.SetTrampolineAddress(0)
+	err = client.AssembleAndWrite(0xc100, "ldx #$42")
+	assert.NilError(t, err)
+	fake.setCPU(c64dws.CPUState{PC: 0xc100, A: 0x11, X: 0x22, Y: 0x33, SP: 0xf0, P: 0x24})
+
+	_, err = client.CallSubroutine(0xc000, c64dws.Regs{X: 0x10})
+	assert.NilError(t, err)
+	state, err := client.ReadCPUStatus()
+	assert.NilError(t, err)
+	assert.Equal(t, state.PC, uint16(0xc100))
+	assert.Equal(t, c64dws.Regs{A: state.A, X: state.X, Y: state.Y, P: state.P}.String(), "A:11 X:22 Y:33 P:24")
+	assert.Equal(t, state.SP, uint8(0xf0))
+	assert.DeepEqual(t, fake.readRAM(c64dws.DefaultTrampolineAddress, 17), cassetteBuffer)
+
+	_, err = client.Request(func(token string) error {
+		return client.StepInstruction(token)
+	})
+	assert.NilError(t, err)
+	state, err = client.ReadCPUStatus()
+	assert.NilError(t, err)
+	assert.Equal(t, state.PC, uint16(0xc102))
+	assert.Equal(t, state.X, uint8(0x42))
+
+	// -------------------------------------------------------------
+	// test: SetRegisters restores I cleared by the trampoline SEI
+	// -------------------------------------------------------------
+	err = client.SetRegisters(c64dws.CPUState{PC: 0xc100, SP: 0xf0, P: 0x20})
+	assert.NilError(t, err)
+	state, err = client.ReadCPUStatus()
+	assert.NilError(t, err)
+	assert.Equal(t, state.PC, uint16(0xc100))
+	assert.Equal(t, state.P, uint8(0x20))
+
+	// -------------------------------------------------------------
+	// test: failed trampoline restore is reported
+	// -------------------------------------------------------------
+	fake.mutex.Lock()
+	writeBlock := fake.handlers["ram/writeBlock"]
+	writes := 0
+	fake.handlers["ram/writeBlock"] = func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+		if writes++; writes == 2 {
+			return 500, map[string]any{"error": "write failed"}, nil
+		}
+		return writeBlock(params, binaryData)
+	}
+	fake.mutex.Unlock()
+	err = client.SetRegisters(c64dws.CPUState{PC: 0xc100, SP: 0xf0, P: 0x20})
+	assert.ErrorContains(t, err, "write failed")
+}
@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"github.com/mojzesh/c64d-ws-client/symbols"
+	"gotest.tools/assert"
+)
+
+const callStackProgram = `
+	* = $0810
+main:	jsr routine
+
+	* = $c000
+routine:
+	nop
+	nop
+	nop
+	nop
+	nop
+	jsr inner
+
+	* = $c100
+inner:	nop
+	nop
+	nop
+	nop
+`
+
+func TestCallStack(t *testing.T) {
+	client, fake := newFakeServerClient(t)
+
+	err := client.AssembleAndWrite(0x0810, callStackProgram)
+	assert.NilError(t, err)
+
+	symbolTable := symbols.NewSymbolTable()
+	symbolTable.Add("main", 0x0810)
+	symbolTable.Add("routine", 0xc000)
+	symbolTable.Add("inner", 0xc100)
+	symbolTable.Add("irq", 0xea31)
+	client.SetSymbols(symbolTable)
+
+	// main: jsr routine, routine: jsr inner, IRQ at inner+3, handler pushed A, X, Y
+	fake.writeRAM(0x01f6, []byte{
+		0x03, 0x02, 0x01, // Y, X, A
+		0x20, 0x03, 0xc1, // flags, interrupted PC ($c103)
+		0x07, 0xc0, // return address - 1 ($c007)
+		0x12, 0x08, // return address - 1 ($0812)
+	})
+	fake.setCPU(c64dws.CPUState{PC: 0xea31, SP: 0xf5})
+
+	frames, err := client.CallStack()
+	assert.NilError(t, err)
+	assert.Equal(t, len(frames), 4)
+
+	assert.Equal(t, frames[0].Kind, c64dws.FrameCurrent)
+	assert.Equal(t, frames[0].String(), "$ea31 irq")
+
+	assert.Equal(t, frames[1].Kind, c64dws.FrameInterrupt)
+	assert.Equal(t, frames[1].Address, uint16(0xc103))
+	assert.Equal(t, frames[1].StackAddress, uint16(0x01f9))
+	assert.Equal(t, frames[1].String(), "$c103 inner+3            interrupt, flags ..-.....")
+
+	assert.Equal(t, frames[2].Kind, c64dws.FrameJSR)
+	assert.Equal(t, frames[2].Address, uint16(0xc005))
+	assert.Equal(t, frames[2].ReturnAddress, uint16(0xc008))
+	assert.Equal(t, frames[2].String(), "$c005 routine+5          jsr $c100 (inner)")
+
+	assert.Equal(t, frames[3].Kind, c64dws.FrameJSR)
+	assert.Equal(t, frames[3].Address, uint16(0x0810))
+	assert.Equal(t, frames[3].Target, uint16(0xc000))
+	assert.Equal(t, frames[3].TargetLabel, "routine")
+}
+
+func TestReconstructCallStackBRK(t *testing.T) {
+	memory := make([]byte, 0x10000)
+	memory[0x1000] = 0x00 // brk
+	memory[0x1001] = 0xff // signature byte
+	memory[0x1002] = 0xea // nop
+	readByte := func(address uint16) (byte, error) {
+		return memory[address], nil
+	}
+
+	stackPage := make([]byte, 0x100)
+	copy(stackPage[0xfd:], []byte{0x30, 0x02, 0x10})
+
+	frames, err := c64dws.ReconstructCallStack(0xfe66, 0xfc, stackPage, readByte, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(frames), 2)
+	assert.Equal(t, frames[1].Kind, c64dws.FrameBRK)
+	assert.Equal(t, frames[1].Address, uint16(0x1000))
+	assert.Equal(t, frames[1].ReturnAddress, uint16(0x1002))
+
+	_, err = c64dws.ReconstructCallStack(0, 0xff, nil, readByte, nil)
+	assert.Error(t, err, "Stack page must have 256 bytes, got 0")
+}
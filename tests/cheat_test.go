@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"gotest.tools/assert"
+)
+
+func TestCheatFinder(t *testing.T) {
+	client, fake := newFakeServerClient(t)
+	// lives at $c010, energy at $c020, noise at $c030
+	fake.writeRAM(0xc010, []byte{3})
+	fake.writeRAM(0xc020, []byte{0x40})
+	fake.writeRAM(0xc030, []byte{3})
+
+	finder, err := client.NewCheatFinder([]c64dws.AddressRange{{Start: 0xc000, End: 0xc0ff}})
+	assert.NilError(t, err)
+	assert.Equal(t, finder.Len(), 0x100)
+
+	// -------------------------------------------------------------
+	// test: narrowing candidates
+	// -------------------------------------------------------------
+	fake.writeRAM(0xc010, []byte{2})
+	fake.writeRAM(0xc020, []byte{0x3c})
+	fake.writeRAM(0xc030, []byte{4})
+	count, err := finder.Filter(c64dws.CheatDecreased, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, count, 2)
+
+	count, err = finder.Filter(c64dws.CheatUnchanged, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, count, 2)
+
+	count, err = finder.Filter(c64dws.CheatEquals, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, count, 1)
+	assert.DeepEqual(t, finder.Candidates(), []c64dws.CheatCandidate{{Address: 0xc010, Value: 2}})
+	assert.Equal(t, finder.Searches(), 3)
+
+	// -------------------------------------------------------------
+	// test: poking results
+	// -------------------------------------------------------------
+	assert.NilError(t, finder.PokeAll(9))
+	assert.DeepEqual(t, fake.readRAM(0xc010, 1), []byte{9})
+	assert.ErrorContains(t, finder.Poke(0xc020, 9), "$c020 is not a cheat candidate")
+
+	condition, err := c64dws.ParseCheatCondition("inc")
+	assert.NilError(t, err)
+	assert.Equal(t, condition, c64dws.CheatIncreased)
+}
+
+func TestMonitorCheatCommand(t *testing.T) {
+	mon, fake, output := newTestMonitor(t)
+	fake.writeRAM(0xc010, []byte{3})
+
+	assert.Equal(t, execute(t, mon, output, "cheat start c000 c0ff"), "256 candidates\n")
+	fake.writeRAM(0xc010, []byte{2})
+	assert.Equal(t, execute(t, mon, output, "cheat dec"), "1 candidates\n:c010  02  .\n")
+	execute(t, mon, output, "cheat poke 09")
+	assert.DeepEqual(t, fake.readRAM(0xc010, 1), []byte{9})
+	assert.Equal(t, execute(t, mon, output, "cheat list"), ":c010  09  .\n")
+}
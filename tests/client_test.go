@@ -157,11 +157,11 @@ func TestCPUMakeJMP(t *testing.T) {
 	assertSuccessfullConnection(t, response, err)
 
 	jmpAddr := uint16(0x0815)
-	infiniteLoopProcedure := []byte{
-		0x78,                                                       // $0815: sei
-		0x4c, byte((jmpAddr + 1) & 0xff), byte((jmpAddr + 1) >> 8), // $0816: jmp $0816
-	}
-	err = client.RAMWriteBlock(jmpAddr, infiniteLoopProcedure)
+	err = client.AssembleAndWrite(jmpAddr, `
+		sei
+	loop:
+		jmp loop
+	`)
 	assert.NilError(t, err)
 	msgType, msg, err := client.ReceiveMessage()
 	assert.NilError(t, err)
@@ -0,0 +1,273 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mojzesh/c64d-ws-client/dap"
+	"gotest.tools/assert"
+)
+
+const dapTestSource = `*=$1000
+main:   jsr sub
+loop:   jmp loop
+        nop
+        nop
+sub:
+        lda #$05
+        rts
+`
+
+// Debug adapter session driven through pipes
+type dapSession struct {
+	t        *testing.T
+	seq      int
+	requests io.Writer
+	messages chan map[string]any
+	events   []string // events received while waiting for a response
+}
+
+func newDAPSession(t *testing.T) *dapSession {
+	requestsReader, requestsWriter := io.Pipe()
+	responsesReader, responsesWriter := io.Pipe()
+	server := dap.NewServer(requestsReader, responsesWriter)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Serve()
+		responsesWriter.Close()
+	}()
+	t.Cleanup(func() {
+		requestsWriter.Close()
+		assert.NilError(t, <-done)
+	})
+
+	session := &dapSession{t: t, requests: requestsWriter, messages: make(chan map[string]any, 100)}
+	go func() {
+		defer close(session.messages)
+		reader := bufio.NewReader(responsesReader)
+		for {
+			content, err := dap.ReadMessage(reader)
+			if err != nil {
+				return
+			}
+			var message map[string]any
+			json.Unmarshal(content, &message)
+			session.messages <- message
+		}
+	}()
+	return session
+}
+
+// Send request and wait for its response, returns response body
+func (s *dapSession) request(command string, arguments any) map[string]any {
+	s.t.Helper()
+	message := s.response(command, arguments)
+	assert.Assert(s.t, message["success"].(bool), "%s: %v", command, message["message"])
+	body, _ := message["body"].(map[string]any)
+	return body
+}
+
+// Send request expected to fail, returns the error message
+func (s *dapSession) failingRequest(command string, arguments any) string {
+	s.t.Helper()
+	message := s.response(command, arguments)
+	assert.Assert(s.t, !message["success"].(bool), "%s succeeded", command)
+	errorMessage, _ := message["message"].(string)
+	return errorMessage
+}
+
+func (s *dapSession) response(command string, arguments any) map[string]any {
+	s.t.Helper()
+	s.seq++
+	err := dap.WriteMessage(s.requests, map[string]any{"seq": s.seq, "type": "request", "command": command, "arguments": arguments})
+	assert.NilError(s.t, err)
+
+	for {
+		message := s.next()
+		if message["type"] == "response" && int(message["request_seq"].(float64)) == s.seq {
+			return message
+		}
+		s.events = append(s.events, eventName(message))
+	}
+}
+
+// Next event, stopped events may arrive before the response of the request which caused them
+func (s *dapSession) event() string {
+	s.t.Helper()
+	if len(s.events) > 0 {
+		event := s.events[0]
+		s.events = s.events[1:]
+		return event
+	}
+	return eventName(s.next())
+}
+
+func (s *dapSession) next() map[string]any {
+	s.t.Helper()
+	select {
+	case message, ok := <-s.messages:
+		assert.Assert(s.t, ok, "adapter closed output")
+		return message
+	case <-time.After(5 * time.Second):
+		s.t.Fatal("timeout waiting for adapter message")
+		return nil
+	}
+}
+
+// Event name with the reason, e.g. 'stopped:entry'
+func eventName(message map[string]any) string {
+	name, _ := message["event"].(string)
+	if body, ok := message["body"].(map[string]any); ok {
+		if reason, ok := body["reason"].(string); ok {
+			name += ":" + reason
+		}
+	}
+	return name
+}
+
+func TestDAPSourceMap(t *testing.T) {
+	sourceMap := dap.NewSourceMap()
+	sourceMap.AddSource("main.asm", dapTestSource)
+
+	for line, expected := range map[int]string{2: "main", 3: "loop", 4: "", 6: "sub", 7: "sub", 8: ""} {
+		label, _ := sourceMap.LabelAt("main.asm", line)
+		assert.Equal(t, label, expected, "line %d", line)
+	}
+
+	location, exist := sourceMap.Location("music.sub")
+	assert.Assert(t, exist)
+	assert.Equal(t, location, dap.SourceLocation{Path: "main.asm", Line: 6})
+}
+
+func TestDAPSession(t *testing.T) {
+	fake, hostName, port := newFakeServer(t)
+
+	dir := t.TempDir()
+	program := filepath.Join(dir, "main.prg")
+	source := filepath.Join(dir, "main.asm")
+	symbolsPath := filepath.Join(dir, "main.sym")
+	assert.NilError(t, os.WriteFile(program, []byte{0x00, 0x10, 0x20, 0x08, 0x10, 0x4c, 0x03, 0x10, 0xea, 0xea, 0xa9, 0x05, 0x60}, 0644))
+	assert.NilError(t, os.WriteFile(source, []byte(dapTestSource), 0644))
+	assert.NilError(t, os.WriteFile(symbolsPath, []byte(".label main=$1000\n.label loop=$1003\n.label sub=$1008\n"), 0644))
+
+	session := newDAPSession(t)
+
+	// -------------------------------------------------------------
+	// test: initialize, launch and stop on entry
+	// -------------------------------------------------------------
+	capabilities := session.request("initialize", map[string]any{"adapterID": "c64dws"})
+	assert.Equal(t, capabilities["supportsConfigurationDoneRequest"], true)
+	assert.Equal(t, session.event(), "initialized")
+
+	session.request("launch", map[string]any{
+		"host": hostName, "port": port, "program": program,
+		"symbols": []string{symbolsPath}, "sources": []string{source}, "stopOnEntry": true,
+	})
+	assert.DeepEqual(t, fake.readRAM(0x1000, 3), []byte{0x20, 0x08, 0x10})
+
+	body := session.request("setBreakpoints", map[string]any{
+		"source":      map[string]any{"path": source},
+		"breakpoints": []map[string]any{{"line": 7}, {"line": 4}},
+	})
+	breakpoints := body["breakpoints"].([]any)
+	assert.Equal(t, breakpoints[0].(map[string]any)["verified"], true)
+	assert.Equal(t, breakpoints[0].(map[string]any)["instructionReference"], "0x1008")
+	assert.Equal(t, breakpoints[1].(map[string]any)["verified"], false)
+
+	session.request("configurationDone", nil)
+	assert.Equal(t, session.event(), "stopped:entry")
+
+	// -------------------------------------------------------------
+	// test: continue to breakpoint and stack trace
+	// -------------------------------------------------------------
+	session.request("continue", map[string]any{"threadId": 1})
+	assert.Equal(t, session.event(), "stopped:breakpoint")
+
+	body = session.request("stackTrace", map[string]any{"threadId": 1})
+	frames := body["stackFrames"].([]any)
+	assert.Equal(t, len(frames), 2)
+	assert.Equal(t, frames[0].(map[string]any)["name"], "sub")
+	assert.Equal(t, frames[0].(map[string]any)["line"], float64(6))
+	assert.Equal(t, frames[1].(map[string]any)["name"], "main")
+	assert.Equal(t, frames[1].(map[string]any)["line"], float64(2))
+
+	// -------------------------------------------------------------
+	// test: step in, registers and step out
+	// -------------------------------------------------------------
+	session.request("stepIn", map[string]any{"threadId": 1})
+	assert.Equal(t, session.event(), "stopped:step")
+
+	body = session.request("evaluate", map[string]any{"expression": "a"})
+	assert.Equal(t, body["result"], "$05 (5)")
+	body = session.request("evaluate", map[string]any{"expression": "sub"})
+	assert.Equal(t, body["result"], "$1008 (sub): $a9, word $05a9")
+
+	session.request("stepOut", map[string]any{"threadId": 1})
+	assert.Equal(t, session.event(), "stopped:step")
+	body = session.request("variables", map[string]any{"variablesReference": 1})
+	assert.Equal(t, body["variables"].([]any)[0].(map[string]any)["value"], "$1003 (loop)")
+
+	// -------------------------------------------------------------
+	// test: chip registers and memory
+	// -------------------------------------------------------------
+	fake.mutex.Lock()
+	fake.vic[0x20] = 0x0e
+	fake.mutex.Unlock()
+	body = session.request("variables", map[string]any{"variablesReference": 2})
+	border := body["variables"].([]any)[0x20].(map[string]any)
+	assert.Equal(t, border["name"], "$d020")
+	assert.Equal(t, border["value"], "$0e")
+
+	body = session.request("readMemory", map[string]any{"memoryReference": "0x1000", "offset": 8, "count": 3})
+	assert.Equal(t, body["data"], "qQVg")
+	session.request("writeMemory", map[string]any{"memoryReference": "sub", "offset": 1, "data": "Bw=="})
+	assert.DeepEqual(t, fake.readRAM(0x1009, 1), []byte{0x07})
+
+	// -------------------------------------------------------------
+	// test: disconnect removes breakpoints
+	// -------------------------------------------------------------
+	session.request("disconnect", map[string]any{})
+	assert.Equal(t, fake.fnCount("cpu/breakpoint/add"), fake.fnCount("cpu/breakpoint/remove"))
+}
+
+func TestDAPLaunchBasicStub(t *testing.T) {
+	fake, hostName, port := newFakeServer(t)
+	dir := t.TempDir()
+
+	// -------------------------------------------------------------
+	// test: programs at $0801 start at the SYS address of the stub
+	// -------------------------------------------------------------
+	// 10 SYS 2064, JMP $0810 at $0810
+	program := filepath.Join(dir, "basic.prg")
+	prg := []byte{0x01, 0x08, 0x0b, 0x08, 0x0a, 0x00, 0x9e, '2', '0', '6', '4', 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x4c, 0x10, 0x08}
+	assert.NilError(t, os.WriteFile(program, prg, 0644))
+
+	session := newDAPSession(t)
+	session.request("launch", map[string]any{"host": hostName, "port": port, "program": program, "stopOnEntry": true})
+	fake.mutex.Lock()
+	assert.Equal(t, fake.cpu.PC, uint16(0x0810))
+	fake.mutex.Unlock()
+	session.request("disconnect", map[string]any{})
+
+	// -------------------------------------------------------------
+	// test: entry is required without SYS in the stub
+	// -------------------------------------------------------------
+	// 10 REM
+	noSYS := filepath.Join(dir, "rem.prg")
+	assert.NilError(t, os.WriteFile(noSYS, []byte{0x01, 0x08, 0x07, 0x08, 0x0a, 0x00, 0x8f, 0x00, 0x00, 0x00}, 0644))
+
+	session = newDAPSession(t)
+	message := session.failingRequest("launch", map[string]any{"host": hostName, "port": port, "program": noSYS})
+	assert.Assert(t, strings.Contains(message, "set 'entry'"), message)
+	session.request("launch", map[string]any{"host": hostName, "port": port, "program": noSYS, "entry": "$0807", "stopOnEntry": true})
+	fake.mutex.Lock()
+	assert.Equal(t, fake.cpu.PC, uint16(0x0807))
+	fake.mutex.Unlock()
+}
@@ -0,0 +1,581 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/mojzesh/c64d-ws-client/asm6502"
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"gotest.tools/assert"
+)
+
+// ----------------------------------------------------------------------
+// Fake Retro Debugger server
+//
+// Implements a small subset of the WebSocket API on top of an in-memory
+// machine model, so sync mode helpers can be tested without the emulator.
+// Stepping advances PC (JMP, JSR and RTS are followed) and executes only a few
+// register instructions. Continue steps until a CPU breakpoint is reached.
+// CPU memory reads see the ROMs banked in by $01.
+// ----------------------------------------------------------------------
+type fakeServer struct {
+	mutex         sync.Mutex
+	server        *httptest.Server
+	conn          *websocket.Conn
+	ram           [0x10000]byte
+	drive1541RAM  [0x0800]byte
+	basicROM      [0x2000]byte
+	charROM       [0x1000]byte
+	kernalROM     [0x2000]byte
+	vic           [0x2f]byte
+	cia           [2][0x10]byte
+	sid           [0x1d]byte
+	via           [2][0x10]byte
+	cpu           c64dws.CPUState
+	counters      c64dws.CycleCounters
+	breakpoints   map[uint16]bool
+	memoryBPs     map[uint16]string // address -> access
+	rasterBPs     map[uint8]bool
+	segments      map[string]c64dws.AddressRange // debug symbols segments in RAM
+	requestedFns  []string
+	pendingEvents []any // events sent after the response
+	handlers      map[string]fakeHandler
+	writeMutex    sync.Mutex
+	unknownStatus int // status returned for unknown functions
+}
+
+// Request handler, returns status, result and binary data
+type fakeHandler func(params map[string]any, binaryData []byte) (int, map[string]any, []byte)
+
+type fakeRequest struct {
+	Fn     string         `json:"fn"`
+	Params map[string]any `json:"params"`
+	Token  string         `json:"token"`
+}
+
+// ----------------------------------------------------------------------
+// Start fake server
+// ----------------------------------------------------------------------
+func newFakeServer(t *testing.T) (fake *fakeServer, hostName string, port int) {
+	fake = &fakeServer{
+		breakpoints: map[uint16]bool{},
+		memoryBPs:   map[uint16]string{},
+		rasterBPs:   map[uint8]bool{},
+		segments: map[string]c64dws.AddressRange{
+			"Default": {Start: 0x0801, End: 0x080d},
+			"Code":    {Start: 0x1000, End: 0x10ff},
+		},
+		unknownStatus: 404,
+	}
+	fake.cpu.SP = 0xff
+	fake.cpu.P = c64dws.FlagUnused | c64dws.FlagInterrupt
+	fake.ram[0x01] = 0x37
+	for idx := range fake.kernalROM {
+		fake.basicROM[idx] = 0xba
+		fake.kernalROM[idx] = 0xee
+	}
+	for idx := range fake.charROM {
+		fake.charROM[idx] = 0xc4
+	}
+	fake.registerHandlers()
+	fake.server = httptest.NewServer(http.HandlerFunc(fake.serveWebSocket))
+	t.Cleanup(fake.server.Close)
+
+	serverURL, err := url.Parse(fake.server.URL)
+	assert.NilError(t, err)
+	port, err = strconv.Atoi(serverURL.Port())
+	assert.NilError(t, err)
+
+	return fake, serverURL.Hostname(), port
+}
+
+// ----------------------------------------------------------------------
+// Start fake server and connect a client to it
+// ----------------------------------------------------------------------
+func newFakeServerClient(t *testing.T) (*c64dws.Client, *fakeServer) {
+	fake, hostName, port := newFakeServer(t)
+	host, err := c64dws.GetCustomHost(hostName, port, "ws")
+	assert.NilError(t, err)
+
+	client := c64dws.NewCustomClient(c64dws.EmulatorC64, c64dws.StreamAPI, c64dws.TokenTypeAutoIncrement, c64dws.WS_DEFAULT_TOKEN_FORMAT, host)
+	response, err := client.Connect()
+	assertSuccessfullConnection(t, response, err)
+	t.Cleanup(client.Close)
+
+	return client, fake
+}
+
+func (fake *fakeServer) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	fake.mutex.Lock()
+	fake.conn = conn
+	fake.mutex.Unlock()
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		textPart, binaryPart := message, []byte(nil)
+		if idx := bytes.IndexByte(message, 0); idx != -1 {
+			textPart, binaryPart = message[:idx], message[idx+1:]
+		}
+
+		var request fakeRequest
+		if err := json.Unmarshal(textPart, &request); err != nil {
+			return
+		}
+		fake.handleRequest(request, binaryPart)
+	}
+}
+
+func (fake *fakeServer) handleRequest(request fakeRequest, binaryData []byte) {
+	fake.mutex.Lock()
+	fn := strings.TrimPrefix(request.Fn, "c64/")
+	fake.requestedFns = append(fake.requestedFns, fn)
+	handler, exist := fake.handlers[fn]
+	var status int
+	var result map[string]any
+	var data []byte
+	if exist {
+		status, result, data = handler(request.Params, binaryData)
+	} else {
+		status = fake.unknownStatus
+	}
+	pendingEvents := fake.pendingEvents
+	fake.pendingEvents = nil
+	fake.mutex.Unlock()
+
+	response := map[string]any{
+		"status": status,
+		"token":  request.Token,
+	}
+	if status == 200 {
+		response["result"] = result
+	} else if message, ok := result["error"].(string); ok {
+		response["error"] = message
+	} else {
+		response["error"] = "unknown function " + request.Fn
+	}
+	message, _ := json.Marshal(response)
+	message = append(message, 0)
+	message = append(message, data...)
+	fake.send(websocket.BinaryMessage, message)
+
+	for _, event := range pendingEvents {
+		fake.sendEvent(event)
+	}
+}
+
+func (fake *fakeServer) send(messageType int, message []byte) {
+	fake.writeMutex.Lock()
+	defer fake.writeMutex.Unlock()
+	fake.conn.WriteMessage(messageType, message)
+}
+
+// ----------------------------------------------------------------------
+// Send server event (text message)
+// ----------------------------------------------------------------------
+func (fake *fakeServer) sendEvent(event any) {
+	message, _ := json.Marshal(event)
+	fake.send(websocket.TextMessage, message)
+}
+
+// ----------------------------------------------------------------------
+// Access to the fake machine state from tests
+// ----------------------------------------------------------------------
+func (fake *fakeServer) writeRAM(address uint16, data []byte) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	copy(fake.ram[address:], data)
+}
+
+func (fake *fakeServer) readRAM(address uint16, size int) []byte {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	return append([]byte{}, fake.ram[address:int(address)+size]...)
+}
+
+func (fake *fakeServer) setCPU(state c64dws.CPUState) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	fake.cpu = state
+}
+
+func (fake *fakeServer) fnCount(fn string) int {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	count := 0
+	for _, requestedFn := range fake.requestedFns {
+		if requestedFn == fn {
+			count++
+		}
+	}
+	return count
+}
+
+// ----------------------------------------------------------------------
+// Request handlers
+// ----------------------------------------------------------------------
+func (fake *fakeServer) registerHandlers() {
+	ok := func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+		return 200, map[string]any{}, nil
+	}
+
+	fake.handlers = map[string]fakeHandler{
+		"pause": ok,
+		"continue": func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+			fake.runUntilBreakpoint()
+			return 200, map[string]any{}, nil
+		},
+		"warp/set": ok,
+		"ram/readBlock": func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+			address, size := paramInt(params, "address"), paramInt(params, "size")
+			return 200, map[string]any{}, append([]byte{}, fake.ram[address:address+size]...)
+		},
+		"ram/writeBlock": func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+			copy(fake.ram[paramInt(params, "address"):], binaryData)
+			return 200, map[string]any{}, nil
+		},
+		"drive1541/ram/readBlock": func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+			address, size := paramInt(params, "address"), paramInt(params, "size")
+			return 200, map[string]any{}, append([]byte{}, fake.drive1541RAM[address:address+size]...)
+		},
+		"drive1541/ram/writeBlock": func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+			copy(fake.drive1541RAM[paramInt(params, "address"):], binaryData)
+			return 200, map[string]any{}, nil
+		},
+		"cpu/status": func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+			return 200, map[string]any{
+				"pc":               fake.cpu.PC,
+				"a":                fake.cpu.A,
+				"x":                fake.cpu.X,
+				"y":                fake.cpu.Y,
+				"sp":               fake.cpu.SP,
+				"p":                fake.cpu.P,
+				"memory0001":       fake.ram[0x01],
+				"instructionCycle": 0,
+				"rasterCycle":      fake.cpu.RasterCycle,
+				"rasterX":          fake.cpu.RasterX,
+				"rasterY":          fake.cpu.RasterY,
+				"game":             true,
+				"exrom":            true,
+			}, nil
+		},
+		"cpu/counters/read": func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+			return 200, map[string]any{
+				"cycle":       fake.counters.Cycle,
+				"frame":       fake.counters.Frame,
+				"instruction": fake.counters.Instruction,
+			}, nil
+		},
+		"cpu/makejmp": func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+			fake.cpu.PC = uint16(paramInt(params, "address"))
+			return 200, map[string]any{}, nil
+		},
+		"step/instruction": func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+			fake.stepInstruction()
+			return 200, map[string]any{}, nil
+		},
+		// steps over JSR
+		"step/subroutine": func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+			next := fake.cpu.PC + 3
+			isJSR := asm6502.Opcodes[fake.ram[fake.cpu.PC]].Mnemonic == "jsr"
+			fake.stepInstruction()
+			for step := 0; isJSR && fake.cpu.PC != next && step < fakeMaxContinueSteps; step++ {
+				fake.stepInstruction()
+			}
+			return 200, map[string]any{}, nil
+		},
+		"cpu/breakpoint/add": func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+			fake.breakpoints[uint16(paramInt(params, "addr"))] = true
+			return 200, map[string]any{}, nil
+		},
+		"cpu/breakpoint/remove": func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+			delete(fake.breakpoints, uint16(paramInt(params, "addr")))
+			return 200, map[string]any{}, nil
+		},
+		// memory breakpoints are only recorded, they are never hit
+		"cpu/memory/breakpoint/add": func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+			access, _ := params["access"].(string)
+			fake.memoryBPs[uint16(paramInt(params, "addr"))] = access
+			return 200, map[string]any{}, nil
+		},
+		"cpu/memory/breakpoint/remove": func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+			delete(fake.memoryBPs, uint16(paramInt(params, "addr")))
+			return 200, map[string]any{}, nil
+		},
+	}
+	fake.handlers["vic/breakpoint/add"] = func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+		fake.rasterBPs[uint8(paramInt(params, "rasterLine"))] = true
+		return 200, map[string]any{}, nil
+	}
+	fake.handlers["vic/breakpoint/remove"] = func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+		delete(fake.rasterBPs, uint8(paramInt(params, "rasterLine")))
+		return 200, map[string]any{}, nil
+	}
+	fake.handlers["vic/read"] = func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+		return 200, map[string]any{"registers": chipRegisters(params, 0xd000, fake.vic[:], false)}, nil
+	}
+	fake.handlers["cia/read"] = func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+		cia := fake.cia[paramInt(params, "num")&1][:]
+		return 200, map[string]any{"registers": chipRegisters(params, 0xdc00+0x100*uint16(paramInt(params, "num")&1), cia, false)}, nil
+	}
+	fake.handlers["sid/read"] = func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+		return 200, map[string]any{"registers": chipRegisters(params, 0xd400, fake.sid[:], true)}, nil
+	}
+	fake.handlers["drive1541/via/read"] = func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+		num := paramInt(params, "num") & 1
+		return 200, map[string]any{"registers": chipRegisters(params, 0x1800+0x400*uint16(num), fake.via[num][:], false)}, nil
+	}
+	fake.handlers["vic/write"] = func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+		writeChipRegisters(params, 0xd000, fake.vic[:])
+		return 200, map[string]any{}, nil
+	}
+	fake.handlers["cia/write"] = func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+		num := paramInt(params, "num") & 1
+		writeChipRegisters(params, 0xdc00+0x100*uint16(num), fake.cia[num][:])
+		return 200, map[string]any{}, nil
+	}
+	fake.handlers["sid/write"] = func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+		sids, _ := params["sids"].(map[string]any)
+		for _, sid := range sids {
+			sid, _ := sid.(map[string]any)
+			writeChipRegisters(sid, 0xd400, fake.sid[:])
+		}
+		return 200, map[string]any{}, nil
+	}
+	fake.handlers["drive1541/via/write"] = func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+		num := paramInt(params, "num") & 1
+		writeChipRegisters(params, 0x1800+0x400*uint16(num), fake.via[num][:])
+		return 200, map[string]any{}, nil
+	}
+	fake.handlers["segment/read"] = func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+		name, _ := params["segment"].(string)
+		segment, exist := fake.segments[name]
+		if !exist {
+			return 400, map[string]any{"error": "unknown segment " + name}, nil
+		}
+		data := append([]byte{}, fake.ram[segment.Start:int(segment.End)+1]...)
+		return 200, map[string]any{}, data
+	}
+	fake.handlers["segment/write"] = func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+		name, _ := params["segment"].(string)
+		segment, exist := fake.segments[name]
+		if !exist {
+			return 400, map[string]any{"error": "unknown segment " + name}, nil
+		}
+		if len(binaryData) > segment.Size() {
+			return 400, map[string]any{"error": "data exceeds segment " + name}, nil
+		}
+		copy(fake.ram[segment.Start:], binaryData)
+		return 200, map[string]any{}, nil
+	}
+	fake.handlers["cpu/memory/readBlock"] = func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+		address, size := paramInt(params, "address"), paramInt(params, "size")
+		data := make([]byte, size)
+		for idx := range data {
+			data[idx] = fake.cpuRead(uint16(address + idx))
+		}
+		return 200, map[string]any{}, data
+	}
+	fake.handlers["cpu/memory/writeBlock"] = fake.handlers["ram/writeBlock"]
+}
+
+// Byte seen by CPU, ROMs are banked in by $01 (I/O area reads RAM)
+func (fake *fakeServer) cpuRead(address uint16) byte {
+	config := c64dws.MemoryConfigFromPort(fake.ram[0x01])
+	switch {
+	case config.BASIC && address >= 0xa000 && address < 0xc000:
+		return fake.basicROM[address-0xa000]
+	case config.CharROM && address >= 0xd000 && address < 0xe000:
+		return fake.charROM[address-0xd000]
+	case config.KERNAL && address >= 0xe000:
+		return fake.kernalROM[address-0xe000]
+	}
+	return fake.ram[address]
+}
+
+// Maximum number of instructions executed by continue
+const fakeMaxContinueSteps = 100000
+
+// Number of instructions in a frame, raster breakpoints are hit once per frame
+const fakeFrameSteps = 100
+
+// Step until PC reaches a CPU breakpoint or a raster breakpoint line, then queue breakpoint event
+func (fake *fakeServer) runUntilBreakpoint() {
+	for step := 0; step < fakeMaxContinueSteps; step++ {
+		fake.stepInstruction()
+		if step == fakeFrameSteps-1 && len(fake.rasterBPs) > 0 {
+			fake.counters.Frame++
+			for rasterLine := range fake.rasterBPs {
+				fake.pendingEvents = append(fake.pendingEvents, map[string]any{
+					"event":        "breakpoint",
+					"type":         "rasterLine",
+					"breakpointId": 1,
+					"platform":     "c64",
+					"rasterLine":   rasterLine,
+				})
+				return
+			}
+		}
+		if fake.breakpoints[fake.cpu.PC] {
+			fake.pendingEvents = append(fake.pendingEvents, map[string]any{
+				"event":        "breakpoint",
+				"type":         "addr",
+				"breakpointId": 1,
+				"platform":     "c64",
+			})
+			return
+		}
+	}
+}
+
+// Advance PC by the instruction size, follows JMP absolute, JSR and RTS,
+// executes register loads, transfers, increments and stack operations
+func (fake *fakeServer) stepInstruction() {
+	pc := fake.cpu.PC
+	opcode := asm6502.Opcodes[fake.ram[pc]]
+	operand := uint16(fake.ram[pc+1]) | uint16(fake.ram[pc+2])<<8
+	fake.executeRegisterInstruction(opcode, uint8(operand))
+	if opcode.Mode == asm6502.Absolute {
+		switch opcode.Mnemonic {
+		case "sta":
+			fake.ram[operand] = fake.cpu.A
+		case "stx":
+			fake.ram[operand] = fake.cpu.X
+		case "sty":
+			fake.ram[operand] = fake.cpu.Y
+		}
+	}
+	switch {
+	case opcode.Mnemonic == "jmp" && opcode.Mode == asm6502.Absolute:
+		fake.cpu.PC = operand
+	case opcode.Mnemonic == "jsr":
+		returnAddress := pc + 2
+		fake.push(uint8(returnAddress >> 8))
+		fake.push(uint8(returnAddress))
+		fake.cpu.PC = operand
+	case opcode.Mnemonic == "rts":
+		returnAddress := uint16(fake.pull())
+		returnAddress |= uint16(fake.pull()) << 8
+		fake.cpu.PC = returnAddress + 1
+	default:
+		fake.cpu.PC = pc + uint16(max(opcode.Size(), 1))
+	}
+	fake.counters.Cycle += uint64(max(opcode.Cycles, 2))
+	fake.counters.Instruction++
+}
+
+func (fake *fakeServer) executeRegisterInstruction(opcode asm6502.Opcode, operand uint8) {
+	if opcode.Mode == asm6502.Immediate {
+		switch opcode.Mnemonic {
+		case "lda":
+			fake.cpu.A = operand
+		case "ldx":
+			fake.cpu.X = operand
+		case "ldy":
+			fake.cpu.Y = operand
+		}
+		return
+	}
+	switch opcode.Mnemonic {
+	case "tax":
+		fake.cpu.X = fake.cpu.A
+	case "txa":
+		fake.cpu.A = fake.cpu.X
+	case "tay":
+		fake.cpu.Y = fake.cpu.A
+	case "tya":
+		fake.cpu.A = fake.cpu.Y
+	case "txs":
+		fake.cpu.SP = fake.cpu.X
+	case "tsx":
+		fake.cpu.X = fake.cpu.SP
+	case "inx":
+		fake.cpu.X++
+	case "iny":
+		fake.cpu.Y++
+	case "dex":
+		fake.cpu.X--
+	case "dey":
+		fake.cpu.Y--
+	case "pha":
+		fake.push(fake.cpu.A)
+	case "pla":
+		fake.cpu.A = fake.pull()
+	case "php":
+		fake.push(fake.cpu.P | c64dws.FlagBreak | c64dws.FlagUnused)
+	case "plp":
+		fake.cpu.P = fake.pull() | c64dws.FlagUnused
+	case "sec":
+		fake.cpu.P |= c64dws.FlagCarry
+	case "clc":
+		fake.cpu.P &^= c64dws.FlagCarry
+	case "sei":
+		fake.cpu.P |= c64dws.FlagInterrupt
+	case "cli":
+		fake.cpu.P &^= c64dws.FlagInterrupt
+	}
+}
+
+func (fake *fakeServer) push(value uint8) {
+	fake.ram[0x0100|uint16(fake.cpu.SP)] = value
+	fake.cpu.SP--
+}
+
+func (fake *fakeServer) pull() uint8 {
+	fake.cpu.SP++
+	return fake.ram[0x0100|uint16(fake.cpu.SP)]
+}
+
+// Chip registers result: [[register, value], ...], register is an offset or a full address
+func chipRegisters(params map[string]any, baseAddress uint16, values []byte, fullAddress bool) [][2]int {
+	addresses, _ := params["registers"].([]any)
+	result := [][2]int{}
+	for _, address := range addresses {
+		offset := int(address.(float64)) - int(baseAddress)
+		if offset < 0 || offset >= len(values) {
+			continue
+		}
+		register := offset
+		if fullAddress {
+			register += int(baseAddress)
+		}
+		result = append(result, [2]int{register, int(values[offset])})
+	}
+	return result
+}
+
+// Write chip registers from 'registers' map: {"$d020": value, ...}
+func writeChipRegisters(params map[string]any, baseAddress uint16, values []byte) {
+	registers, _ := params["registers"].(map[string]any)
+	for register, value := range registers {
+		address, err := strconv.ParseUint(strings.TrimPrefix(register, "$"), 16, 16)
+		offset := int(address) - int(baseAddress)
+		if err != nil || offset < 0 || offset >= len(values) {
+			continue
+		}
+		values[offset] = byte(value.(float64))
+	}
+}
+
+func paramInt(params map[string]any, key string) int {
+	value, _ := params[key].(float64)
+	return int(value)
+}
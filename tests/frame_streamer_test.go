@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"gotest.tools/assert"
+)
+
+func TestFrameStreamer(t *testing.T) {
+	client, fake := newFakeServerClient(t)
+	fake.writeRAM(0x1000, []byte{0x4c, 0x00, 0x10})
+	fake.setCPU(c64dws.CPUState{PC: 0x1000, SP: 0xff, P: 0x24})
+
+	opts := c64dws.FrameStreamerOptions{
+		RasterLine: 0xff,
+		Buffers: [2]c64dws.FrameBuffer{
+			{Address: 0x2000, D018: 0x18, DD00: 0x03},
+			{Address: 0x6000, D018: 0x18, DD00: 0x02},
+		},
+		FrameSize: 0x100,
+	}
+	render := func(frame int) ([]byte, error) {
+		// first half changes every frame, second half is constant
+		data := bytes.Repeat([]byte{0x55}, 0x100)
+		copy(data, bytes.Repeat([]byte{byte(frame + 1)}, 0x80))
+		return data, nil
+	}
+	streamer, err := client.NewFrameStreamer(opts, render)
+	assert.NilError(t, err)
+
+	// -------------------------------------------------------------
+	// test: buffers are swapped every frame, only changes are uploaded
+	// -------------------------------------------------------------
+	ctx, cancel := context.WithCancel(context.Background())
+	var shown []uint8
+	var flushes []c64dws.FlushStats
+	streamer.OnFrame = func(stats c64dws.FrameStats) {
+		fake.mutex.Lock()
+		shown = append(shown, fake.cia[1][0])
+		fake.mutex.Unlock()
+		flushes = append(flushes, stats.Flush)
+		if stats.Frames == 4 {
+			cancel()
+		}
+	}
+	assert.NilError(t, streamer.Run(ctx))
+
+	assert.DeepEqual(t, shown, []uint8{0x03, 0x02, 0x03, 0x02})
+	assert.Equal(t, flushes[0].Bytes, 0x100)
+	assert.Equal(t, flushes[2].Bytes, 0x80)
+	assert.Equal(t, flushes[2].Saved, 0x80)
+	assert.DeepEqual(t, fake.readRAM(0x2000, 2), []byte{3, 3})
+	assert.DeepEqual(t, fake.readRAM(0x6000, 2), []byte{4, 4})
+	assert.DeepEqual(t, fake.readRAM(0x60ff, 1), []byte{0x55})
+	assert.Equal(t, fake.vic[0x18], uint8(0x18))
+	assert.Equal(t, len(fake.rasterBPs), 0)
+
+	stats := streamer.Stats()
+	assert.Equal(t, stats.Frames, 4)
+	assert.Assert(t, stats.AverageLatency > 0)
+
+	// -------------------------------------------------------------
+	// test: render errors stop streaming
+	// -------------------------------------------------------------
+	streamer, err = client.NewFrameStreamer(opts, func(frame int) ([]byte, error) {
+		return nil, errors.New("Render failed")
+	})
+	assert.NilError(t, err)
+	assert.ErrorContains(t, streamer.Run(context.Background()), "Render failed")
+	assert.Equal(t, len(fake.rasterBPs), 0)
+
+	// -------------------------------------------------------------
+	// test: upload errors stop streaming
+	// -------------------------------------------------------------
+	streamer, err = client.NewFrameStreamer(opts, render)
+	assert.NilError(t, err)
+	fake.mutex.Lock()
+	fake.handlers["ram/writeBlock"] = func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+		return 400, map[string]any{"error": "write failed"}, nil
+	}
+	fake.mutex.Unlock()
+	assert.ErrorContains(t, streamer.Run(context.Background()), "write failed")
+	assert.Equal(t, len(fake.rasterBPs), 0)
+
+	opts.Buffers[1].Address = 0xff80
+	_, err = client.NewFrameStreamer(opts, render)
+	assert.ErrorContains(t, err, "exceeds RAM size")
+}
@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"gotest.tools/assert"
+)
+
+// Wait until the condition (checked with the fake server locked) is true
+func waitForFake(t *testing.T, fake *fakeServer, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		fake.mutex.Lock()
+		done := condition()
+		fake.mutex.Unlock()
+		if done {
+			return
+		}
+		assert.Assert(t, time.Now().Before(deadline), "timeout waiting for fake server state")
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFreezerEveryFrame(t *testing.T) {
+	client, fake := newFakeServerClient(t)
+	fake.writeRAM(0x1000, []byte{0x4c, 0x00, 0x10})
+	fake.setCPU(c64dws.CPUState{PC: 0x1000, SP: 0xff, P: 0x24})
+
+	freezer := client.NewFreezer(c64dws.FreezerOptions{Mode: c64dws.FreezeEveryFrame, RasterLine: 0xfa})
+	assert.NilError(t, freezer.Add(c64dws.TrainerEntry{Address: 0xc010, Value: 9, Description: "lives"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- freezer.Run(ctx)
+	}()
+
+	// -------------------------------------------------------------
+	// test: bytes are re-written every frame
+	// -------------------------------------------------------------
+	waitForFake(t, fake, func() bool { return fake.ram[0xc010] == 9 && fake.rasterBPs[0xfa] })
+	fake.writeRAM(0xc010, []byte{0})
+	waitForFake(t, fake, func() bool { return fake.ram[0xc010] == 9 })
+
+	// -------------------------------------------------------------
+	// test: add and remove at runtime
+	// -------------------------------------------------------------
+	assert.NilError(t, freezer.Add(c64dws.TrainerEntry{Address: 0xc020, Value: 0x40, Description: "energy"}))
+	assert.NilError(t, freezer.Remove(0xc010))
+	fake.writeRAM(0xc010, []byte{1})
+	fake.writeRAM(0xc020, []byte{0})
+	waitForFake(t, fake, func() bool { return fake.ram[0xc020] == 0x40 })
+	assert.DeepEqual(t, fake.readRAM(0xc010, 1), []byte{1})
+
+	cancel()
+	assert.NilError(t, <-done)
+	assert.Equal(t, len(fake.rasterBPs), 0)
+}
+
+func TestFreezerOnWrite(t *testing.T) {
+	client, fake := newFakeServerClient(t)
+	freezer := client.NewFreezer(c64dws.FreezerOptions{Mode: c64dws.FreezeOnWrite})
+	assert.NilError(t, freezer.Add(c64dws.TrainerEntry{Address: 0xc010, Value: 9}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- freezer.Run(ctx)
+	}()
+
+	// -------------------------------------------------------------
+	// test: write breakpoints follow frozen addresses
+	// -------------------------------------------------------------
+	waitForFake(t, fake, func() bool { return fake.memoryBPs[0xc010] == "write" && fake.ram[0xc010] == 9 })
+	assert.NilError(t, freezer.Add(c64dws.TrainerEntry{Address: 0xc011, Value: 3}))
+	assert.NilError(t, freezer.Remove(0xc010))
+	waitForFake(t, fake, func() bool {
+		_, exist := fake.memoryBPs[0xc010]
+		return !exist && fake.memoryBPs[0xc011] == "write" && fake.ram[0xc011] == 3
+	})
+
+	cancel()
+	assert.NilError(t, <-done)
+	assert.Equal(t, len(fake.memoryBPs), 0)
+}
+
+func TestTrainerFile(t *testing.T) {
+	entries, err := c64dws.ReadTrainer(strings.NewReader("# Trainer\n$c010 $09 infinite lives\n\n0xc020 64\n"))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, entries, []c64dws.TrainerEntry{
+		{Address: 0xc010, Value: 9, Description: "infinite lives"},
+		{Address: 0xc020, Value: 64},
+	})
+
+	var buffer bytes.Buffer
+	assert.NilError(t, c64dws.WriteTrainer(&buffer, entries))
+	assert.Equal(t, buffer.String(), "$c010 $09 infinite lives\n$c020 $40\n")
+
+	_, err = c64dws.ReadTrainer(strings.NewReader("$c010 $100\n"))
+	assert.ErrorContains(t, err, "Invalid value in line 1")
+
+	client, _ := newFakeServerClient(t)
+	freezer := client.NewFreezer(c64dws.FreezerOptions{})
+	path := filepath.Join(t.TempDir(), "game.trainer")
+	assert.NilError(t, freezer.Add(entries[0]))
+	assert.NilError(t, freezer.SaveTrainer(path))
+	loaded := client.NewFreezer(c64dws.FreezerOptions{})
+	assert.NilError(t, loaded.LoadTrainer(path))
+	assert.DeepEqual(t, loaded.Entries(), freezer.Entries())
+}
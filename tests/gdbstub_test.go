@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"github.com/mojzesh/c64d-ws-client/gdbstub"
+	"gotest.tools/assert"
+)
+
+// GDB connection driven through a pipe
+type gdbSession struct {
+	t       *testing.T
+	conn    net.Conn
+	replies chan string
+}
+
+func newGDBSession(t *testing.T) (*gdbSession, *fakeServer) {
+	client, fake := newFakeServerClient(t)
+	server := gdbstub.NewServer(client)
+
+	serverConn, clientConn := net.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- server.ServeConn(serverConn)
+	}()
+	t.Cleanup(func() {
+		clientConn.Close()
+		<-done
+	})
+
+	session := &gdbSession{t: t, conn: clientConn, replies: make(chan string, 100)}
+	go func() {
+		defer close(session.replies)
+		reader := bufio.NewReader(clientConn)
+		for {
+			packet, err := gdbstub.ReadPacket(reader)
+			if err != nil {
+				return
+			}
+			session.replies <- packet
+		}
+	}()
+	return session, fake
+}
+
+// Send packet and wait for the reply
+func (s *gdbSession) send(packet string) string {
+	s.t.Helper()
+	_, err := io.WriteString(s.conn, gdbstub.EncodePacket(packet))
+	assert.NilError(s.t, err)
+
+	select {
+	case reply, ok := <-s.replies:
+		assert.Assert(s.t, ok, "connection closed")
+		return reply
+	case <-time.After(5 * time.Second):
+		s.t.Fatal("timeout waiting for reply to " + packet)
+		return ""
+	}
+}
+
+func TestGDBStubPackets(t *testing.T) {
+	assert.Equal(t, gdbstub.EncodePacket("OK"), "$OK#9a")
+	assert.Equal(t, gdbstub.EncodePacket("a#b"), "$a}\x03b#43")
+
+	reader := bufio.NewReader(strings.NewReader("+$a}\x03b#43$m0,2#fb"))
+	packet, err := gdbstub.ReadPacket(reader)
+	assert.NilError(t, err)
+	assert.Equal(t, packet, "a#b")
+	packet, err = gdbstub.ReadPacket(reader)
+	assert.NilError(t, err)
+	assert.Equal(t, packet, "m0,2")
+}
+
+func TestGDBStubSession(t *testing.T) {
+	session, fake := newGDBSession(t)
+	fake.writeRAM(0x1000, []byte{0xa9, 0x05, 0xa2, 0x07, 0xe8, 0x4c, 0x04, 0x10})
+	fake.setCPU(c64dws.CPUState{PC: 0x1000, SP: 0xff, P: 0x24})
+
+	// -------------------------------------------------------------
+	// test: queries and target description
+	// -------------------------------------------------------------
+	assert.Equal(t, session.send("?"), "S05")
+	assert.Assert(t, strings.Contains(session.send("qSupported:multiprocess+"), "qXfer:features:read+"))
+	assert.Assert(t, strings.HasPrefix(session.send("qXfer:features:read:target.xml:0,fff"), "l<?xml"))
+	assert.Equal(t, session.send("vMustReplyEmpty"), "")
+
+	// -------------------------------------------------------------
+	// test: registers
+	// -------------------------------------------------------------
+	assert.Equal(t, session.send("g"), "00000024ff0010")
+	assert.Equal(t, session.send("G42010224f00010"), "OK")
+	assert.Equal(t, session.send("p0"), "42")
+	assert.Equal(t, session.send("p4"), "f0")
+	assert.Equal(t, session.send("P0=07"), "OK")
+	assert.Equal(t, session.send("g"), "07010224f00010")
+
+	// -------------------------------------------------------------
+	// test: memory
+	// -------------------------------------------------------------
+	assert.Equal(t, session.send("m1000,4"), "a905a207")
+	assert.Equal(t, session.send("M2000,2:0102"), "OK")
+	assert.DeepEqual(t, fake.readRAM(0x2000, 2), []byte{0x01, 0x02})
+	assert.Equal(t, session.send("M2000,2:01"), "E01")
+
+	// -------------------------------------------------------------
+	// test: breakpoints, continue and step
+	// -------------------------------------------------------------
+	assert.Equal(t, session.send("Z0,1005,1"), "OK")
+	assert.Equal(t, session.send("c"), "S05")
+	assert.Equal(t, session.send("p5"), "0510")
+	assert.Equal(t, session.send("s"), "S05")
+	assert.Equal(t, session.send("p5"), "0410")
+	assert.Equal(t, session.send("z0,1005,1"), "OK")
+	assert.Equal(t, fake.fnCount("cpu/breakpoint/remove"), 1)
+
+	// -------------------------------------------------------------
+	// test: watchpoints
+	// -------------------------------------------------------------
+	assert.Equal(t, session.send("Z2,d020,2"), "OK")
+	assert.DeepEqual(t, fake.memoryBPs, map[uint16]string{0xd020: "write", 0xd021: "write"})
+	assert.Equal(t, session.send("z2,d020,2"), "OK")
+	assert.Equal(t, len(fake.memoryBPs), 0)
+
+	// access watchpoints are not supported, read replaces write at the same address
+	assert.Equal(t, session.send("Z4,d020,1"), "")
+	assert.Equal(t, len(fake.memoryBPs), 0)
+	assert.Equal(t, session.send("Z2,d020,1"), "OK")
+	assert.Equal(t, session.send("Z3,d020,1"), "OK")
+	assert.DeepEqual(t, fake.memoryBPs, map[uint16]string{0xd020: "read"})
+	assert.Equal(t, session.send("z3,d020,1"), "OK")
+	assert.Equal(t, len(fake.memoryBPs), 0)
+
+	// -------------------------------------------------------------
+	// test: detach continues emulation
+	// -------------------------------------------------------------
+	assert.Equal(t, session.send("Z0,1005,1"), "OK")
+	assert.Equal(t, session.send("D"), "OK")
+	assert.Equal(t, len(fake.breakpoints), 0)
+	assert.Equal(t, fake.fnCount("continue"), 2)
+}
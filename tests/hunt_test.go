@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"gotest.tools/assert"
+)
+
+func TestHuntPatterns(t *testing.T) {
+	pattern, err := c64dws.ParseHuntPattern([]string{"a9", "??", "$8d20d0", "a:Hi", "p:Hi!", "s:@az"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, pattern.Bytes, []byte{0xa9, 0x00, 0x8d, 0x20, 0xd0, 'H', 'i', 0x48, 0x49, 0x21, 0x00, 0x01, 0x1a})
+	assert.DeepEqual(t, pattern.Mask[:3], []bool{true, false, true})
+
+	_, err = c64dws.ParseHuntPattern([]string{"a9?"})
+	assert.ErrorContains(t, err, "Invalid hunt pattern 'a9?'")
+	_, err = c64dws.PETSCIIPattern("ä")
+	assert.ErrorContains(t, err, "can't be converted")
+
+	pattern, err = c64dws.ParseHuntPattern([]string{"01", "?", "01"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, c64dws.FindPattern([]byte{1, 2, 1, 2, 1, 1}, 0x1000, pattern), []uint16{0x1000, 0x1002})
+}
+
+func TestHunt(t *testing.T) {
+	client, fake := newFakeServerClient(t)
+	screenCodes, err := c64dws.ScreenCodePattern("GAME OVER")
+	assert.NilError(t, err)
+	fake.writeRAM(0x0450, screenCodes.Bytes)
+	fake.writeRAM(0xc100, screenCodes.Bytes)
+	fake.mutex.Lock()
+	copy(fake.drive1541RAM[0x0700:], "GAME OVER")
+	fake.mutex.Unlock()
+
+	// -------------------------------------------------------------
+	// test: RAM ranges, whole memory and drive memory
+	// -------------------------------------------------------------
+	found, err := client.Hunt(c64dws.MemorySpaceRAM, []c64dws.AddressRange{{Start: 0x0400, End: 0x07e7}}, screenCodes)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, found, []uint16{0x0450})
+
+	found, err = client.Hunt(c64dws.MemorySpaceCPU, nil, screenCodes)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, found, []uint16{0x0450, 0xc100})
+
+	ascii, err := c64dws.ASCIIPattern("OVER")
+	assert.NilError(t, err)
+	found, err = client.Hunt(c64dws.MemorySpaceDrive1541RAM, nil, ascii)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, found, []uint16{0x0705})
+
+	// occurrences crossing the range end are not found
+	found, err = client.Hunt(c64dws.MemorySpaceRAM, []c64dws.AddressRange{{Start: 0xc000, End: 0xc104}}, screenCodes)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, found, []uint16{})
+}
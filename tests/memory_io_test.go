@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"gotest.tools/assert"
+)
+
+func TestMemoryIO(t *testing.T) {
+	client, fake := newFakeServerClient(t)
+	ram := client.OpenMemory(c64dws.MemorySpaceRAM)
+
+	// -------------------------------------------------------------
+	// test: standard io works against emulator memory
+	// -------------------------------------------------------------
+	writer := io.NewOffsetWriter(ram, 0xc000)
+	assert.NilError(t, binary.Write(writer, binary.LittleEndian, uint32(0x12345678)))
+	assert.DeepEqual(t, fake.readRAM(0xc000, 4), []byte{0x78, 0x56, 0x34, 0x12})
+
+	var value uint16
+	assert.NilError(t, binary.Read(io.NewSectionReader(ram, 0xc001, 2), binary.BigEndian, &value))
+	assert.Equal(t, value, uint16(0x5634))
+
+	// -------------------------------------------------------------
+	// test: seek, read and write at the current address
+	// -------------------------------------------------------------
+	offset, err := ram.Seek(0xc002, io.SeekStart)
+	assert.NilError(t, err)
+	assert.Equal(t, offset, int64(0xc002))
+	_, err = ram.Write([]byte{0xaa})
+	assert.NilError(t, err)
+	_, err = ram.Seek(-2, io.SeekCurrent)
+	assert.NilError(t, err)
+	data := make([]byte, 3)
+	_, err = io.ReadFull(ram, data)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, data, []byte{0x56, 0xaa, 0x12})
+	_, err = ram.Seek(-1, io.SeekStart)
+	assert.ErrorContains(t, err, "before the start")
+
+	// -------------------------------------------------------------
+	// test: end of the memory space
+	// -------------------------------------------------------------
+	drive := client.OpenMemory(c64dws.MemorySpaceDrive1541RAM)
+	offset, err = drive.Seek(-2, io.SeekEnd)
+	assert.NilError(t, err)
+	assert.Equal(t, offset, int64(0x07fe))
+	n, err := drive.Read(make([]byte, 4))
+	assert.Equal(t, n, 2)
+	assert.Equal(t, err, io.EOF)
+	n, err = drive.Read(make([]byte, 4))
+	assert.Equal(t, n, 0)
+	assert.Equal(t, err, io.EOF)
+	_, err = drive.WriteAt([]byte{1, 2}, 0x07ff)
+	assert.ErrorContains(t, err, "exceeds Drive1541RAM memory size")
+}
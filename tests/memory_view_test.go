@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"gotest.tools/assert"
+)
+
+func TestMemoryConfig(t *testing.T) {
+	for port, expected := range []string{"RAM", "CHAR", "CHAR+KERNAL", "BASIC+CHAR+KERNAL", "RAM", "I/O", "I/O+KERNAL", "BASIC+I/O+KERNAL"} {
+		config := c64dws.MemoryConfigFromPort(uint8(port) | 0x30)
+		assert.Equal(t, config.String(), expected)
+		selected, err := config.Port()
+		assert.NilError(t, err)
+		assert.Equal(t, c64dws.MemoryConfigFromPort(selected), config)
+	}
+
+	_, err := c64dws.MemoryConfig{BASIC: true}.Port()
+	assert.ErrorContains(t, err, "Memory configuration BASIC can't be selected")
+	_, err = c64dws.MemoryConfig{KERNAL: true, CharROM: true, IO: true}.Port()
+	assert.ErrorContains(t, err, "can't be selected")
+}
+
+func TestMemoryView(t *testing.T) {
+	client, fake := newFakeServerClient(t)
+	fake.writeRAM(0x0000, []byte{0x2f, 0x35})
+	fake.writeRAM(0xe000, []byte{0x11, 0x22})
+
+	// -------------------------------------------------------------
+	// test: ROM is read while RAM is banked in, $00 / $01 are restored
+	// -------------------------------------------------------------
+	roms, err := client.NewMemoryView(c64dws.MemoryConfigROMs)
+	assert.NilError(t, err)
+	data, err := roms.Read(0xdfff, 3)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, data, []byte{0xc4, 0xee, 0xee})
+	assert.DeepEqual(t, fake.readRAM(0x0000, 2), []byte{0x2f, 0x35})
+	assert.Assert(t, fake.fnCount("pause") > 0)
+
+	// -------------------------------------------------------------
+	// test: RAM under KERNAL, writes go to RAM
+	// -------------------------------------------------------------
+	ram, err := client.NewMemoryView(c64dws.MemoryConfigRAM)
+	assert.NilError(t, err)
+	data, err = ram.Read(0xe000, 2)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, data, []byte{0x11, 0x22})
+
+	kernal, err := client.NewMemoryView(c64dws.MemoryConfigDefault)
+	assert.NilError(t, err)
+	assert.NilError(t, kernal.Write(0xe001, []byte{0x33}))
+	assert.DeepEqual(t, fake.readRAM(0xe000, 2), []byte{0x11, 0x33})
+	data, err = kernal.Read(0xe001, 1)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, data, []byte{0xee})
+	assert.DeepEqual(t, fake.readRAM(0x0000, 2), []byte{0x2f, 0x35})
+}
@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"gotest.tools/assert"
+)
+
+// Wait until the fake server received count requests of the function
+func waitForFnCount(t *testing.T, fake *fakeServer, fn string, count int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for fake.fnCount(fn) < count {
+		assert.Assert(t, time.Now().Before(deadline), "timeout waiting for %s", fn)
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMirroredRAM(t *testing.T) {
+	client, fake := newFakeServerClient(t)
+	mirror, err := client.NewMirroredRAM(c64dws.MemorySpaceRAM, c64dws.AddressRange{Start: 0x2000, End: 0x3fff})
+	assert.NilError(t, err)
+	mirror.RequestCost = 16
+
+	// -------------------------------------------------------------
+	// test: unchanged values are not dirty, close runs are merged
+	// -------------------------------------------------------------
+	mirror.Write(0x2000, []byte{0, 0, 0})
+	assert.Equal(t, mirror.DirtyBytes(), 0)
+	mirror.Write(0x2100, []byte{1, 2, 3})
+	mirror.SetUByte8(0x2110, 4)
+	mirror.Fill(0x3000, 4, 0xff)
+	assert.DeepEqual(t, mirror.DirtyRuns(), []c64dws.AddressRange{{Start: 0x2100, End: 0x2110}, {Start: 0x3000, End: 0x3003}})
+
+	stats, err := mirror.Flush()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, stats, c64dws.FlushStats{Requests: 2, DirtyBytes: 8, Bytes: 21, Saved: 0x2000 - 21})
+	assert.Equal(t, mirror.DirtyBytes(), 0)
+	waitForFnCount(t, fake, "ram/writeBlock", 2)
+	assert.DeepEqual(t, fake.readRAM(0x2100, 3), []byte{1, 2, 3})
+	assert.DeepEqual(t, fake.readRAM(0x2110, 1), []byte{4})
+	assert.DeepEqual(t, fake.readRAM(0x3000, 4), []byte{0xff, 0xff, 0xff, 0xff})
+
+	// -------------------------------------------------------------
+	// test: nothing to flush, whole range after Invalidate
+	// -------------------------------------------------------------
+	stats, err = mirror.Flush()
+	assert.NilError(t, err)
+	assert.Equal(t, stats.Requests, 0)
+	mirror.Invalidate()
+	stats, err = mirror.Flush()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, stats, c64dws.FlushStats{Requests: 1, DirtyBytes: 0x2000, Bytes: 0x2000, Saved: 0})
+	waitForFnCount(t, fake, "ram/writeBlock", 3)
+
+	// -------------------------------------------------------------
+	// test: load drops dirty bytes
+	// -------------------------------------------------------------
+	fake.writeRAM(0x2005, []byte{0x42})
+	mirror.SetUByte8(0x2006, 1)
+	assert.NilError(t, mirror.Load())
+	assert.Equal(t, mirror.GetUByte8(0x2005), byte(0x42))
+	assert.Equal(t, mirror.DirtyBytes(), 0)
+
+	// -------------------------------------------------------------
+	// test: sync flush reports failed writes, bytes stay dirty
+	// -------------------------------------------------------------
+	mirror.SetUByte8(0x2200, 0x55)
+	stats, err = mirror.FlushSync()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, stats, c64dws.FlushStats{Requests: 1, DirtyBytes: 1, Bytes: 1, Saved: 0x2000 - 1})
+	assert.DeepEqual(t, fake.readRAM(0x2200, 1), []byte{0x55})
+
+	fake.mutex.Lock()
+	fake.handlers["ram/writeBlock"] = func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+		return 400, map[string]any{"error": "write failed"}, nil
+	}
+	fake.mutex.Unlock()
+	mirror.SetUByte8(0x2200, 0x66)
+	_, err = mirror.FlushSync()
+	assert.ErrorContains(t, err, "write failed")
+	assert.Equal(t, mirror.DirtyBytes(), 1)
+
+	_, err = client.NewMirroredRAM(c64dws.MemorySpaceDrive1541RAM, c64dws.AddressRange{Start: 0x0700, End: 0x08ff})
+	assert.ErrorContains(t, err, "exceeds Drive1541RAM memory size")
+}
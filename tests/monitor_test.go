@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"github.com/mojzesh/c64d-ws-client/monitor"
+	"gotest.tools/assert"
+)
+
+func newTestMonitor(t *testing.T) (*monitor.Monitor, *fakeServer, *bytes.Buffer) {
+	client, fake := newFakeServerClient(t)
+	var output bytes.Buffer
+	return monitor.New(client, &output), fake, &output
+}
+
+// Execute command and return its output
+func execute(t *testing.T, mon *monitor.Monitor, output *bytes.Buffer, line string) string {
+	output.Reset()
+	err := mon.Execute(context.Background(), line)
+	assert.NilError(t, err, line)
+	return output.String()
+}
+
+func TestFormatDump(t *testing.T) {
+	data := []byte{0xa9, 0x01, 0x8d, 0x20, 0xd0, 0x41, 0x42}
+
+	assert.Equal(t,
+		monitor.FormatDump(0x0810, data, 4, monitor.DumpStyleActionReplay),
+		":0810  a9 01 8d 20  ... \n"+
+			":0814  d0 41 42     .AB\n",
+	)
+	assert.Equal(t,
+		monitor.FormatDump(0x0810, data, 4, monitor.DumpStyleKickAss),
+		".byte $a9, $01, $8d, $20\n"+
+			".byte $d0, $41, $42\n",
+	)
+
+	style, err := monitor.ParseDumpStyle("kickass")
+	assert.NilError(t, err)
+	assert.Equal(t, style, monitor.DumpStyleKickAss)
+	_, err = monitor.ParseDumpStyle("hex")
+	assert.Error(t, err, "Unknown dump style 'hex', use 'ar' or 'kickass'")
+}
+
+func TestMonitorMemoryCommands(t *testing.T) {
+	mon, fake, output := newTestMonitor(t)
+
+	// -------------------------------------------------------------
+	// test: poke, fill and memory dump
+	// -------------------------------------------------------------
+	execute(t, mon, output, ">c000 a9 01 8d 20 d0")
+	assert.DeepEqual(t, fake.readRAM(0xc000, 5), []byte{0xa9, 0x01, 0x8d, 0x20, 0xd0})
+
+	execute(t, mon, output, "f c008 c00f 41 42")
+	assert.DeepEqual(t, fake.readRAM(0xc008, 8), []byte("ABABABAB"))
+
+	assert.Equal(t, execute(t, mon, output, "m c000 c00f"),
+		":c000  a9 01 8d 20 d0 00 00 00  ... ....\n"+
+			":c008  41 42 41 42 41 42 41 42  ABABABAB\n",
+	)
+
+	execute(t, mon, output, "style kickass 16")
+	assert.Equal(t, execute(t, mon, output, "m c000 c003"), ".byte $a9, $01, $8d, $20\n")
+
+	// -------------------------------------------------------------
+	// test: hunt with wildcard
+	// -------------------------------------------------------------
+	assert.Equal(t, execute(t, mon, output, "h c000 c0ff 41 ?? 41"), "c008 c00a c00c\n")
+	assert.Equal(t, execute(t, mon, output, "h c000 c0ff p:bab"), "c009 c00b c00d\n")
+
+	// -------------------------------------------------------------
+	// test: disassemble
+	// -------------------------------------------------------------
+	assert.Equal(t, execute(t, mon, output, "d c000 c002"),
+		".C:c000  a9 01     lda #$01\n"+
+			".C:c002  8d 20 d0  sta $d020\n",
+	)
+
+	// -------------------------------------------------------------
+	// test: save and load PRG
+	// -------------------------------------------------------------
+	path := filepath.Join(t.TempDir(), "test.prg")
+	execute(t, mon, output, `s "`+path+`" c000 c004`)
+	prg, err := os.ReadFile(path)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, prg, []byte{0x00, 0xc0, 0xa9, 0x01, 0x8d, 0x20, 0xd0})
+
+	prg[0] = 0x00
+	prg[1] = 0x20
+	err = os.WriteFile(path, prg, 0644)
+	assert.NilError(t, err)
+	assert.Equal(t, execute(t, mon, output, `l "`+path+`"`), "Loaded $2000-$2004\n")
+	assert.DeepEqual(t, fake.readRAM(0x2000, 5), []byte{0xa9, 0x01, 0x8d, 0x20, 0xd0})
+}
+
+func TestMonitorExecutionCommands(t *testing.T) {
+	mon, fake, output := newTestMonitor(t)
+
+	execute(t, mon, output, ">1000 a9 05 a2 07 e8 4c 04 10")
+	fake.setCPU(c64dws.CPUState{PC: 0x1000, SP: 0xff})
+
+	// -------------------------------------------------------------
+	// test: step and registers
+	// -------------------------------------------------------------
+	assert.Equal(t, execute(t, mon, output, "z 2"), ".C:1004  e8        inx\n")
+	assert.Equal(t, execute(t, mon, output, "r"),
+		"  ADDR A  X  Y  SP 01 NV-BDIZC LIN CYC\n"+
+			".;1004 05 07 00 ff 37 ..-..... 000 000\n",
+	)
+
+	// -------------------------------------------------------------
+	// test: breakpoints and go
+	// -------------------------------------------------------------
+	execute(t, mon, output, "b 1005")
+	assert.Equal(t, execute(t, mon, output, "b"), "BREAK: $1005\n")
+
+	goOutput := execute(t, mon, output, "g 1000")
+	assert.Assert(t, strings.HasPrefix(goOutput, "BREAK\n"))
+	assert.Assert(t, strings.HasSuffix(goOutput, ".C:1005  4c 04 10  jmp $1004\n"))
+
+	execute(t, mon, output, "bd")
+	assert.Equal(t, execute(t, mon, output, "b"), "")
+	assert.Equal(t, fake.fnCount("cpu/breakpoint/remove"), 1)
+}
+
+func TestMonitorScriptAndHistory(t *testing.T) {
+	mon, fake, output := newTestMonitor(t)
+
+	script := filepath.Join(t.TempDir(), "script.mon")
+	err := os.WriteFile(script, []byte("; setup\n>c000 01 02\n\n# dump\nm c000 c001\n"), 0644)
+	assert.NilError(t, err)
+
+	err = mon.RunScript(context.Background(), script)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, fake.readRAM(0xc000, 2), []byte{0x01, 0x02})
+	assert.Equal(t, output.String(), ":c000  01 02                    ..\n")
+
+	// -------------------------------------------------------------
+	// test: history recall
+	// -------------------------------------------------------------
+	assert.DeepEqual(t, mon.History(), []string{">c000 01 02", "m c000 c001"})
+	assert.Equal(t, execute(t, mon, output, "!!"), ":c000  01 02                    ..\n")
+	assert.Equal(t, execute(t, mon, output, "history"), "   1  >c000 01 02\n   2  m c000 c001\n   3  m c000 c001\n   4  history\n")
+
+	// -------------------------------------------------------------
+	// test: errors
+	// -------------------------------------------------------------
+	err = mon.Execute(context.Background(), "foo")
+	assert.Error(t, err, "Unknown command 'foo', type 'help' for the list of commands")
+	err = mon.Execute(context.Background(), "f c000")
+	assert.Error(t, err, "Usage: f start end byte [byte...]")
+	err = mon.Execute(context.Background(), ", ,")
+	assert.Error(t, err, "Missing command, type 'help' for the list of commands")
+	err = mon.Execute(context.Background(), "x")
+	assert.Equal(t, err, monitor.ErrQuit)
+
+	// script stops on the first error
+	err = os.WriteFile(script, []byte("m zzzz\n>c000 ff\n"), 0644)
+	assert.NilError(t, err)
+	err = mon.RunScript(context.Background(), script)
+	assert.Error(t, err, "Invalid address 'zzzz'")
+	assert.DeepEqual(t, fake.readRAM(0xc000, 1), []byte{0x01})
+}
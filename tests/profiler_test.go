@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"github.com/mojzesh/c64d-ws-client/symbols"
+	"gotest.tools/assert"
+)
+
+const profilerProgram = `
+main:	nop
+	jsr work
+	jmp main
+work:	nop
+	nop
+	nop
+	nop
+	jmp main
+`
+
+func TestProfileStepping(t *testing.T) {
+	client, fake := newFakeServerClient(t)
+
+	err := client.AssembleAndWrite(0x0810, profilerProgram)
+	assert.NilError(t, err)
+	fake.setCPU(c64dws.CPUState{PC: 0x0810})
+
+	symbolTable := symbols.NewSymbolTable()
+	symbolTable.Add("main", 0x0810)
+	symbolTable.Add("work", 0x0817)
+	client.SetSymbols(symbolTable)
+
+	// -------------------------------------------------------------
+	// test: every instruction is sampled in stepping mode
+	// -------------------------------------------------------------
+	profile, err := client.Profile(context.Background(), c64dws.ProfileOptions{
+		Mode:       c64dws.ProfileModeStepping,
+		MaxSamples: 12,
+		Warp:       true,
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, profile.Total, 12)
+	assert.Equal(t, fake.fnCount("warp/set"), 2)
+
+	// PC sequence: 0811 0817 0818 0819 081a 081b 0810 | 0811 0817 0818 0819 081a
+	hotspots := profile.Hotspots(0)
+	assert.Equal(t, len(hotspots), 7)
+	assert.Equal(t, hotspots[0].Address, uint16(0x0811))
+	assert.Equal(t, hotspots[0].Samples, 2)
+	assert.Equal(t, hotspots[0].Name, "main+1")
+	assert.Equal(t, hotspots[6].Address, uint16(0x081b))
+
+	bySymbol := profile.HotspotsBySymbol(0)
+	assert.Equal(t, len(bySymbol), 2)
+	assert.Equal(t, bySymbol[0].Name, "work")
+	assert.Equal(t, bySymbol[0].Samples, 9)
+	assert.Equal(t, bySymbol[1].Name, "main")
+	assert.Equal(t, bySymbol[1].Percent, 25.0)
+}
+
+func TestProfileDuration(t *testing.T) {
+	client, fake := newFakeServerClient(t)
+	fake.setCPU(c64dws.CPUState{PC: 0x0810})
+
+	// -------------------------------------------------------------
+	// test: sampling stops at Duration
+	// -------------------------------------------------------------
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	profile, err := client.Profile(ctx, c64dws.ProfileOptions{
+		Mode:     c64dws.ProfileModeRunning,
+		Interval: 20 * time.Millisecond,
+		Duration: 100 * time.Millisecond,
+	})
+	assert.NilError(t, err)
+	assert.Assert(t, profile.Duration < time.Second, profile.Duration)
+	assert.Assert(t, profile.Total > 0 && profile.Total <= 10, profile.Total)
+	assert.NilError(t, ctx.Err())
+}
+
+func TestProfileBySymbol(t *testing.T) {
+	symbolTable := symbols.NewSymbolTable()
+	symbolTable.Add("main", 0x0810)
+	symbolTable.Add("work", 0x0817)
+
+	profile := c64dws.NewProfile(symbolTable)
+	for _, pc := range []uint16{0x0810, 0x0811, 0x0817, 0x0818, 0x0819, 0x0800, 0x0917} {
+		profile.AddSample(pc)
+	}
+
+	bySymbol := profile.HotspotsBySymbol(2)
+	assert.Equal(t, len(bySymbol), 2)
+	assert.Equal(t, bySymbol[0].Name, "work")
+	assert.Equal(t, bySymbol[0].Samples, 3)
+	assert.Equal(t, bySymbol[0].Percent, 300.0/7)
+	assert.Equal(t, bySymbol[1].Name, "main")
+
+	// samples below the first symbol or too far from the nearest one are aggregated by PC
+	bySymbol = profile.HotspotsBySymbol(0)
+	assert.Equal(t, bySymbol[2].Name, "$0800")
+	assert.Equal(t, bySymbol[3].Name, "$0917")
+	assert.Equal(t, bySymbol[3].Address, uint16(0x0917))
+}
+
+func TestProfileWritePprof(t *testing.T) {
+	symbolTable := symbols.NewSymbolTable()
+	symbolTable.Add("main", 0x0810)
+	symbolTable.Add("work", 0x0817)
+
+	profile := c64dws.NewProfile(symbolTable)
+	for _, pc := range []uint16{0x0810, 0x0817, 0x0817, 0x0818} {
+		profile.AddSample(pc)
+	}
+
+	var buffer bytes.Buffer
+	err := profile.WritePprof(&buffer)
+	assert.NilError(t, err)
+
+	gzipReader, err := gzip.NewReader(&buffer)
+	assert.NilError(t, err)
+	data, err := io.ReadAll(gzipReader)
+	assert.NilError(t, err)
+	assert.Assert(t, bytes.Contains(data, []byte("samples")))
+	assert.Assert(t, bytes.Contains(data, []byte("main")))
+	assert.Assert(t, bytes.Contains(data, []byte("work")))
+
+}
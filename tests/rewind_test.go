@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"gotest.tools/assert"
+)
+
+func TestRewindBuffer(t *testing.T) {
+	client, fake := newFakeServerClient(t)
+	// loop: inx / jmp loop, X counts executed loops
+	fake.writeRAM(0x1000, []byte{0xe8, 0x4c, 0x00, 0x10})
+	fake.setCPU(c64dws.CPUState{PC: 0x1000, SP: 0xff, P: 0x24})
+
+	// -------------------------------------------------------------
+	// test: snapshots are captured every 2 frames, oldest are dropped
+	// -------------------------------------------------------------
+	buffer := client.NewRewindBuffer(c64dws.RewindOptions{Capacity: 3, EveryFrames: 2, RasterLine: 0x30})
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for buffer.Len() < 3 || fake.fnCount("continue") < 8 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	assert.NilError(t, buffer.Run(ctx))
+	assert.Equal(t, buffer.Len(), 3)
+	assert.Equal(t, len(fake.rasterBPs), 0)
+
+	latest, err := buffer.Snapshot(0)
+	assert.NilError(t, err)
+	oldest, err := buffer.Snapshot(2)
+	assert.NilError(t, err)
+	assert.Equal(t, latest.Counters.Frame-oldest.Counters.Frame, uint64(4))
+	_, err = buffer.Snapshot(3)
+	assert.ErrorContains(t, err, "contains 3 snapshots")
+
+	// -------------------------------------------------------------
+	// test: rewind restores the state and drops newer snapshots
+	// -------------------------------------------------------------
+	assert.NilError(t, buffer.Rewind(1))
+	assert.Equal(t, buffer.Len(), 2)
+	restored, err := buffer.Snapshot(0)
+	assert.NilError(t, err)
+	state, err := client.ReadCPUStatus()
+	assert.NilError(t, err)
+	assert.Equal(t, state.X, restored.CPU.X)
+	assert.Equal(t, state.PC, restored.CPU.PC)
+
+	// -------------------------------------------------------------
+	// test: export chosen state
+	// -------------------------------------------------------------
+	path := filepath.Join(t.TempDir(), "glitch.c64dsnap")
+	assert.NilError(t, buffer.Export(1, path))
+	exported, err := c64dws.LoadSnapshotFile(path)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, exported, oldest)
+}
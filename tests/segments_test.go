@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"gotest.tools/assert"
+)
+
+func TestSegments(t *testing.T) {
+	client, fake := newFakeServerClient(t)
+
+	// -------------------------------------------------------------
+	// test: discovery keeps names accepted by the server
+	// -------------------------------------------------------------
+	segments, err := client.DiscoverSegments([]c64dws.Segment{c64dws.SegmentDefault, "Code", "Music"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, segments, []c64dws.Segment{c64dws.SegmentDefault, "Code"})
+
+	// -------------------------------------------------------------
+	// test: read and write every supported segment
+	// -------------------------------------------------------------
+	for _, segment := range segments {
+		r := fake.segments[string(segment)]
+		data := getSliceOfConsecutiveBytes(0x40, r.Size())
+		assert.NilError(t, client.WriteSegmentData(segment, data))
+		assert.DeepEqual(t, fake.readRAM(r.Start, r.Size()), data)
+
+		read, err := client.ReadSegmentData(segment)
+		assert.NilError(t, err)
+		assert.Equal(t, read.Segment, segment)
+		assert.DeepEqual(t, read.Data, data)
+	}
+
+	// -------------------------------------------------------------
+	// test: errors name the segment
+	// -------------------------------------------------------------
+	_, err = client.ReadSegmentData("Music")
+	assert.ErrorContains(t, err, "Segment 'Music': Request failed with status 400: unknown segment Music")
+	err = client.WriteSegmentData("Default", make([]byte, 0x100))
+	assert.ErrorContains(t, err, "data exceeds segment Default")
+
+	// -------------------------------------------------------------
+	// test: discovery stops at errors other than unknown segment
+	// -------------------------------------------------------------
+	fake.mutex.Lock()
+	fake.handlers["segment/read"] = func(params map[string]any, binaryData []byte) (int, map[string]any, []byte) {
+		return 400, map[string]any{"error": "emulation is not paused"}, nil
+	}
+	fake.mutex.Unlock()
+	_, err = client.DiscoverSegments([]c64dws.Segment{c64dws.SegmentDefault})
+	assert.ErrorContains(t, err, "emulation is not paused")
+}
@@ -0,0 +1,168 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"gotest.tools/assert"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	client, fake := newFakeServerClient(t)
+	fake.writeRAM(0x1000, []byte{0xa9, 0x05, 0xe8, 0x4c, 0x02, 0x10})
+	fake.writeRAM(c64dws.ColorRAMAddress, []byte{0x01, 0x02, 0x03})
+	fake.setCPU(c64dws.CPUState{PC: 0x1002, A: 0x05, X: 0x10, Y: 0x20, SP: 0xf0, P: 0x25})
+	fake.mutex.Lock()
+	fake.vic[0x20] = 0x0e
+	fake.cia[1][0x00] = 0x03
+	fake.sid[0x18] = 0x0f
+	fake.vic[0x19] = 0x81
+	fake.cia[0][0x04] = 0x34
+	fake.cia[0][0x0d] = 0x81
+	fake.cia[0][0x0e] = 0x01
+	fake.via[1][0x00] = 0x0c
+	fake.via[1][0x0e] = 0x82
+	fake.drive1541RAM[0x0300] = 0x60
+	fake.counters.Frame = 50
+	fake.mutex.Unlock()
+
+	// -------------------------------------------------------------
+	// test: snapshot captures the machine state
+	// -------------------------------------------------------------
+	snapshot, err := client.Snapshot()
+	assert.NilError(t, err)
+	assert.Equal(t, snapshot.CPU.PC, uint16(0x1002))
+	assert.Equal(t, snapshot.CPU.SP, uint8(0xf0))
+	assert.Equal(t, snapshot.Counters.Frame, uint64(50))
+	assert.DeepEqual(t, snapshot.ProcessorPort, [2]byte{0x00, 0x37})
+	assert.DeepEqual(t, snapshot.RAM[0x1000:0x1006], []byte{0xa9, 0x05, 0xe8, 0x4c, 0x02, 0x10})
+	assert.DeepEqual(t, snapshot.ColorRAM[:3], []byte{0x01, 0x02, 0x03})
+	assert.Equal(t, snapshot.Chips[c64dws.ChipVIC][0x20], uint8(0x0e))
+	assert.Equal(t, snapshot.Chips[c64dws.ChipCIA2][0x00], uint8(0x03))
+	assert.Equal(t, snapshot.Chips[c64dws.ChipSID][0x18], uint8(0x0f))
+	assert.Equal(t, snapshot.Chips[c64dws.ChipDrive1541VIA2][0x00], uint8(0x0c))
+	assert.Equal(t, snapshot.DriveRAM[0x0300], uint8(0x60))
+	assert.DeepEqual(t, fake.readRAM(0x0000, 2), []byte{0x00, 0x37})
+
+	// -------------------------------------------------------------
+	// test: file format round trip
+	// -------------------------------------------------------------
+	path := filepath.Join(t.TempDir(), "machine.c64dsnap")
+	assert.NilError(t, snapshot.SaveFile(path))
+	loaded, err := c64dws.LoadSnapshotFile(path)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, loaded, snapshot)
+
+	_, err = c64dws.ReadSnapshot(bytes.NewReader([]byte("C64DSNAP\x63\x00")))
+	assert.ErrorContains(t, err, "Unsupported snapshot version 99")
+
+	// -------------------------------------------------------------
+	// test: restore pushes everything back
+	// -------------------------------------------------------------
+	fake.writeRAM(0x0000, make([]byte, 0x10000))
+	fake.setCPU(c64dws.CPUState{PC: 0x2000, SP: 0xff})
+	fake.mutex.Lock()
+	fake.vic = [0x2f]byte{}
+	fake.cia = [2][0x10]byte{}
+	fake.sid = [0x1d]byte{}
+	fake.via = [2][0x10]byte{}
+	fake.drive1541RAM[0x0300] = 0x00
+	fake.mutex.Unlock()
+
+	assert.NilError(t, client.Restore(loaded))
+	state, err := client.ReadCPUStatus()
+	assert.NilError(t, err)
+	assert.Equal(t, state.PC, uint16(0x1002))
+	assert.Equal(t, state.A, uint8(0x05))
+	assert.Equal(t, state.X, uint8(0x10))
+	assert.Equal(t, state.Y, uint8(0x20))
+	assert.Equal(t, state.SP, uint8(0xf0))
+	assert.Equal(t, state.P, uint8(0x25))
+
+	restored, err := client.Snapshot()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, restored.RAM, snapshot.RAM)
+	assert.DeepEqual(t, restored.ColorRAM, snapshot.ColorRAM)
+	assert.DeepEqual(t, restored.DriveRAM, snapshot.DriveRAM)
+	assert.Equal(t, restored.Chips[c64dws.ChipVIC][0x20], uint8(0x0e))
+	assert.Equal(t, restored.Chips[c64dws.ChipCIA2][0x00], uint8(0x03))
+	assert.Equal(t, restored.Chips[c64dws.ChipDrive1541VIA2][0x00], uint8(0x0c))
+
+	// timer counter goes to the latch and is force loaded
+	assert.Equal(t, restored.Chips[c64dws.ChipCIA1][0x04], uint8(0x34))
+	assert.Equal(t, restored.Chips[c64dws.ChipCIA1][0x0e], uint8(0x11))
+	// interrupt enable mask is set again
+	assert.Equal(t, restored.Chips[c64dws.ChipDrive1541VIA2][0x0e], uint8(0x82))
+
+	// registers with write side effects and write-only registers are not written
+	assert.Equal(t, restored.Chips[c64dws.ChipVIC][0x19], uint8(0x00))
+	assert.Equal(t, restored.Chips[c64dws.ChipCIA1][0x0d], uint8(0x00))
+	assert.Equal(t, restored.Chips[c64dws.ChipSID][0x18], uint8(0x00))
+}
+
+func newTestSnapshot() *c64dws.Snapshot {
+	snapshot := &c64dws.Snapshot{
+		RAM:      make([]byte, 0x10000),
+		ColorRAM: make([]byte, c64dws.ColorRAMSize),
+		DriveRAM: make([]byte, 0x0800),
+		Chips:    map[c64dws.Chip][]byte{},
+	}
+	for _, chip := range append(c64dws.Chips, c64dws.DriveChips...) {
+		snapshot.Chips[chip] = make([]byte, chip.RegistersCount())
+	}
+	return snapshot
+}
+
+func TestSnapshotDiff(t *testing.T) {
+	old, new := newTestSnapshot(), newTestSnapshot()
+	old.CPU = c64dws.CPUState{PC: 0x1000, A: 0x01, SP: 0xff}
+	new.CPU = c64dws.CPUState{PC: 0x1010, A: 0x01, SP: 0xfd}
+	old.Counters = c64dws.CycleCounters{Cycle: 1000, Frame: 1, Instruction: 300}
+	new.Counters = c64dws.CycleCounters{Cycle: 20656, Frame: 2, Instruction: 5300}
+	copy(new.RAM[0x0400:], []byte{0x01, 0x02, 0x03})
+	new.RAM[0x0410] = 0xff
+	new.ColorRAM[0x0001] = 0x0e
+	new.Chips[c64dws.ChipVIC][0x20] = 0x0e
+	new.Chips[c64dws.ChipVIC][0x18] = 0x14
+	new.Chips[c64dws.ChipCIA2][0x00] = 0x03
+
+	// -------------------------------------------------------------
+	// test: changes are reported
+	// -------------------------------------------------------------
+	diff := c64dws.DiffSnapshots(old, new)
+	assert.Assert(t, !diff.Empty())
+	assert.DeepEqual(t, diff.CPU, []c64dws.ValueDiff{{Name: "PC", Old: 0x1000, New: 0x1010}, {Name: "SP", Old: 0xff, New: 0xfd}})
+	assert.DeepEqual(t, diff.Elapsed, c64dws.CycleCounters{Cycle: 19656, Frame: 1, Instruction: 5000})
+	assert.DeepEqual(t, diff.Memory, []c64dws.MemoryDiff{
+		{Memory: c64dws.DiffMemoryRAM, Range: c64dws.AddressRange{Start: 0x0400, End: 0x0402}, Old: c64dws.HexBytes{0, 0, 0}, New: c64dws.HexBytes{1, 2, 3}},
+		{Memory: c64dws.DiffMemoryRAM, Range: c64dws.AddressRange{Start: 0x0410, End: 0x0410}, Old: c64dws.HexBytes{0}, New: c64dws.HexBytes{0xff}},
+		{Memory: c64dws.DiffMemoryColorRAM, Range: c64dws.AddressRange{Start: 0xd801, End: 0xd801}, Old: c64dws.HexBytes{0}, New: c64dws.HexBytes{0x0e}},
+	})
+	assert.DeepEqual(t, diff.Registers, []c64dws.RegisterDiff{
+		{Chip: "VIC", Address: 0xd018, Name: "memory pointers", Old: 0x00, New: 0x14,
+			OldDescription: "screen +$0000, charset +$0000, bitmap +$0000", NewDescription: "screen +$0400, charset +$1000, bitmap +$0000"},
+		{Chip: "VIC", Address: 0xd020, Name: "border color", Old: 0x00, New: 0x0e, OldDescription: "black", NewDescription: "light blue"},
+		{Chip: "CIA2", Address: 0xdd00, Name: "port A", Old: 0x00, New: 0x03, OldDescription: "VIC bank $c000", NewDescription: "VIC bank $0000"},
+	})
+
+	// -------------------------------------------------------------
+	// test: text and JSON reports
+	// -------------------------------------------------------------
+	text := diff.String()
+	assert.Assert(t, strings.Contains(text, "  PC  $1000 -> $1010\n"), text)
+	assert.Assert(t, strings.Contains(text, "  RAM          $0400-$0402 (3 bytes): 00 00 00 -> 01 02 03\n"), text)
+	assert.Assert(t, strings.Contains(text, "$d020 border color             $00 -> $0e (black -> light blue)\n"), text)
+
+	var buffer bytes.Buffer
+	assert.NilError(t, diff.WriteJSON(&buffer))
+	assert.Assert(t, strings.Contains(buffer.String(), `"new": "010203"`), buffer.String())
+	var decoded c64dws.SnapshotDiff
+	assert.NilError(t, json.Unmarshal(buffer.Bytes(), &decoded))
+	assert.DeepEqual(t, &decoded, diff)
+
+	assert.Assert(t, c64dws.DiffSnapshots(old, old).Empty())
+}
@@ -0,0 +1,177 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mojzesh/c64d-ws-client/asm6502"
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"github.com/mojzesh/c64d-ws-client/symbols"
+	"gotest.tools/assert"
+)
+
+func TestParseSymbolFiles(t *testing.T) {
+	type testCase struct {
+		name     string
+		contents string
+		expected map[string]uint16
+	}
+
+	testCases := []testCase{
+		{
+			name: "KickAssembler .sym",
+			contents: `.label main=$0810
+.const SCREEN=$0400
+.const BIG=$12345
+.namespace music {
+.label init=$1000
+.label play=4099
+}
+`,
+			expected: map[string]uint16{
+				"main":       0x0810,
+				"SCREEN":     0x0400,
+				"music.init": 0x1000,
+				"music.play": 0x1003,
+			},
+		},
+		{
+			name: "KickAssembler .vs / VICE labels",
+			contents: `al C:0810 .main
+al C:1000 .music.init
+al 1003 .play
+`,
+			expected: map[string]uint16{
+				"main":       0x0810,
+				"music.init": 0x1000,
+				"play":       0x1003,
+			},
+		},
+		{
+			name: "ACME label dump",
+			contents: `; ACME label dump
+	main	= $0810	; ?
+	.local	= $0815
+	irq = 49152
+`,
+			expected: map[string]uint16{
+				"main":   0x0810,
+				".local": 0x0815,
+				"irq":    0xc000,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		symbolTable, err := symbols.Parse(testCase.contents)
+		assert.NilError(t, err, testCase.name)
+		assert.Equal(t, symbolTable.Len(), len(testCase.expected), testCase.name)
+		for name, address := range testCase.expected {
+			fetched, exist := symbolTable.Lookup(name)
+			assert.Check(t, exist, testCase.name+": "+name)
+			assert.Equal(t, fetched, address, testCase.name+": "+name)
+		}
+	}
+}
+
+func TestSymbolResolveAndAnnotate(t *testing.T) {
+	symbolTable := symbols.NewSymbolTable()
+	symbolTable.Add("main", 0x0810)
+	symbolTable.Add("loop", 0x0815)
+
+	type testCase struct {
+		expression string
+		expected   uint16
+	}
+	for _, testCase := range []testCase{
+		{"main", 0x0810},
+		{"loop+3", 0x0818},
+		{"loop - 1", 0x0814},
+		{"$d020", 0xd020},
+		{"0xd021", 0xd021},
+		{"4096", 0x1000},
+	} {
+		address, err := symbolTable.Resolve(testCase.expression)
+		assert.NilError(t, err, testCase.expression)
+		assert.Equal(t, address, testCase.expected, testCase.expression)
+	}
+
+	_, err := symbolTable.Resolve("unknown")
+	assert.Error(t, err, "unknown symbol 'unknown'")
+
+	assert.Equal(t, symbolTable.Annotate(0x0810), "main")
+	assert.Equal(t, symbolTable.Annotate(0x0813), "main+3")
+	assert.Equal(t, symbolTable.Annotate(0x0816), "loop+1")
+	assert.Equal(t, symbolTable.Annotate(0x0800), "")
+	assert.Equal(t, symbolTable.Annotate(0x0a00), "")
+
+	// alphabetically first name is preferred at the same address
+	symbolTable.Add("start", 0x0810)
+	assert.Equal(t, symbolTable.Annotate(0x0811), "main+1")
+}
+
+func TestSymbolTableConcurrentUse(t *testing.T) {
+	symbolTable := symbols.NewSymbolTable()
+
+	// lookups rebuilding the sorted symbols run while symbols are added (go test -race)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for idx := 0; idx < 100; idx++ {
+			symbolTable.Add(fmt.Sprintf("label%d", idx), uint16(0x1000+idx))
+		}
+	}()
+	for idx := 0; idx < 100; idx++ {
+		symbolTable.Annotate(uint16(0x1000 + idx))
+		symbolTable.Lookup("label0")
+	}
+	<-done
+	assert.Equal(t, symbolTable.Len(), 100)
+	assert.Equal(t, symbolTable.Annotate(0x1063), "label99")
+}
+
+func TestDisassembleWithSymbols(t *testing.T) {
+	symbolTable := symbols.NewSymbolTable()
+	symbolTable.Add("loop", 0x0816)
+	symbolTable.Add("border", 0xd020)
+
+	code := []byte{
+		0x78,             // sei
+		0xee, 0x20, 0xd0, // inc border
+		0xd0, 0xfb, // bne loop
+		0xb1, 0xfb, // lda ($fb),y
+		0x02, // undocumented
+	}
+	instructions := asm6502.DisassembleBlock(0x0815, code)
+
+	var lines []string
+	for _, instruction := range instructions {
+		lines = append(lines, instruction.Format(symbolTable.Annotate))
+	}
+	assert.DeepEqual(t, lines, []string{
+		"sei",
+		"inc border",
+		"bne loop",
+		"lda ($fb),y",
+		".byte $02",
+	})
+}
+
+func TestClientLoadSymbols(t *testing.T) {
+	symbolsPath := filepath.Join(t.TempDir(), "program.sym")
+	err := os.WriteFile(symbolsPath, []byte(".label main=$0810\n"), 0644)
+	assert.NilError(t, err)
+
+	client := c64dws.NewDefaultClient(c64dws.EmulatorC64, c64dws.StreamAPI)
+	assert.Check(t, client != nil)
+
+	err = client.LoadSymbols(symbolsPath)
+	assert.NilError(t, err)
+
+	address, err := client.ResolveAddress("main+2")
+	assert.NilError(t, err)
+	assert.Equal(t, address, uint16(0x0812))
+	assert.Equal(t, client.DescribeAddress(0x0812), "$0812 (main+2)")
+}
@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"github.com/mojzesh/c64d-ws-client/symbols"
+	"gotest.tools/assert"
+)
+
+const traceProgram = `
+main:	lda #$01
+	ldx #$02
+	ldy #$03
+	nop
+loop:	jmp loop
+`
+
+func TestTrace(t *testing.T) {
+	client, fake := newFakeServerClient(t)
+
+	err := client.AssembleAndWrite(0x0810, traceProgram)
+	assert.NilError(t, err)
+
+	symbolTable := symbols.NewSymbolTable()
+	symbolTable.Add("main", 0x0810)
+	client.SetSymbols(symbolTable)
+
+	// -------------------------------------------------------------
+	// test: stop at address, VICE format
+	// -------------------------------------------------------------
+	fake.setCPU(c64dws.CPUState{PC: 0x0810, SP: 0xf3, P: 0x24})
+	var output bytes.Buffer
+	result, err := client.Trace(context.Background(), c64dws.TraceOptions{
+		Output: &output,
+		Format: c64dws.TraceFormatVICE,
+		StopAt: []uint16{0x0817},
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, result.StopReason, c64dws.TraceStopBreakpoint)
+	assert.Equal(t, result.Executed, 4)
+	assert.Equal(t, result.Recorded, 4)
+	assert.Equal(t, result.LastPC, uint16(0x0817))
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	assert.Equal(t, len(lines), 4)
+	assert.Equal(t, lines[0], ".C:0810  A9 01     LDA #$01       - A:00 X:00 Y:00 SP:f3 ..-..I..          0")
+	assert.Assert(t, strings.HasPrefix(lines[3], ".C:0816  EA        NOP"))
+
+	// -------------------------------------------------------------
+	// test: max instructions, address range filter, JSONL format
+	// -------------------------------------------------------------
+	fake.setCPU(c64dws.CPUState{PC: 0x0810})
+	output.Reset()
+	result, err = client.Trace(context.Background(), c64dws.TraceOptions{
+		Output:          &output,
+		Format:          c64dws.TraceFormatJSONL,
+		Ranges:          []c64dws.AddressRange{{Start: 0x0817, End: 0x0819}},
+		MaxInstructions: 8,
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, result.StopReason, c64dws.TraceStopMaxInstructions)
+	assert.Equal(t, result.Executed, 8)
+	assert.Equal(t, result.Recorded, 4)
+
+	lines = strings.Split(strings.TrimSpace(output.String()), "\n")
+	assert.Equal(t, len(lines), 4)
+	var entry c64dws.TraceEntry
+	err = json.Unmarshal([]byte(lines[0]), &entry)
+	assert.NilError(t, err)
+	assert.Equal(t, entry.PC, uint16(0x0817))
+	assert.Equal(t, entry.Disassembly, "jmp $0817")
+	assert.Equal(t, entry.Label, "main+7")
+
+	// -------------------------------------------------------------
+	// test: cancelled trace
+	// -------------------------------------------------------------
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err = client.Trace(ctx, c64dws.TraceOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, result.StopReason, c64dws.TraceStopCancelled)
+	assert.Equal(t, result.Executed, 0)
+}
+
+func TestFormatFlags(t *testing.T) {
+	assert.Equal(t, c64dws.FormatFlags(0x00), "..-.....")
+	assert.Equal(t, c64dws.FormatFlags(0xff), "NV-BDIZC")
+	assert.Equal(t, c64dws.FormatFlags(c64dws.FlagNegative|c64dws.FlagCarry), "N.-....C")
+}
@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"github.com/mojzesh/c64d-ws-client/vsf"
+	"gotest.tools/assert"
+)
+
+func TestVSFConversion(t *testing.T) {
+	snapshot := newTestSnapshot()
+	snapshot.CPU = c64dws.CPUState{PC: 0x0810, A: 0x01, X: 0x02, Y: 0x03, SP: 0xf6, P: 0x24, Memory0001: 0x35, RasterY: 0x123, RasterCycle: 17, Game: 1, Exrom: 1}
+	snapshot.Counters.Cycle = 0x123456789
+	snapshot.ProcessorPort = [2]byte{0x2f, 0x35}
+	copy(snapshot.RAM[0x0810:], []byte{0xee, 0x20, 0xd0})
+	snapshot.ColorRAM[0x03ff] = 0x0e
+	snapshot.Chips[c64dws.ChipVIC][0x20] = 0x0e
+	snapshot.Chips[c64dws.ChipCIA1][0x0e] = 0x11
+	snapshot.Chips[c64dws.ChipCIA2][0x00] = 0x97
+	snapshot.Chips[c64dws.ChipCIA2][0x0d] = 0x81
+	snapshot.Chips[c64dws.ChipSID][0x18] = 0x0f
+
+	// -------------------------------------------------------------
+	// test: update and import round trip
+	// -------------------------------------------------------------
+	vice := newTestVSF()
+	vice.Module(vsf.ModuleCIA2).Data[0x0d] = 0x90
+	assert.NilError(t, vice.Update(snapshot))
+	// CIA interrupt mask is kept, interrupt flags are not imported
+	assert.Equal(t, vice.Module(vsf.ModuleCIA2).Data[0x0d], byte(0x90))
+	snapshot.Chips[c64dws.ChipCIA2][0x0d] = 0x00
+	path := filepath.Join(t.TempDir(), "state.vsf")
+	assert.NilError(t, vice.SaveFile(path))
+
+	loaded, err := vsf.LoadFile(path)
+	assert.NilError(t, err)
+	assert.Equal(t, loaded.Machine, "C64SC")
+	assert.Equal(t, loaded.VICEVersion, [4]byte{3, 7, 0, 0})
+	assert.Equal(t, len(loaded.Modules), 7)
+
+	imported, err := loaded.Snapshot()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, imported.CPU, snapshot.CPU)
+	assert.Equal(t, imported.Counters.Cycle, snapshot.Counters.Cycle)
+	assert.DeepEqual(t, imported.ProcessorPort, snapshot.ProcessorPort)
+	assert.DeepEqual(t, imported.RAM, snapshot.RAM)
+	assert.DeepEqual(t, imported.ColorRAM, snapshot.ColorRAM)
+	for _, chip := range c64dws.Chips {
+		assert.DeepEqual(t, imported.Chips[chip], snapshot.Chips[chip])
+	}
+
+	// -------------------------------------------------------------
+	// test: update keeps data unknown to the conversion
+	// -------------------------------------------------------------
+	vice = newTestVSF()
+	vice.VICEVersion = [4]byte{3, 4, 0, 0}
+	cpu := vice.Module(vsf.ModuleCPU)
+	cpu.Data = append(make([]byte, 4+7), 0xaa, 0xbb)
+	assert.NilError(t, vice.Update(snapshot))
+	assert.DeepEqual(t, cpu.Data, []byte{0x89, 0x67, 0x45, 0x23, 0x01, 0x02, 0x03, 0xf6, 0x10, 0x08, 0x24, 0xaa, 0xbb})
+	assert.DeepEqual(t, vice.Module("C64ROM").Data, []byte{0x01, 0x02})
+	sid := vice.Module(vsf.ModuleSID).Data
+	assert.DeepEqual(t, sid[len(sid)-2:], []byte{0xcc, 0xcc})
+
+	// -------------------------------------------------------------
+	// test: files without converted modules are not updated
+	// -------------------------------------------------------------
+	incomplete := newTestVSF()
+	incomplete.Modules = incomplete.Modules[:len(incomplete.Modules)-1]
+	assert.ErrorContains(t, incomplete.Update(snapshot), "Missing or invalid SID module")
+	assert.DeepEqual(t, incomplete.Module(vsf.ModuleMemory).Data, make([]byte, 4+0x10000))
+
+	// -------------------------------------------------------------
+	// test: files without VICE version (VICE < 2.4)
+	// -------------------------------------------------------------
+	vice.VICEVersion = [4]byte{}
+	var buffer bytes.Buffer
+	assert.NilError(t, vice.Write(&buffer))
+	old, err := vsf.Read(&buffer)
+	assert.NilError(t, err)
+	assert.Equal(t, old.Machine, "C64SC")
+	assert.Equal(t, len(old.Modules), len(vice.Modules))
+	_, err = old.Snapshot()
+	assert.NilError(t, err)
+
+	_, err = vsf.Read(bytes.NewReader([]byte("C64DSNAP")))
+	assert.ErrorContains(t, err, "Invalid VICE snapshot header")
+}
+
+// File with the modules of a VICE 3.7 x64sc snapshot. Module sizes are the converted
+// fields plus two bytes of data unknown to the conversion, not real module sizes.
+func newTestVSF() *vsf.File {
+	module := func(name string, major byte, minor byte, size int) *vsf.Module {
+		data := append(make([]byte, size), 0xcc, 0xcc)
+		return &vsf.Module{Name: name, Major: major, Minor: minor, Data: data}
+	}
+	memory := &vsf.Module{Name: vsf.ModuleMemory, Major: 0, Minor: 1, Data: make([]byte, 4+0x10000)}
+	return &vsf.File{Major: 2, Minor: 0, Machine: "C64SC", VICEVersion: [4]byte{3, 7, 0, 0}, Modules: []*vsf.Module{
+		module(vsf.ModuleCPU, 1, 1, 8+7),
+		memory,
+		{Name: "C64ROM", Major: 0, Minor: 0, Data: []byte{0x01, 0x02}},
+		module(vsf.ModuleVIC, 1, 1, 1119+0x40),
+		module(vsf.ModuleCIA1, 2, 2, 0x10),
+		module(vsf.ModuleCIA2, 2, 2, 0x10),
+		module(vsf.ModuleSID, 1, 1, 0x20),
+	}}
+}
@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+	"gotest.tools/assert"
+)
+
+// Receive the next watch event or fail after timeout
+func nextWatchEvent(t *testing.T, events <-chan c64dws.WatchEvent) c64dws.WatchEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for watch event")
+	}
+	return c64dws.WatchEvent{}
+}
+
+func TestMergeRanges(t *testing.T) {
+	ranges := []c64dws.AddressRange{{Start: 0xc100, End: 0xc10f}, {Start: 0xc000, End: 0xc00f}, {Start: 0xc012, End: 0xc01f}, {Start: 0xc008, End: 0xc00a}}
+	assert.DeepEqual(t, c64dws.MergeRanges(ranges, 0), []c64dws.AddressRange{
+		{Start: 0xc000, End: 0xc00f}, {Start: 0xc012, End: 0xc01f}, {Start: 0xc100, End: 0xc10f},
+	})
+	assert.DeepEqual(t, c64dws.MergeRanges(ranges, 2), []c64dws.AddressRange{
+		{Start: 0xc000, End: 0xc01f}, {Start: 0xc100, End: 0xc10f},
+	})
+}
+
+func TestWatch(t *testing.T) {
+	client, fake := newFakeServerClient(t)
+
+	// -------------------------------------------------------------
+	// test: polling reports changed watched bytes only
+	// -------------------------------------------------------------
+	ranges := []c64dws.AddressRange{{Start: 0xc000, End: 0xc00f}, {Start: 0xc012, End: 0xc01f}, {Start: 0xc100, End: 0xc10f}}
+	watcher, err := client.NewWatcher(ranges, c64dws.WatchOptions{Interval: time.Millisecond, MaxGap: 4})
+	assert.NilError(t, err)
+	assert.Equal(t, watcher.Reads(), 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- watcher.Run(ctx)
+	}()
+	for fake.fnCount("ram/readBlock") < 4 {
+		time.Sleep(time.Millisecond)
+	}
+
+	fake.writeRAM(0xc010, []byte{0x55})
+	fake.writeRAM(0xc005, []byte{0x01})
+	fake.writeRAM(0xc108, []byte{0x02})
+	// changes may be detected by different polls, in any order
+	changes := map[uint16]c64dws.WatchEvent{}
+	for len(changes) < 2 {
+		event := nextWatchEvent(t, watcher.Events())
+		changes[event.Address] = event
+	}
+	assert.DeepEqual(t, changes, map[uint16]c64dws.WatchEvent{
+		0xc005: {Address: 0xc005, Old: 0, New: 1},
+		0xc108: {Address: 0xc108, Old: 0, New: 2},
+	})
+
+	cancel()
+	assert.NilError(t, <-done)
+	_, open := <-watcher.Events()
+	assert.Assert(t, !open)
+
+	// -------------------------------------------------------------
+	// test: every frame, events carry the frame number
+	// -------------------------------------------------------------
+	// loop: inx / stx $c000 / jmp loop
+	fake.writeRAM(0x1000, []byte{0xe8, 0x8e, 0x00, 0xc0, 0x4c, 0x00, 0x10})
+	fake.setCPU(c64dws.CPUState{PC: 0x1000, SP: 0xff, P: 0x24})
+	watcher, err = client.NewWatcher(ranges[:1], c64dws.WatchOptions{EveryFrame: true, RasterLine: 0x30})
+	assert.NilError(t, err)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		done <- watcher.Run(ctx)
+	}()
+	first := nextWatchEvent(t, watcher.Events())
+	second := nextWatchEvent(t, watcher.Events())
+	assert.Equal(t, first.Address, uint16(0xc000))
+	assert.Equal(t, second.Old, first.New)
+	assert.Equal(t, second.Frame, first.Frame+1)
+
+	cancel()
+	assert.NilError(t, <-done)
+	assert.Equal(t, len(fake.rasterBPs), 0)
+
+	_, err = client.NewWatcher([]c64dws.AddressRange{{Start: 0x10, End: 0x0f}}, c64dws.WatchOptions{})
+	assert.ErrorContains(t, err, "Invalid range")
+}
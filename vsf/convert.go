@@ -0,0 +1,183 @@
+package vsf
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/mojzesh/c64d-ws-client/c64dws"
+)
+
+// Converted modules. Layouts follow VICE 3.x, only the leading fields used by
+// the conversion are described, data following them is kept by Update.
+const (
+	ModuleCPU    = "MAINCPU"
+	ModuleMemory = "C64MEM"
+	ModuleVIC    = "VIC-II"
+	ModuleCIA1   = "CIA1"
+	ModuleCIA2   = "CIA2"
+	ModuleSID    = "SID"
+)
+
+// C64MEM: port data, port direction, EXROM, GAME, 64KB of RAM.
+// Port data is the processor port data latch, not the value the CPU reads from $01
+// (input bits read the port pins), it's converted as the snapshot's $01.
+const (
+	memoryPortData = 0
+	memoryPortDir  = 1
+	memoryExrom    = 2
+	memoryGame     = 3
+	memoryRAM      = 4
+	memorySize     = memoryRAM + 0x10000
+)
+
+// MAINCPU: clock (32-bit before VICE 3.5, 64-bit since), A, X, Y, SP, PC (word), P
+const cpuRegistersSize = 7
+
+// VIC-II: bad line and blank flags, color buffer, color RAM, light pen and
+// matrix buffer state, sprite DMA mask, RAM base, raster cycle and line, registers
+const (
+	vicColorRAM    = 43
+	vicRasterCycle = 1116
+	vicRasterLine  = 1117
+	vicRegisters   = 1119
+	vicSize        = vicRegisters + 0x40
+)
+
+// CIA: registers in their order, timers are the current counter values.
+// ICR ($0d) holds the interrupt mask, while the c64dws snapshot holds the interrupt
+// flags read from $dc0d / $dd0d, so it's not converted.
+const (
+	ciaICR  = 0x0d
+	ciaSize = 0x10
+)
+
+// SID: registers
+const sidSize = 0x20
+
+// Convert to c64dws snapshot. C64MEM and MAINCPU modules are required,
+// missing chip modules are left out, 1541 RAM and CIA interrupt flags are not
+// converted (zeros).
+func (f *File) Snapshot() (*c64dws.Snapshot, error) {
+	memory := f.Module(ModuleMemory)
+	if memory == nil || len(memory.Data) < memorySize {
+		return nil, fmt.Errorf("Missing or invalid %s module", ModuleMemory)
+	}
+	cpu := f.Module(ModuleCPU)
+	clockSize := f.clockSize()
+	if cpu == nil || len(cpu.Data) < clockSize+cpuRegistersSize {
+		return nil, fmt.Errorf("Missing or invalid %s module", ModuleCPU)
+	}
+
+	snapshot := &c64dws.Snapshot{
+		ProcessorPort: [2]byte{memory.Data[memoryPortDir], memory.Data[memoryPortData]},
+		RAM:           append([]byte{}, memory.Data[memoryRAM:memorySize]...),
+		ColorRAM:      make([]byte, c64dws.ColorRAMSize),
+		Chips:         map[c64dws.Chip][]byte{},
+		DriveRAM:      make([]byte, c64dws.MemorySpaceDrive1541RAM.Size()),
+	}
+
+	registers := cpu.Data[clockSize:]
+	snapshot.CPU = c64dws.CPUState{
+		A:          registers[0],
+		X:          registers[1],
+		Y:          registers[2],
+		SP:         registers[3],
+		PC:         binary.LittleEndian.Uint16(registers[4:]),
+		P:          registers[6],
+		Memory0001: memory.Data[memoryPortData],
+		Exrom:      memory.Data[memoryExrom],
+		Game:       memory.Data[memoryGame],
+	}
+	if clockSize == 8 {
+		snapshot.Counters.Cycle = binary.LittleEndian.Uint64(cpu.Data)
+	} else {
+		snapshot.Counters.Cycle = uint64(binary.LittleEndian.Uint32(cpu.Data))
+	}
+
+	if vic := f.Module(ModuleVIC); vic != nil && len(vic.Data) >= vicSize {
+		for idx := range snapshot.ColorRAM {
+			snapshot.ColorRAM[idx] = vic.Data[vicColorRAM+idx] & 0x0f
+		}
+		snapshot.CPU.RasterCycle = uint64(vic.Data[vicRasterCycle])
+		snapshot.CPU.RasterY = binary.LittleEndian.Uint16(vic.Data[vicRasterLine:])
+		snapshot.Chips[c64dws.ChipVIC] = chipRegisters(c64dws.ChipVIC, vic.Data[vicRegisters:])
+	}
+	for chip, name := range map[c64dws.Chip]string{c64dws.ChipCIA1: ModuleCIA1, c64dws.ChipCIA2: ModuleCIA2, c64dws.ChipSID: ModuleSID} {
+		if module := f.Module(name); module != nil && len(module.Data) >= chip.RegistersCount() {
+			snapshot.Chips[chip] = chipRegisters(chip, module.Data)
+			if chip != c64dws.ChipSID {
+				snapshot.Chips[chip][ciaICR] = 0
+			}
+		}
+	}
+
+	return snapshot, nil
+}
+
+// Write the c64dws snapshot into the modules of a file saved by VICE, data not known
+// to this package is kept, as is the CIA interrupt mask. All converted modules must be
+// present, the file is left unchanged when one is missing or too short.
+func (f *File) Update(snapshot *c64dws.Snapshot) error {
+	if len(snapshot.RAM) != 0x10000 || len(snapshot.ColorRAM) != c64dws.ColorRAMSize {
+		return fmt.Errorf("Invalid snapshot memory size")
+	}
+	clockSize := f.clockSize()
+	for name, size := range map[string]int{
+		ModuleMemory: memorySize,
+		ModuleCPU:    clockSize + cpuRegistersSize,
+		ModuleVIC:    vicSize,
+		ModuleCIA1:   ciaSize,
+		ModuleCIA2:   ciaSize,
+		ModuleSID:    sidSize,
+	} {
+		if module := f.Module(name); module == nil || len(module.Data) < size {
+			return fmt.Errorf("Missing or invalid %s module", name)
+		}
+	}
+
+	memory := f.Module(ModuleMemory)
+	memory.Data[memoryPortData] = snapshot.ProcessorPort[1]
+	memory.Data[memoryPortDir] = snapshot.ProcessorPort[0]
+	memory.Data[memoryExrom] = snapshot.CPU.Exrom
+	memory.Data[memoryGame] = snapshot.CPU.Game
+	copy(memory.Data[memoryRAM:], snapshot.RAM)
+
+	cpu := f.Module(ModuleCPU)
+	if clockSize == 8 {
+		binary.LittleEndian.PutUint64(cpu.Data, snapshot.Counters.Cycle)
+	} else {
+		binary.LittleEndian.PutUint32(cpu.Data, uint32(snapshot.Counters.Cycle))
+	}
+	registers := cpu.Data[clockSize:]
+	registers[0], registers[1], registers[2], registers[3] = snapshot.CPU.A, snapshot.CPU.X, snapshot.CPU.Y, snapshot.CPU.SP
+	binary.LittleEndian.PutUint16(registers[4:], snapshot.CPU.PC)
+	registers[6] = snapshot.CPU.P
+
+	vic := f.Module(ModuleVIC)
+	copy(vic.Data[vicColorRAM:], snapshot.ColorRAM)
+	vic.Data[vicRasterCycle] = uint8(snapshot.CPU.RasterCycle)
+	binary.LittleEndian.PutUint16(vic.Data[vicRasterLine:], snapshot.CPU.RasterY)
+	copy(vic.Data[vicRegisters:], snapshot.Chips[c64dws.ChipVIC])
+
+	for chip, name := range map[c64dws.Chip]string{c64dws.ChipCIA1: ModuleCIA1, c64dws.ChipCIA2: ModuleCIA2} {
+		cia := f.Module(name).Data
+		mask := cia[ciaICR]
+		copy(cia, snapshot.Chips[chip])
+		cia[ciaICR] = mask
+	}
+	copy(f.Module(ModuleSID).Data, snapshot.Chips[c64dws.ChipSID])
+
+	return nil
+}
+
+// Size of MAINCPU clock in bytes
+func (f *File) clockSize() int {
+	if f.wideClock() {
+		return 8
+	}
+	return 4
+}
+
+func chipRegisters(chip c64dws.Chip, data []byte) []byte {
+	return append([]byte{}, data[:chip.RegistersCount()]...)
+}
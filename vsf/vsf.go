@@ -0,0 +1,174 @@
+// # VICE snapshot files
+//
+// This package reads and writes VICE snapshot files (.vsf) and converts
+// them to and from c64dws snapshots, so machine states can be shared
+// between Retro Debugger and VICE. Files are never created from scratch,
+// a c64dws snapshot is written into a file saved by VICE, which provides
+// the modules this package doesn't convert (ROMs, drives, cartridges...).
+//
+// A snapshot file is a header followed by modules, each module is:
+// name (16 bytes, zero padded), major and minor version, size of the
+// module including this 22-byte header (uint32, little endian) and data.
+// Modules not known to this package are kept as they are.
+package vsf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// File header magics
+const (
+	fileMagic    = "VICE Snapshot File\x1a"
+	versionMagic = "VICE Version\x1a"
+)
+
+// Length of machine and module names
+const nameLength = 16
+
+// Size of the module header: name, major, minor and size
+const moduleHeaderSize = nameLength + 1 + 1 + 4
+
+// Snapshot module
+type Module struct {
+	Name  string
+	Major byte
+	Minor byte
+	Data  []byte
+}
+
+// VICE snapshot file
+type File struct {
+	Major       byte      // snapshot format version
+	Minor       byte      // snapshot format version
+	Machine     string    // 'C64SC', 'C64', ...
+	VICEVersion [4]byte   // major, minor, build, 0; zero for files written by VICE < 2.4
+	Revision    uint32    // SVN revision of VICE
+	Modules     []*Module // modules in the file order
+}
+
+// Module by name, nil if the file doesn't contain it
+func (f *File) Module(name string) *Module {
+	for _, module := range f.Modules {
+		if module.Name == name {
+			return module
+		}
+	}
+	return nil
+}
+
+// Check if the file was written by VICE with 64-bit clock values (3.5 and newer)
+func (f *File) wideClock() bool {
+	return f.VICEVersion[0] > 3 || f.VICEVersion[0] == 3 && f.VICEVersion[1] >= 5
+}
+
+// Read snapshot file
+func Read(r io.Reader) (*File, error) {
+	reader := bufio.NewReader(r)
+	header := make([]byte, len(fileMagic)+2+nameLength)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("Invalid VICE snapshot header: %w", err)
+	}
+	if string(header[:len(fileMagic)]) != fileMagic {
+		return nil, fmt.Errorf("Not a VICE snapshot file")
+	}
+	file := &File{
+		Major:   header[len(fileMagic)],
+		Minor:   header[len(fileMagic)+1],
+		Machine: trimName(header[len(fileMagic)+2:]),
+	}
+
+	// VICE version is present since VICE 2.4
+	if magic, err := reader.Peek(len(versionMagic)); err == nil && string(magic) == versionMagic {
+		version := make([]byte, len(versionMagic)+4+4)
+		if _, err := io.ReadFull(reader, version); err != nil {
+			return nil, fmt.Errorf("Invalid VICE version: %w", err)
+		}
+		copy(file.VICEVersion[:], version[len(versionMagic):])
+		file.Revision = binary.LittleEndian.Uint32(version[len(versionMagic)+4:])
+	}
+
+	moduleHeader := make([]byte, moduleHeaderSize)
+	for {
+		if _, err := io.ReadFull(reader, moduleHeader); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("Invalid module header: %w", err)
+		}
+		module := &Module{
+			Name:  trimName(moduleHeader[:nameLength]),
+			Major: moduleHeader[nameLength],
+			Minor: moduleHeader[nameLength+1],
+		}
+		size := binary.LittleEndian.Uint32(moduleHeader[nameLength+2:])
+		if size < moduleHeaderSize {
+			return nil, fmt.Errorf("Invalid size %d of module '%s'", size, module.Name)
+		}
+		module.Data = make([]byte, size-moduleHeaderSize)
+		if _, err := io.ReadFull(reader, module.Data); err != nil {
+			return nil, fmt.Errorf("Invalid module '%s': %w", module.Name, err)
+		}
+		file.Modules = append(file.Modules, module)
+	}
+
+	return file, nil
+}
+
+// Write snapshot file
+func (f *File) Write(w io.Writer) error {
+	buffer := bufio.NewWriter(w)
+	buffer.WriteString(fileMagic)
+	buffer.Write([]byte{f.Major, f.Minor})
+	buffer.Write(padName(f.Machine))
+	if f.VICEVersion != [4]byte{} {
+		buffer.WriteString(versionMagic)
+		buffer.Write(f.VICEVersion[:])
+		binary.Write(buffer, binary.LittleEndian, f.Revision)
+	}
+
+	for _, module := range f.Modules {
+		buffer.Write(padName(module.Name))
+		buffer.Write([]byte{module.Major, module.Minor})
+		binary.Write(buffer, binary.LittleEndian, uint32(moduleHeaderSize+len(module.Data)))
+		buffer.Write(module.Data)
+	}
+
+	return buffer.Flush()
+}
+
+// Load snapshot file
+func LoadFile(path string) (*File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Read(file)
+}
+
+// Save snapshot file
+func (f *File) SaveFile(path string) error {
+	var buffer bytes.Buffer
+	if err := f.Write(&buffer); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buffer.Bytes(), 0644)
+}
+
+func trimName(name []byte) string {
+	return strings.TrimRight(string(name), "\x00")
+}
+
+func padName(name string) []byte {
+	padded := make([]byte, nameLength)
+	copy(padded, name)
+	return padded
+}